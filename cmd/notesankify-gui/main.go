@@ -23,12 +23,14 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/kpauljoseph/notesankify/internal/anki"
 	"github.com/kpauljoseph/notesankify/internal/pdf"
 	"github.com/kpauljoseph/notesankify/internal/scanner"
+	"github.com/kpauljoseph/notesankify/internal/state"
 	"github.com/kpauljoseph/notesankify/pkg/logger"
 	"github.com/kpauljoseph/notesankify/pkg/models"
 )
@@ -56,18 +58,36 @@ type NotesAnkifyGUI struct {
 	// Processing settings
 	processingMode ProcessingMode
 	dimensions     models.PageDimensions
+	layout         models.CardLayout
+	pagePairing    pdf.PairingMode
+	unfinished     anki.UnfinishedPolicy
 
 	// UI components
-	dirEntry       *widget.Entry
-	rootDeckEntry  *widget.Entry
-	modeSelect     *widget.Select
-	widthEntry     *widget.Entry
-	heightEntry    *widget.Entry
-	outputDirEntry *widget.Entry
-	dimContainer   *fyne.Container
-	verboseCheck   *widget.Check
-	progress       *widget.ProgressBarInfinite
-	status         *widget.Label
+	dirEntry          *widget.Entry
+	rootDeckEntry     *widget.Entry
+	modeSelect        *widget.Select
+	layoutSelect      *widget.Select
+	splitSelect       *widget.Select
+	pairingSelect     *widget.Select
+	unfinishedSelect  *widget.Select
+	pagesEntry        *widget.Entry
+	widthEntry        *widget.Entry
+	heightEntry       *widget.Entry
+	outputDirEntry    *widget.Entry
+	dimContainer      *fyne.Container
+	presetsCheck      *widget.CheckGroup
+	verboseCheck      *widget.Check
+	eraseMarkersCheck *widget.Check
+	trimCheck         *widget.Check
+	scanCheck         *widget.Check
+	outlineCheck      *widget.Check
+	directivesCheck   *widget.Check
+	titleDecksCheck   *widget.Check
+	pageLinksCheck    *widget.Check
+	moveNotesCheck    *widget.Check
+	isolateCheck      *widget.Check
+	progress          *widget.ProgressBarInfinite
+	status            *widget.Label
 }
 
 func NewNotesAnkifyGUI() *NotesAnkifyGUI {
@@ -84,10 +104,7 @@ func NewNotesAnkifyGUI() *NotesAnkifyGUI {
 		window.SetIcon(bundledIcon)
 	}
 
-	dimensions := models.PageDimensions{
-		Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
-		Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
-	}
+	dimensions := models.GoodNotesStandardPreset
 
 	return &NotesAnkifyGUI{
 		window:         window,
@@ -96,16 +113,14 @@ func NewNotesAnkifyGUI() *NotesAnkifyGUI {
 		ankiService:    anki.NewService(log),
 		logFileName:    logFileName,
 		dimensions:     dimensions,
+		layout:         models.SingleCardLayout,
 		processingMode: ModeBoth, // Start with most strict mode
 		updateChecker:  updater.NewChecker(log),
 	}
 }
 
 func (gui *NotesAnkifyGUI) resetDimensions() {
-	gui.dimensions = models.PageDimensions{
-		Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
-		Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
-	}
+	gui.dimensions = models.GoodNotesStandardPreset
 	gui.widthEntry.SetText(fmt.Sprintf("%.2f", gui.dimensions.Width))
 	gui.heightEntry.SetText(fmt.Sprintf("%.2f", gui.dimensions.Height))
 }
@@ -153,20 +168,98 @@ func (gui *NotesAnkifyGUI) setupUI() {
 
 	gui.modeSelect.OnChanged = gui.handleModeChange
 
+	// Cards per page
+	var layoutNames []string
+	for _, profile := range models.LayoutProfiles {
+		layoutNames = append(layoutNames, profile.Name)
+	}
+	gui.layoutSelect = widget.NewSelect(layoutNames, func(selected string) {
+		if profile, ok := models.LookupLayout(selected); ok {
+			profile.Split = gui.layout.Split
+			gui.layout = profile
+		}
+	})
+	gui.layoutSelect.SetSelected(gui.layout.Name)
+
+	splitOptions := map[string]models.SplitDirection{
+		"Automatic (by page orientation)": models.SplitAuto,
+		"Top / Bottom":                    models.SplitHorizontal,
+		"Left / Right":                    models.SplitVertical,
+	}
+	gui.splitSelect = widget.NewSelect(
+		[]string{"Automatic (by page orientation)", "Top / Bottom", "Left / Right"},
+		func(selected string) {
+			gui.layout.Split.Direction = splitOptions[selected]
+		},
+	)
+	gui.splitSelect.SetSelected("Automatic (by page orientation)")
+
+	pairingOptions := map[string]pdf.PairingMode{
+		"One page per card":               pdf.PairingNone,
+		"Question page, then answer page": pdf.PairingConsecutive,
+		"QUESTION page, then ANSWER page": pdf.PairingMarkers,
+	}
+	gui.pairingSelect = widget.NewSelect(
+		[]string{"One page per card", "Question page, then answer page", "QUESTION page, then ANSWER page"},
+		func(selected string) {
+			gui.pagePairing = pairingOptions[selected]
+		},
+	)
+	gui.pairingSelect.SetSelected("One page per card")
+
+	unfinishedOptions := map[string]anki.UnfinishedPolicy{
+		"Import as usual":          "",
+		"Skip until answered":      anki.UnfinishedSkip,
+		"Import suspended":         anki.UnfinishedSuspend,
+		"Import tagged unfinished": anki.UnfinishedTag,
+	}
+	gui.unfinishedSelect = widget.NewSelect(
+		[]string{"Import as usual", "Skip until answered", "Import suspended", "Import tagged unfinished"},
+		func(selected string) {
+			gui.unfinished = unfinishedOptions[selected]
+		},
+	)
+	gui.unfinishedSelect.SetSelected("Import as usual")
+
+	gui.pagesEntry = widget.NewEntry()
+	gui.pagesEntry.SetPlaceHolder("All pages, or e.g. 1-10,15,-3..")
+
+	layoutContainer := container.NewVBox(
+		container.NewGridWithColumns(2,
+			container.NewBorder(nil, nil, widget.NewLabel("Cards per page:"), nil, gui.layoutSelect),
+			container.NewBorder(nil, nil, widget.NewLabel("Split:"), nil, gui.splitSelect),
+		),
+		container.NewBorder(nil, nil, widget.NewLabel("Two-page cards:"), nil, gui.pairingSelect),
+		container.NewBorder(nil, nil, widget.NewLabel("Blank answers:"), nil, gui.unfinishedSelect),
+		container.NewBorder(nil, nil, widget.NewLabel("Pages:"), nil, gui.pagesEntry),
+	)
+
 	// Dimension controls
 	gui.widthEntry = widget.NewEntry()
 	gui.heightEntry = widget.NewEntry()
 	gui.resetDimensions() // Set default dimensions
 
 	resetDimensionsBtn := widget.NewButton("Reset to Default", gui.resetDimensions)
+	detectDimensionsBtn := widget.NewButton("Detect from my notes", gui.handleDetectDimensions)
 
 	dimensionsForm := container.NewGridWithColumns(2,
 		container.NewBorder(nil, nil, widget.NewLabel("Width:"), nil, gui.widthEntry),
 		container.NewBorder(nil, nil, widget.NewLabel("Height:"), nil, gui.heightEntry),
 	)
 
-	gui.dimContainer = container.NewBorder(nil, nil, nil, resetDimensionsBtn,
-		dimensionsForm)
+	var extraPresets []string
+	for _, name := range models.PresetNames(nil) {
+		if name != models.GoodNotesStandardPreset.Name {
+			extraPresets = append(extraPresets, name)
+		}
+	}
+	gui.presetsCheck = widget.NewCheckGroup(extraPresets, nil)
+	gui.presetsCheck.Horizontal = true
+
+	gui.dimContainer = container.NewVBox(
+		container.NewBorder(nil, nil, nil, container.NewHBox(detectDimensionsBtn, resetDimensionsBtn), dimensionsForm),
+		container.NewBorder(nil, nil, widget.NewLabel("Also accept:"), nil, gui.presetsCheck),
+	)
 
 	// Additional settings
 	// Optional output directory
@@ -194,6 +287,17 @@ func (gui *NotesAnkifyGUI) setupUI() {
 		gui.log.SetVerbose(checked)
 	})
 
+	gui.eraseMarkersCheck = widget.NewCheck("Erase QUESTION/ANSWER labels", nil)
+	gui.trimCheck = widget.NewCheck("Trim empty margins", nil)
+	gui.scanCheck = widget.NewCheck("Clean up scanned paper cards", nil)
+	gui.outlineCheck = widget.NewCheck("Sub-decks from PDF bookmarks", nil)
+	gui.directivesCheck = widget.NewCheck("Apply #tag, DECK:, !suspend and !flag lines on cards", nil)
+	gui.titleDecksCheck = widget.NewCheck("Name decks after PDF titles", nil)
+	gui.pageLinksCheck = widget.NewCheck("Link cards to their PDF page", nil)
+	gui.moveNotesCheck = widget.NewCheck("Move notes of moved PDFs to their new deck", nil)
+	gui.isolateCheck = widget.NewCheck("Render PDFs in a separate process", nil)
+	gui.isolateCheck.SetChecked(true)
+
 	// Progress indicator
 	gui.progress = widget.NewProgressBarInfinite()
 	gui.progress.Hide()
@@ -244,20 +348,36 @@ func (gui *NotesAnkifyGUI) setupUI() {
 			"	The Flashcard page must have uppercase QUESTION/ANSWER text in the page\n\n\n\n" +
 			"• **Only Pages Matching Dimensions**:\n\n " +
 			"	The Flashcard page must match specified dimensions\n\n\n\n" +
+			"• **Also accept**:\n\n " +
+			"	Match pages of other common sizes (index cards, A5, Letter, ...) in the same run\n\n\n\n" +
 			"• **Process All Pages**:\n\n " +
-			"	Split every PDF page into two halves top->question bottom->answer",
+			"	Split every PDF page into two halves top->question bottom->answer\n\n\n\n" +
+			"• **Cards per page**:\n\n " +
+			"	Use a grid layout (grid-2, grid-4, grid-6) when a page holds several cards\n\n\n\n" +
+			"• **Split**:\n\n " +
+			"	Portrait pages are split top/bottom and landscape pages left/right unless a direction is chosen\n\n\n\n" +
+			"• **Two-page cards**:\n\n " +
+			"	Take the question from one page and the answer from the following page\n\n\n\n" +
+			"• **Blank answers**:\n\n " +
+			"	Cards whose answer is not written yet can be skipped, suspended or tagged; their note is updated once the answer is filled in\n\n\n\n" +
+			"• **Pages**:\n\n " +
+			"	Process only some pages of every PDF: ranges like 1-10, open ranges like 5.., -3.. for the last three pages and !12 to leave a page out",
 	)
 	processingInfoText.Wrapping = fyne.TextWrapWord
 
 	processingInfo := gui.createInfoSection("Processing Mode",
 		"Choose how to identify flashcards in your PDF files:",
 		container.NewBorder(
-			container.NewBorder(nil, nil, nil, nil, processingInfoText), container.NewVBox(gui.modeSelect, gui.dimContainer), nil, nil, nil),
+			container.NewBorder(nil, nil, nil, nil, processingInfoText), container.NewVBox(gui.modeSelect, gui.dimContainer, layoutContainer), nil, nil, nil),
 	)
 
 	settingsInfo := gui.createInfoSection("Additional Settings",
-		"Enable verbose logging to see detailed processing information.",
-		container.NewVBox(gui.verboseCheck))
+		"Enable verbose logging to see detailed processing information.\n\n"+
+			"Erasing labels removes the printed QUESTION/ANSWER words from the card images, "+
+			"and trimming crops empty margins so cards are easier to read on phones.\n\n"+
+			"Scan cleanup straightens and sharpens photographed cards and crops them from the "+
+			"background. Their size is checked by aspect ratio only.",
+		container.NewVBox(gui.verboseCheck, gui.eraseMarkersCheck, gui.trimCheck, gui.scanCheck, gui.outlineCheck, gui.directivesCheck, gui.titleDecksCheck, gui.pageLinksCheck, gui.moveNotesCheck, gui.isolateCheck))
 	outputDirInfo := gui.createInfoSection("Output Directory",
 		"Optional: Specify where to save the processed flashcard images.\n"+
 			"If not specified, a temporary directory will be used.\n"+
@@ -383,6 +503,17 @@ func (gui *NotesAnkifyGUI) handleProcess() {
 		}
 		gui.dimensions.Width = width
 		gui.dimensions.Height = height
+		gui.dimensions.Name = models.GoodNotesStandardPreset.Name
+		if width != models.GoodNotesStandardPreset.Width || height != models.GoodNotesStandardPreset.Height {
+			gui.dimensions.Name = "custom"
+		}
+	}
+
+	var presets []models.PageDimensions
+	for _, name := range gui.presetsCheck.Selected {
+		if preset, ok := models.LookupPreset(name, nil); ok {
+			presets = append(presets, preset)
+		}
 	}
 
 	// Check Anki connection
@@ -391,6 +522,12 @@ func (gui *NotesAnkifyGUI) handleProcess() {
 		return
 	}
 
+	pages, err := pdf.ParsePageSelection(gui.pagesEntry.Text)
+	if err != nil {
+		dialog.ShowError(err, gui.window)
+		return
+	}
+
 	outputDir := gui.outputDirEntry.Text
 
 	// Create processor configuration based on mode
@@ -398,14 +535,48 @@ func (gui *NotesAnkifyGUI) handleProcess() {
 		TempDir:    filepath.Join(os.TempDir(), "notesankify-temp"),
 		OutputDir:  outputDir,
 		Dimensions: gui.dimensions,
+		Presets:    presets,
+		Layout:     gui.layout,
 		ProcessingOptions: pdf.ProcessingOptions{
 			CheckDimensions: gui.processingMode == ModeOnlyDimensions || gui.processingMode == ModeBoth,
 			CheckMarkers:    gui.processingMode == ModeOnlyMarkers || gui.processingMode == ModeBoth,
+			PagePairing:     gui.pagePairing,
+			OutlineDecks:    gui.outlineCheck.Checked,
+			Pages:           pdf.PageRules{{Pages: pages}},
+			// Directive lines are instructions rather than card content, so they are masked.
+			Directives:   pdf.DirectiveOptions{Enabled: gui.directivesCheck.Checked, Mask: true},
+			BlankAnswers: pdf.BlankAnswerOptions{Enabled: gui.unfinished != ""},
+		},
+		Passwords: []pdf.PasswordProvider{
+			pdf.EnvPassword(pdf.PasswordEnvVar),
+			pdf.PasswordFunc(gui.askPassword),
+		},
+		PostProcessing: pdf.PostProcessingOptions{
+			EraseMarkers:   gui.eraseMarkersCheck.Checked,
+			TrimWhitespace: gui.trimCheck.Checked,
+			TrimPadding:    20,
 		},
 		Logger: gui.log,
 	}
 
-	var err error
+	if gui.scanCheck.Checked {
+		config.Scan = pdf.AllScanSteps()
+	}
+
+	// A malformed PDF then only fails its own pages instead of closing the app.
+	if gui.isolateCheck.Checked {
+		if executable, err := os.Executable(); err == nil {
+			config.Worker = pdf.WorkerOptions{
+				Enabled:     true,
+				Command:     []string{executable, pdf.WorkerSubcommand},
+				PageTimeout: pdf.DefaultPageTimeout,
+				FileTimeout: pdf.DefaultFileTimeout,
+			}
+		} else {
+			gui.log.Info("Rendering in process, the executable was not found: %v", err)
+		}
+	}
+
 	gui.processor, err = pdf.NewProcessor(config)
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("failed to initialize processor: %v", err), gui.window)
@@ -418,6 +589,88 @@ func (gui *NotesAnkifyGUI) handleProcess() {
 	go gui.processFiles()
 }
 
+func (gui *NotesAnkifyGUI) handleDetectDimensions() {
+	if gui.dirEntry.Text == "" {
+		dialog.ShowError(fmt.Errorf("please select a PDF directory first"), gui.window)
+		return
+	}
+
+	gui.progress.Show()
+	gui.updateStatus("Surveying page sizes...")
+
+	go gui.detectDimensions(gui.dirEntry.Text)
+}
+
+// detectDimensions clusters the page sizes under dir and lets the user pick one,
+// using the same survey as `notesankify survey`.
+func (gui *NotesAnkifyGUI) detectDimensions(dir string) {
+	defer func() {
+		gui.mutex.Lock()
+		gui.progress.Hide()
+		gui.mutex.Unlock()
+	}()
+
+	pdfs, err := gui.scanner.FindPDFs(context.Background(), dir)
+	if err != nil {
+		gui.showError(fmt.Sprintf("Error finding PDFs: %v", err))
+		return
+	}
+
+	clusters, err := pdf.SurveyPDFs(context.Background(), pdfs, gui.log)
+	if err != nil {
+		gui.showError(fmt.Sprintf("Error surveying PDFs: %v", err))
+		return
+	}
+	if len(clusters) == 0 {
+		gui.updateStatus("No pages found")
+		return
+	}
+
+	gui.updateStatus(fmt.Sprintf("Found %d page sizes in %d PDFs", len(clusters), len(pdfs)))
+	gui.showSurveyDialog(clusters)
+}
+
+func (gui *NotesAnkifyGUI) showSurveyDialog(clusters []pdf.PageSizeCluster) {
+	gui.mutex.Lock()
+	defer gui.mutex.Unlock()
+
+	options := make([]string, len(clusters))
+	suggested := ""
+	for i, cluster := range clusters {
+		label := fmt.Sprintf("%.2f x %.2f pt: %d pages in %d files", cluster.Width, cluster.Height, cluster.PageCount, len(cluster.Files))
+		if cluster.HasMarkers() {
+			label += fmt.Sprintf(", %d with QUESTION/ANSWER", cluster.MarkerPages)
+		}
+		if preset, ok := cluster.KnownPreset(nil); ok {
+			label += fmt.Sprintf(" (%s)", preset.Name)
+		}
+		if cluster.HasMarkers() && suggested == "" {
+			suggested = label
+		}
+		options[i] = label
+	}
+
+	choices := widget.NewRadioGroup(options, nil)
+	if suggested != "" {
+		choices.SetSelected(suggested)
+	}
+
+	dialog.ShowCustomConfirm("Page sizes in your notes", "Use this size", "Cancel",
+		container.NewVBox(widget.NewLabel("Sizes with QUESTION/ANSWER pages are most likely your flashcards:"), choices),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			for i, option := range options {
+				if option == choices.Selected {
+					gui.widthEntry.SetText(fmt.Sprintf("%.2f", clusters[i].Width))
+					gui.heightEntry.SetText(fmt.Sprintf("%.2f", clusters[i].Height))
+					gui.log.Info("Using detected page size %.2f x %.2f", clusters[i].Width, clusters[i].Height)
+				}
+			}
+		}, gui.window)
+}
+
 func (gui *NotesAnkifyGUI) showError(message string) {
 	gui.mutex.Lock()
 	defer gui.mutex.Unlock()
@@ -427,6 +680,22 @@ func (gui *NotesAnkifyGUI) showError(message string) {
 	gui.status.SetText("Error occurred during processing")
 }
 
+// askPassword shows a password dialog for an encrypted PDF and waits for the answer.
+// It must not be called from the UI goroutine.
+func (gui *NotesAnkifyGUI) askPassword(pdfPath string) (string, bool) {
+	entry := widget.NewPasswordEntry()
+	answers := make(chan bool, 1)
+	dialog.ShowForm("Password required", "Open", "Skip",
+		[]*widget.FormItem{widget.NewFormItem(filepath.Base(pdfPath), entry)},
+		func(open bool) { answers <- open },
+		gui.window)
+
+	if !<-answers || entry.Text == "" {
+		return "", false
+	}
+	return entry.Text, true
+}
+
 func (gui *NotesAnkifyGUI) updateStatus(message string) {
 	gui.mutex.Lock()
 	defer gui.mutex.Unlock()
@@ -468,12 +737,69 @@ func (gui *NotesAnkifyGUI) showCompletionDialog(report *anki.ProcessingReport) {
 		}
 	}
 
+	if len(report.MovedNotes) > 0 {
+		gui.log.Info("\nNotes in another deck than their PDF:")
+		for _, note := range report.MovedNotes {
+			gui.log.Info("- %s", note.Summary())
+		}
+	}
+
+	if len(report.UnfinishedCards) > 0 {
+		gui.log.Info("\nUnfinished cards (blank answers):")
+		for _, card := range report.UnfinishedCards {
+			gui.log.Info("- %s (Page %d, Hash:%s): %s", card.DeckName, card.PageNumber, card.Hash, card.Action)
+		}
+	}
+
+	if len(report.PresetMatches) > 0 {
+		gui.log.Info("\nMatched page sizes:")
+		for _, match := range report.PresetMatches {
+			gui.log.Info("- %s (Page %d): %s", match.FilePath, match.PageNumber, match.Preset)
+		}
+	}
+
+	skippedFiles, skippedByFile := report.SkippedPagesByFile()
+	if len(skippedFiles) > 0 {
+		gui.log.Info("\nPages not fully turned into cards:")
+		for _, file := range skippedFiles {
+			for _, decision := range skippedByFile[file] {
+				gui.log.Info("- %s (Page %d): %s", file, decision.PageNumber, decision.Summary())
+			}
+		}
+	}
+
+	if len(report.RenderFailures) > 0 {
+		gui.log.Info("\nRender failures:")
+		for _, failure := range report.RenderFailures {
+			gui.log.Info("- %s: %s", failure.Location(), failure.Reason)
+		}
+	}
+
+	if len(report.EncryptedPDFs) > 0 {
+		gui.log.Info("\nEncrypted PDFs skipped:")
+		for _, file := range report.EncryptedPDFs {
+			gui.log.Info("- %s: %s", file.FilePath, file.Reason)
+		}
+	}
+
+	if len(report.UnpairedPages) > 0 {
+		gui.log.Info("\nUnpaired pages:")
+		for _, page := range report.UnpairedPages {
+			gui.log.Info("- %s (Page %d)", page.FilePath, page.PageNumber)
+		}
+	}
+
 	message := fmt.Sprintf(
 		"Processing Complete!\n\n"+
 			"PDFs Processed: %d\n"+
 			"Total Flashcards: %d\n"+
 			"Cards Added: %d\n"+
 			"Cards Skipped: %d\n"+
+			"Notes In Another Deck: %d\n"+
+			"Unfinished Cards: %d\n"+
+			"Unpaired Pages: %d\n"+
+			"Encrypted PDFs Skipped: %d\n"+
+			"Render Failures: %d\n"+
 			"Time Taken: %v\n"+
 			"Output directory: %s\n\n"+
 			"Log file saved to: %s",
@@ -481,6 +807,11 @@ func (gui *NotesAnkifyGUI) showCompletionDialog(report *anki.ProcessingReport) {
 		report.TotalFlashcards,
 		report.AddedCount,
 		report.SkippedCount,
+		len(report.MovedNotes),
+		len(report.UnfinishedCards),
+		len(report.UnpairedPages),
+		len(report.EncryptedPDFs),
+		len(report.RenderFailures),
 		report.TimeTaken(),
 		gui.outputDirEntry.Text,
 		gui.logFileName,
@@ -515,10 +846,11 @@ func (gui *NotesAnkifyGUI) showCompletionDialog(report *anki.ProcessingReport) {
 		}),
 	)
 
-	content := container.NewVBox(
-		messageLabel,
-		buttonContainer,
-	)
+	content := container.NewVBox(messageLabel)
+	if len(skippedFiles) > 0 {
+		content.Add(gui.skippedPagesAccordion(skippedFiles, skippedByFile))
+	}
+	content.Add(buttonContainer)
 
 	customDialog := dialog.NewCustom("Processing Complete", "Close", content, gui.window)
 	customDialog.Resize(fyne.NewSize(500, 0))
@@ -576,6 +908,26 @@ func (gui *NotesAnkifyGUI) handleModeChange(selected string) {
 	}
 }
 
+// skippedPagesAccordion lists, per file, why pages did not become cards.
+func (gui *NotesAnkifyGUI) skippedPagesAccordion(files []string, byFile map[string][]pdf.PageDecision) fyne.CanvasObject {
+	accordion := widget.NewAccordion()
+	for _, file := range files {
+		var lines []string
+		for _, decision := range byFile[file] {
+			lines = append(lines, fmt.Sprintf("Page %d: %s", decision.PageNumber, decision.Summary()))
+		}
+		details := widget.NewLabel(strings.Join(lines, "\n"))
+		details.Wrapping = fyne.TextWrapWord
+
+		title := fmt.Sprintf("%s: %d pages not turned into cards", file, len(byFile[file]))
+		accordion.Append(widget.NewAccordionItem(title, details))
+	}
+
+	scroll := container.NewVScroll(accordion)
+	scroll.SetMinSize(fyne.NewSize(0, 200))
+	return widget.NewCard("", "Why pages were skipped", scroll)
+}
+
 func (gui *NotesAnkifyGUI) processFiles() {
 	defer func() {
 		gui.mutex.Lock()
@@ -586,6 +938,24 @@ func (gui *NotesAnkifyGUI) processFiles() {
 	report := &anki.ProcessingReport{
 		StartTime: time.Now(),
 	}
+	defer gui.processor.Cleanup()
+	gui.ankiService.SetPageLinks(gui.pageLinksCheck.Checked)
+	if gui.moveNotesCheck.Checked {
+		gui.ankiService.SetMovePolicy(anki.MoveNotes)
+	} else {
+		gui.ankiService.SetMovePolicy(anki.MoveReport)
+	}
+	gui.ankiService.SetUnfinishedPolicy(gui.unfinished)
+	if store, err := state.Open(state.DefaultPath()); err != nil {
+		gui.log.Info("Not remembering note sources: %v", err)
+	} else {
+		gui.ankiService.SetState(store)
+		defer func() {
+			if err := store.Save(); err != nil {
+				gui.log.Info("Error saving state: %v", err)
+			}
+		}()
+	}
 
 	pdfs, err := gui.scanner.FindPDFs(context.Background(), gui.dirEntry.Text)
 	if err != nil {
@@ -599,24 +969,38 @@ func (gui *NotesAnkifyGUI) processFiles() {
 		report.ProcessedPDFs++
 		gui.updateStatus(fmt.Sprintf("Processing: %s", pdf.RelativePath))
 
-		stats, err := gui.processor.ProcessPDF(context.Background(), pdf.AbsolutePath)
+		fileProcessor := gui.processor.WithOverrides(pdf.Overrides)
+		stats, err := fileProcessor.ProcessPDF(context.Background(), pdf.AbsolutePath)
+		report.AddRenderFailures(pdf.RelativePath, stats.RenderFailures)
 		if err != nil {
+			if report.RecordEncryptedPDF(pdf.RelativePath, err) {
+				gui.log.Info("Skipping encrypted PDF %s: %v", pdf.RelativePath, err)
+				continue
+			}
 			gui.showError(fmt.Sprintf("Error processing %s: %v", pdf.RelativePath, err))
 			continue
 		}
+		report.AddUnpairedPages(pdf.RelativePath, stats.UnpairedPages)
+		report.AddPageSelection(pdf.RelativePath, stats.SelectedPages, stats.PageCount)
+		report.AddPresetMatches(pdf.RelativePath, stats.PagePresets)
+		report.AddPageDecisions(pdf.RelativePath, stats.Decisions)
 
 		if stats.FlashcardCount > 0 {
-			deckName := anki.GetDeckNameFromPath(gui.rootDeckEntry.Text, pdf.RelativePath)
 			report.TotalFlashcards += stats.FlashcardCount
 
-			if err := gui.ankiService.CreateDeck(deckName); err != nil {
-				gui.showError(fmt.Sprintf("Error creating deck %s: %v", deckName, err))
-				continue
-			}
-
-			if err := gui.ankiService.AddAllFlashcards(deckName, stats.ImagePairs, stats.PageNumbers, report); err != nil {
-				gui.showError(fmt.Sprintf("Error adding flashcards to deck %s: %v", deckName, err))
-				continue
+			deckPath := anki.DeckPath(pdf.DeckPath, stats.Metadata, pdf.Overrides.UseTitle(gui.titleDecksCheck.Checked))
+			tags := append(anki.MetadataTags(stats.Metadata), anki.NormalizeTags(pdf.Overrides.Tags)...)
+			source := anki.SourceFile{RelativePath: pdf.RelativePath, AbsolutePath: pdf.AbsolutePath}
+			for _, deck := range anki.GroupCardsByDeck(gui.rootDeckEntry.Text, deckPath, stats.ImagePairs, stats.PageNumbers) {
+				if err := gui.ankiService.CreateDeck(deck.DeckName); err != nil {
+					gui.showError(fmt.Sprintf("Error creating deck %s: %v", deck.DeckName, err))
+					continue
+				}
+
+				if err := gui.ankiService.WithModel(pdf.Overrides.NoteModel).AddAllFlashcards(deck.DeckName, source, deck.Pairs, deck.PageNumbers, report, tags...); err != nil {
+					gui.showError(fmt.Sprintf("Error adding flashcards to deck %s: %v", deck.DeckName, err))
+					continue
+				}
 			}
 		}
 	}
@@ -772,6 +1156,14 @@ func (gui *NotesAnkifyGUI) Run() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == pdf.WorkerSubcommand {
+		if err := pdf.RunWorker(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "render worker: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	gui := NewNotesAnkifyGUI()
 	gui.startUpdateChecker()
 	gui.Run()
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+// runInspectDiff implements `notesankify inspect diff <old.pdf> <new.pdf>`: it compares
+// two PDFs page by page and reports how their cards would be treated on import.
+func runInspectDiff(args []string) {
+	flags := flag.NewFlagSet("inspect diff", flag.ExitOnError)
+	processing := registerProcessingFlags(flags)
+	heatmapDir := flags.String("heatmaps", "", "directory to write diff heatmaps of changed pages to")
+	showText := flags.Bool("text", false, "print both versions of the text of pages whose text changed")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: notesankify inspect diff [flags] <old.pdf> <new.pdf>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	oldPath, newPath := flags.Arg(0), flags.Arg(1)
+
+	log := processing.newLogger()
+	ctx := context.Background()
+
+	cfg := loadInspectConfig(processing, log)
+	renderer := processing.newRenderer(cfg, log)
+	diffs, err := pdf.ComparePDFs(ctx, oldPath, newPath, *heatmapDir, renderer, processing.passwordProviders(cfg, log))
+	if err != nil {
+		log.Fatal("Error comparing PDFs: %v", err)
+	}
+
+	fmt.Printf("Comparing %s with %s\n\n", oldPath, newPath)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PAGE\tSIZE\tTEXT\tPIXELS\tPERCEPTUAL\tHEATMAP")
+	for _, diff := range diffs {
+		switch {
+		case !diff.InNew:
+			fmt.Fprintf(writer, "%d\tonly in old\t\t\t\t\n", diff.PageNumber)
+			continue
+		case !diff.InOld:
+			fmt.Fprintf(writer, "%d\tonly in new\t\t\t\t\n", diff.PageNumber)
+			continue
+		}
+
+		size := "same"
+		if !diff.SameSize() {
+			size = fmt.Sprintf("%.0f x %.0f -> %.0f x %.0f", diff.OldWidth, diff.OldHeight, diff.NewWidth, diff.NewHeight)
+		}
+		pixels := "identical"
+		if !diff.SameHash {
+			pixels = fmt.Sprintf("%.2f%% changed", diff.ChangedPixels*100)
+		}
+		heatmap := diff.Heatmap
+		if heatmap == "" {
+			heatmap = "-"
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%d/64\t%s\n",
+			diff.PageNumber, size, sameOrChanged(diff.SameText), pixels, diff.PerceptualDistance, heatmap)
+	}
+	writer.Flush()
+
+	if *showText {
+		for _, diff := range diffs {
+			if diff.InOld && diff.InNew && !diff.SameText {
+				fmt.Printf("\n--- Page %d old text ---\n%s\n", diff.PageNumber, strings.TrimSpace(diff.OldText))
+				fmt.Printf("--- Page %d new text ---\n%s\n", diff.PageNumber, strings.TrimSpace(diff.NewText))
+			}
+		}
+	}
+
+	oldProcessor, cleanupOld := newInspectProcessor(flags, processing, log)
+	defer cleanupOld()
+	oldStats, err := oldProcessor.ProcessPDF(ctx, oldPath)
+	if err != nil {
+		log.Fatal("Error processing %s: %v", oldPath, err)
+	}
+
+	newProcessor, cleanupNew := newInspectProcessor(flags, processing, log)
+	defer cleanupNew()
+	newStats, err := newProcessor.ProcessPDF(ctx, newPath)
+	if err != nil {
+		log.Fatal("Error processing %s: %v", newPath, err)
+	}
+
+	changes, err := pdf.ClassifyCards(oldStats.ImagePairs, newStats.ImagePairs)
+	if err != nil {
+		log.Fatal("Error comparing cards: %v", err)
+	}
+	printCardChanges(changes)
+}
+
+func printCardChanges(changes []pdf.CardChange) {
+	counts := make(map[pdf.CardStatus]int)
+	for _, change := range changes {
+		counts[change.Status]++
+	}
+	fmt.Printf("\nCards: %d new, %d updated, %d unchanged, %d removed\n",
+		counts[pdf.CardNew], counts[pdf.CardUpdated], counts[pdf.CardUnchanged], counts[pdf.CardRemoved])
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, change := range changes {
+		switch change.Status {
+		case pdf.CardNew:
+			fmt.Fprintf(writer, "  new\t%s\t\n", cardLocation(change.Card))
+		case pdf.CardUpdated:
+			fmt.Fprintf(writer, "  updated\t%s\twas %s, perceptual distance %d\n",
+				cardLocation(change.Card), cardLocation(*change.Previous), change.Distance)
+		case pdf.CardRemoved:
+			fmt.Fprintf(writer, "  removed\t%s\t\n", cardLocation(change.Card))
+		}
+	}
+	writer.Flush()
+}
+
+func cardLocation(card pdf.ImagePair) string {
+	location := fmt.Sprintf("page %d", card.Source.PageNumber)
+	if card.Source.CellIndex > 0 {
+		location += fmt.Sprintf(" cell %d", card.Source.CellIndex+1)
+	}
+	return location
+}
+
+func sameOrChanged(same bool) string {
+	if same {
+		return "same"
+	}
+	return "changed"
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kpauljoseph/notesankify/internal/config"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+)
+
+// runInspect implements `notesankify inspect <file.pdf>`: it runs the processor on a
+// single file without touching Anki and explains the decision made for every page.
+func runInspect(args []string) {
+	if len(args) > 0 && args[0] == "diff" {
+		runInspectDiff(args[1:])
+		return
+	}
+
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	processing := registerProcessingFlags(flags)
+	showText := flags.Bool("text", false, "print the extracted text of every page")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: notesankify inspect [flags] <file.pdf>")
+		fmt.Fprintln(flags.Output(), "       notesankify inspect diff [flags] <old.pdf> <new.pdf>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	pdfPath := flags.Arg(0)
+
+	log := processing.newLogger()
+	processor, cleanup := newInspectProcessor(flags, processing, log)
+	defer cleanup()
+
+	stats, err := processor.ProcessPDF(context.Background(), pdfPath)
+	if err != nil {
+		log.Fatal("Error processing %s: %v", pdfPath, err)
+	}
+
+	fmt.Printf("%s: %d pages, %d cards\n", pdfPath, stats.PageCount, stats.FlashcardCount)
+	if stats.SelectedPages != "" {
+		fmt.Printf("Selected pages: %s\n", stats.SelectedPages)
+	}
+	if stats.Metadata.Title != "" {
+		fmt.Printf("Title: %s\n", stats.Metadata.Title)
+	}
+	if len(stats.Metadata.Keywords) > 0 {
+		fmt.Printf("Keywords: %s\n", strings.Join(stats.Metadata.Keywords, ", "))
+	}
+	fmt.Println()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PAGE\tSIZE (PT)\tPRESET\tMARKERS\tOUTCOME\tDETAIL")
+	for _, decision := range stats.Decisions {
+		preset := decision.Preset
+		if preset == "" {
+			preset = "-"
+		}
+		detail := decision.Reason
+		if decision.Scanned {
+			detail = strings.TrimPrefix(detail+"; scanned page", "; ")
+		}
+		fmt.Fprintf(writer, "%d\t%.2f x %.2f\t%s\t%s\t%s\t%s\n",
+			decision.PageNumber, decision.Width, decision.Height, preset, decision.Markers(), outcomeLabel(decision), detail)
+	}
+	writer.Flush()
+
+	if *showText {
+		printPageText(processor, pdfPath)
+	}
+}
+
+// newInspectProcessor builds a processor from the config file and flags. A missing
+// config file is not an error, and card images go to a scratch directory that
+// cleanup removes unless -output-dir is given.
+func newInspectProcessor(flags *flag.FlagSet, processing *processingFlags, log *logger.Logger) (*pdf.Processor, func()) {
+	processorConfig := processing.processorConfig(loadInspectConfig(processing, log), log)
+	scratchDir := ""
+	if !isFlagSet(flags, "output-dir") {
+		var err error
+		scratchDir, err = os.MkdirTemp("", "notesankify-inspect-*")
+		if err != nil {
+			log.Fatal("Error creating scratch directory: %v", err)
+		}
+		processorConfig.OutputDir = scratchDir
+	}
+	processorConfig.TempDir = filepath.Join(os.TempDir(), "notesankify-inspect-temp")
+
+	processor, err := pdf.NewProcessor(processorConfig)
+	if err != nil {
+		log.Fatal("Error initializing processor: %v", err)
+	}
+
+	return processor, func() {
+		processor.Cleanup()
+		if scratchDir != "" {
+			os.RemoveAll(scratchDir)
+		}
+	}
+}
+
+// loadInspectConfig loads the config file, treating a missing file as an empty config.
+func loadInspectConfig(processing *processingFlags, log *logger.Logger) *config.Config {
+	cfg, err := config.Load(*processing.configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Fatal("Error loading config: %v", err)
+		}
+		cfg = &config.Config{}
+	}
+	return cfg
+}
+
+func outcomeLabel(decision pdf.PageDecision) string {
+	if decision.Outcome == pdf.PageCard && decision.Cards > 1 {
+		return fmt.Sprintf("%s x%d", decision.Outcome, decision.Cards)
+	}
+	return string(decision.Outcome)
+}
+
+func printPageText(processor *pdf.Processor, pdfPath string) {
+	doc, err := processor.OpenDocument(pdfPath)
+	if err != nil {
+		fmt.Printf("\nError opening %s: %v\n", pdfPath, err)
+		return
+	}
+	defer doc.Close()
+
+	for pageIndex := 0; pageIndex < doc.NumPage(); pageIndex++ {
+		fmt.Printf("\n--- Page %d text ---\n", pageIndex+1)
+		text, err := doc.Text(pageIndex)
+		if err != nil {
+			fmt.Printf("Error extracting text: %v\n", err)
+			continue
+		}
+		fmt.Println(strings.TrimSpace(text))
+	}
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kpauljoseph/notesankify/internal/anki"
+	"github.com/kpauljoseph/notesankify/internal/config"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/state"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+)
+
+// runLocate implements `notesankify locate <hash-or-note-id>`: it finds the PDF page a
+// card came from, in the local state or else in the note's fields in Anki.
+func runLocate(args []string) {
+	flags := flag.NewFlagSet("locate", flag.ExitOnError)
+	processing := registerProcessingFlags(flags)
+	statePath := flags.String("state", "", "file remembering the source of every added note (overrides config)")
+	noAnki := flags.Bool("no-anki", false, "only look in the state file, without asking Anki")
+	openPDF := flags.Bool("open", false, "open the PDF with the system viewer")
+	viewer := flags.String("viewer", "", "command used by -open instead of the system viewer; {file} and {page} are replaced, e.g. \"evince --page-label={page} {file}\"")
+	renderPath := flags.String("render", "", "render the card's page to this PNG file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: notesankify locate [flags] <hash-or-note-id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	query := strings.TrimSpace(flags.Arg(0))
+
+	log := processing.newLogger()
+	cfg := loadInspectConfig(processing, log)
+
+	foundIn := "state file"
+	card, found := openState(cfg, *statePath, log).Lookup(query)
+	if !found {
+		if *noAnki {
+			log.Fatal("No card %s in the state file", query)
+		}
+		ankiService := anki.NewService(log)
+		if err := ankiService.CheckConnection(); err != nil {
+			log.Fatal("No card %s in the state file, and Anki is not reachable: %v", query, err)
+		}
+		var err error
+		card, err = ankiService.LocateNote(query)
+		if err != nil {
+			log.Fatal("Error locating %s: %v", query, err)
+		}
+		foundIn = "Anki"
+	}
+
+	printLocation(card, foundIn)
+	if _, err := os.Stat(card.SourcePath); err != nil {
+		log.Fatal("The PDF is no longer at %s", card.SourcePath)
+	}
+
+	if *renderPath != "" {
+		renderSourcePage(processing, cfg, card, *renderPath, log)
+		fmt.Printf("Rendered page %d to %s\n", card.PageNumber, *renderPath)
+	}
+	if *openPDF {
+		if err := openSource(card, *viewer); err != nil {
+			log.Fatal("Error opening %s: %v", card.SourcePath, err)
+		}
+	}
+}
+
+func printLocation(card state.Card, foundIn string) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "File:\t%s\n", card.SourcePath)
+	fmt.Fprintf(writer, "Page:\t%d\n", card.PageNumber)
+	if card.CellIndex > 0 {
+		fmt.Fprintf(writer, "Card on page:\t%d\n", card.CellIndex+1)
+	}
+	if card.DeckName != "" {
+		fmt.Fprintf(writer, "Deck:\t%s\n", card.DeckName)
+	}
+	if card.NoteID != 0 {
+		fmt.Fprintf(writer, "Note ID:\t%d\n", card.NoteID)
+	}
+	fmt.Fprintf(writer, "Hash:\t%s\n", card.Hash)
+	fmt.Fprintf(writer, "Found in:\t%s\n", foundIn)
+	writer.Flush()
+}
+
+// renderSourcePage writes the card's page as a PNG at the resolution cards use.
+func renderSourcePage(processing *processingFlags, cfg *config.Config, card state.Card, outputPath string, log *logger.Logger) {
+	doc, err := processing.newRenderer(cfg, log).Open(card.SourcePath, processing.passwordProviders(cfg, log))
+	if err != nil {
+		log.Fatal("Error opening %s: %v", card.SourcePath, err)
+	}
+	defer doc.Close()
+
+	if card.PageNumber < 1 || card.PageNumber > doc.NumPage() {
+		log.Fatal("%s has no page %d", card.SourcePath, card.PageNumber)
+	}
+	img, err := doc.ImageDPI(card.PageNumber-1, pdf.RenderDPI)
+	if err != nil {
+		log.Fatal("Error rendering page %d: %v", card.PageNumber, err)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		log.Fatal("Error creating %s: %v", outputPath, err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		log.Fatal("Error writing %s: %v", outputPath, err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal("Error writing %s: %v", outputPath, err)
+	}
+}
+
+// openSource opens the card's PDF with the viewer command or the system viewer,
+// which cannot be told the page.
+func openSource(card state.Card, viewer string) error {
+	if viewer != "" {
+		fields := strings.Fields(viewer)
+		replacer := strings.NewReplacer("{file}", card.SourcePath, "{page}", strconv.Itoa(card.PageNumber))
+		for i, field := range fields {
+			fields[i] = replacer.Replace(field)
+		}
+		return exec.Command(fields[0], fields[1:]...).Start()
+	}
+
+	fmt.Printf("Opening %s, the card is on page %d\n", card.SourcePath, card.PageNumber)
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("cmd", "/c", "start", "", card.SourcePath).Start()
+	case "darwin":
+		return exec.Command("open", card.SourcePath).Start()
+	default:
+		return exec.Command("xdg-open", card.SourcePath).Start()
+	}
+}
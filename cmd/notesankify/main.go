@@ -8,26 +8,40 @@ import (
 	"github.com/kpauljoseph/notesankify/internal/config"
 	"github.com/kpauljoseph/notesankify/internal/pdf"
 	"github.com/kpauljoseph/notesankify/internal/scanner"
-	"github.com/kpauljoseph/notesankify/pkg/logger"
-	"github.com/kpauljoseph/notesankify/pkg/models"
-	"github.com/kpauljoseph/notesankify/pkg/utils"
 	"github.com/kpauljoseph/notesankify/pkg/version"
 	"os"
-	"path/filepath"
 	"time"
 )
 
 func main() {
-	configPath := flag.String("config", "config.yaml", "path to config file")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "survey":
+			runSurvey(os.Args[2:])
+			return
+		case "inspect":
+			runInspect(os.Args[2:])
+			return
+		case "locate":
+			runLocate(os.Args[2:])
+			return
+		case pdf.WorkerSubcommand:
+			if err := pdf.RunWorker(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "render worker: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	flags := registerProcessingFlags(flag.CommandLine)
 	pdfDir := flag.String("pdf-dir", "", "directory containing PDF files (overrides config)")
-	outputDir := flag.String("output-dir", utils.GetDefaultOutputDir(), "directory to save processed flashcards")
 	rootDeckName := flag.String("root-deck", "", "root deck name for organizing flashcards (optional)")
-	verbose := flag.Bool("verbose", false, "enable verbose logging")
-	debug := flag.Bool("debug", false, "enable debug mode with trace logging")
-	width := flag.Float64("width", 0.0, "custom flashcard width (defaults to Goodnotes standard if not specified)")
-	height := flag.Float64("height", 0.0, "custom flashcard height (defaults to Goodnotes standard if not specified)")
-	disableMarkerCheck := flag.Bool("no-markers", false, "disable checking for QUESTION/ANSWER markers in pages")
-	disableDimensionCheck := flag.Bool("no-dimensions", false, "disable checking page dimensions")
+	titleDecks := flag.Bool("title-decks", false, "name decks after the PDF's Title metadata instead of its file name, where set")
+	pageLinks := flag.Bool("page-links", false, "link cards to their page in the source PDF (#page=N) rather than to the file")
+	statePath := flag.String("state", "", "file remembering the source of every added note (overrides config)")
+	movedNotes := flag.String("moved-notes", "", "what to do with existing notes in another deck than their PDF's path gives: keep, report or move (overrides config)")
+	unfinished := flag.String("unfinished", "", "what to do with cards whose answer is still blank: skip, suspend or tag (overrides config)")
 	versionFlag := flag.Bool("version", false, "Print version information")
 
 	flag.Parse()
@@ -41,20 +55,7 @@ func main() {
 		StartTime: time.Now(),
 	}
 
-	logOptions := []logger.Option{
-		logger.WithPrefix("[notesankify] "),
-	}
-
-	log := logger.New(logOptions...)
-	log.SetVerbose(*verbose)
-
-	if *debug {
-		log.SetLevel(logger.LevelTrace)
-	}
-
-	if *verbose {
-		log.Debug("Verbose logging enabled")
-	}
+	log := flags.newLogger()
 
 	// TODO: Add cleanup to account for termination case
 	//ctx, cancel := context.WithCancel(context.Background())
@@ -67,7 +68,7 @@ func main() {
 	//	cancel()
 	//}()
 
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.Load(*flags.configPath)
 	if err != nil {
 		log.Fatal("Error loading config: %v", err)
 	}
@@ -76,34 +77,23 @@ func main() {
 		cfg.PDFSourceDir = *pdfDir
 	}
 
-	// Set up dimensions
-	dimensions := models.PageDimensions{
-		Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
-		Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
-	}
-
-	if *width > 0 && *height > 0 {
-		dimensions.Width = *width
-		dimensions.Height = *height
-		log.Debug("Using custom dimensions: %.2f x %.2f", *width, *height)
-	} else {
-		log.Debug("Using default standard size dimensions: %.2f x %.2f",
-			dimensions.Width, dimensions.Height)
-	}
-
 	if _, err := os.Stat(cfg.PDFSourceDir); os.IsNotExist(err) {
 		log.Fatal("PDF directory does not exist: %s", cfg.PDFSourceDir)
 	}
 
-	processorConfig := pdf.ProcessorConfig{
-		TempDir:    filepath.Join(os.TempDir(), "notesankify-temp"),
-		OutputDir:  *outputDir,
-		Dimensions: dimensions,
-		ProcessingOptions: pdf.ProcessingOptions{
-			CheckDimensions: !*disableDimensionCheck, // Enabled by default
-			CheckMarkers:    !*disableMarkerCheck,    // Enabled by default
-		},
-		Logger: log,
+	processorConfig := flags.processorConfig(cfg, log)
+
+	unfinishedPolicyName := cfg.UnfinishedCards.Policy
+	if *unfinished != "" {
+		unfinishedPolicyName = *unfinished
+	}
+	unfinishedPolicy, err := anki.ParseUnfinishedPolicy(unfinishedPolicyName)
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	processorConfig.BlankAnswers = pdf.BlankAnswerOptions{
+		Enabled:   unfinishedPolicy != "",
+		Threshold: cfg.UnfinishedCards.Threshold,
 	}
 
 	processor, err := pdf.NewProcessor(processorConfig)
@@ -135,6 +125,26 @@ func main() {
 
 	// Initialize and check Anki connection
 	ankiService := anki.NewService(log)
+	ankiService.SetPageLinks(cfg.SourceLinks.PageAnchor || *pageLinks)
+
+	movePolicyName := cfg.MovedNotes
+	if *movedNotes != "" {
+		movePolicyName = *movedNotes
+	}
+	movePolicy, err := anki.ParseMovePolicy(movePolicyName)
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	ankiService.SetMovePolicy(movePolicy)
+	ankiService.SetUnfinishedPolicy(unfinishedPolicy)
+
+	store := openState(cfg, *statePath, log)
+	ankiService.SetState(store)
+	defer func() {
+		if err := store.Save(); err != nil {
+			log.Info("Error saving state: %v", err)
+		}
+	}()
 
 	log.Debug("Checking Anki connection...")
 	if err := ankiService.CheckConnection(); err != nil {
@@ -144,26 +154,50 @@ func main() {
 
 	for _, pdf := range pdfs {
 		report.ProcessedPDFs++
-		stats, err := processor.ProcessPDF(context.Background(), pdf.AbsolutePath)
+		fileProcessor := processor.WithOverrides(pdf.Overrides)
+		stats, err := fileProcessor.ProcessPDF(context.Background(), pdf.AbsolutePath)
+		report.AddRenderFailures(pdf.RelativePath, stats.RenderFailures)
 		if err != nil {
+			if report.RecordEncryptedPDF(pdf.RelativePath, err) {
+				log.Info("Skipping encrypted PDF %s: %v", pdf.RelativePath, err)
+				continue
+			}
 			log.Info("Error processing %s: %v", pdf.RelativePath, err)
 			continue
 		}
 
+		report.AddPageSelection(pdf.RelativePath, stats.SelectedPages, stats.PageCount)
+		report.AddPresetMatches(pdf.RelativePath, stats.PagePresets)
+		report.AddPageDecisions(pdf.RelativePath, stats.Decisions)
+
+		if len(stats.UnpairedPages) > 0 {
+			log.Info("Unpaired pages in %s: %v", pdf.RelativePath, stats.UnpairedPages)
+			report.AddUnpairedPages(pdf.RelativePath, stats.UnpairedPages)
+		}
+
 		if stats.FlashcardCount > 0 {
-			deckName := anki.GetDeckNameFromPath(*rootDeckName, pdf.RelativePath)
 			log.Info("Found %d flashcards in %s", stats.FlashcardCount, pdf.RelativePath)
 			report.TotalFlashcards += stats.FlashcardCount
 
-			if err := ankiService.CreateDeck(deckName); err != nil {
-				log.Info("Error creating deck %s: %v", deckName, err)
-				continue
+			deckPath := anki.DeckPath(pdf.DeckPath, stats.Metadata, pdf.Overrides.UseTitle(cfg.DeckNames.UseTitle || *titleDecks))
+			tags := anki.MetadataTags(stats.Metadata)
+			tags = append(tags, anki.NormalizeTags(append(cfg.Tags, pdf.Overrides.Tags...))...)
+			source := anki.SourceFile{RelativePath: pdf.RelativePath, AbsolutePath: pdf.AbsolutePath}
+			noteModel := pdf.Overrides.NoteModel
+			if noteModel == "" {
+				noteModel = cfg.NoteModel
 			}
-			log.Debug("Created/Updated deck: %s", deckName)
-
-			if err := ankiService.AddAllFlashcards(deckName, stats.ImagePairs, stats.PageNumbers, report); err != nil {
-				log.Info("Error adding flashcards to deck %s: %v", deckName, err)
-				continue
+			for _, deck := range anki.GroupCardsByDeck(*rootDeckName, deckPath, stats.ImagePairs, stats.PageNumbers) {
+				if err := ankiService.CreateDeck(deck.DeckName); err != nil {
+					log.Info("Error creating deck %s: %v", deck.DeckName, err)
+					continue
+				}
+				log.Debug("Created/Updated deck: %s", deck.DeckName)
+
+				if err := ankiService.WithModel(noteModel).AddAllFlashcards(deck.DeckName, source, deck.Pairs, deck.PageNumbers, report, tags...); err != nil {
+					log.Info("Error adding flashcards to deck %s: %v", deck.DeckName, err)
+					continue
+				}
 			}
 		}
 	}
@@ -171,7 +205,7 @@ func main() {
 	log.Info("Processing complete:")
 	log.Info("- Total PDFs processed: %d", len(pdfs))
 	log.Info("- Total flashcards found: %d", report.TotalFlashcards)
-	log.Info("- Flashcards saved to: %s", *outputDir)
+	log.Info("- Flashcards saved to: %s", *flags.outputDir)
 
 	report.EndTime = time.Now()
 	report.Print(log)
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kpauljoseph/notesankify/internal/config"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/state"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+	"golang.org/x/term"
+)
+
+// processingFlags are the flags shared by every command that runs the page processor.
+type processingFlags struct {
+	configPath            *string
+	outputDir             *string
+	verbose               *bool
+	debug                 *bool
+	width                 *float64
+	height                *float64
+	disableMarkerCheck    *bool
+	disableDimensionCheck *bool
+	presetNames           *string
+	layoutName            *string
+	splitDirection        *string
+	pairPages             *string
+	eraseMarkers          *bool
+	trimWhitespace        *bool
+	scanned               *bool
+	debugOverlays         *bool
+	ocr                   *bool
+	outlineDecks          *bool
+	outlineDepth          *int
+	directives            *bool
+	pages                 *string
+	noPasswordPrompt      *bool
+	renderer              *string
+	isolate               *bool
+	pageTimeout           *time.Duration
+	fileTimeout           *time.Duration
+	workerMemoryMB        *uint64
+	debugDir              *string
+}
+
+func registerProcessingFlags(flags *flag.FlagSet) *processingFlags {
+	return &processingFlags{
+		configPath:            flags.String("config", "config.yaml", "path to config file"),
+		outputDir:             flags.String("output-dir", utils.GetDefaultOutputDir(), "directory to save processed flashcards"),
+		verbose:               flags.Bool("verbose", false, "enable verbose logging"),
+		debug:                 flags.Bool("debug", false, "enable debug mode with trace logging"),
+		width:                 flags.Float64("width", 0.0, "custom flashcard width (defaults to flashcard_size in the config, else Goodnotes standard)"),
+		height:                flags.Float64("height", 0.0, "custom flashcard height (defaults to flashcard_size in the config, else Goodnotes standard)"),
+		disableMarkerCheck:    flags.Bool("no-markers", false, "disable checking for QUESTION/ANSWER markers in pages"),
+		disableDimensionCheck: flags.Bool("no-dimensions", false, "disable checking page dimensions"),
+		presetNames:           flags.String("presets", "", "comma-separated dimension presets accepted in addition to the flashcard size, e.g. index-3x5,a5 (built-in: "+strings.Join(models.PresetNames(nil), ", ")+")"),
+		layoutName:            flags.String("layout", "", "cards per page layout: single, grid-2, grid-4 or grid-6 (overrides config)"),
+		splitDirection:        flags.String("split", "", "question/answer split: horizontal (top/bottom) or vertical (left/right); defaults by page orientation"),
+		pairPages:             flags.String("pair-pages", "", "build cards from two pages: consecutive (question page followed by answer page, only marked pages unless -no-markers) or markers (QUESTION page followed by ANSWER page)"),
+		eraseMarkers:          flags.Bool("erase-markers", false, "erase the printed QUESTION/ANSWER labels from card images"),
+		trimWhitespace:        flags.Bool("trim-whitespace", false, "crop empty margins around question and answer images"),
+		scanned:               flags.Bool("scanned", false, "clean up image-only pages (deskew, contrast, thresholding, card detection) and match them by aspect ratio"),
+		debugOverlays:         flags.Bool("debug-overlays", false, "write an annotated PNG of every processed page showing markers, split lines and crop regions"),
+		debugDir:              flags.String("debug-dir", "notesankify-debug", "directory for -debug-overlays images"),
+		ocr:                   flags.Bool("ocr", false, "read handwritten markers and card text with OCR (requires tesseract, see the ocr config section)"),
+		outlineDecks:          flags.Bool("outline-decks", false, "file cards into sub-decks named after the PDF's bookmarks"),
+		outlineDepth:          flags.Int("outline-depth", -1, "number of bookmark levels used for -outline-decks sub-decks, 0 for all (overrides config)"),
+		directives:            flags.Bool("directives", false, "apply directives typed on cards: #tag <tags>, DECK: <deck>, !suspend and !flag <color>"),
+		renderer:              flags.String("renderer", "", "PDF rendering backend: fitz (default) or poppler, which requires pdfinfo, pdftotext and pdftoppm and cannot open encrypted PDFs (overrides config)"),
+		pages:                 flags.String("pages", "", "pages to process in every PDF, e.g. 1-10,15,-3.. for pages 1 to 10, 15 and the last three; !N leaves a page out (overrides config)"),
+		isolate:               flags.Bool("isolate", false, "render PDFs in a separate worker process so a malformed file cannot crash the run"),
+		pageTimeout:           flags.Duration("page-timeout", 0, "time limit for rendering one page with -isolate (default 1m, overrides config)"),
+		fileTimeout:           flags.Duration("file-timeout", 0, "time limit for processing one PDF with -isolate (default 10m, overrides config)"),
+		workerMemoryMB:        flags.Uint64("worker-memory", 0, "memory limit of the -isolate worker in MB, Linux and macOS only (overrides config)"),
+		noPasswordPrompt:      flags.Bool("no-password-prompt", false, "skip encrypted PDFs without a configured password instead of asking for one"),
+	}
+}
+
+func (f *processingFlags) newLogger() *logger.Logger {
+	log := logger.New(logger.WithPrefix("[notesankify] "))
+	log.SetVerbose(*f.verbose)
+
+	if *f.debug {
+		log.SetLevel(logger.LevelTrace)
+	}
+
+	if *f.verbose {
+		log.Debug("Verbose logging enabled")
+	}
+	return log
+}
+
+// processorConfig combines the config file with the command line flags. Invalid
+// settings are fatal.
+func (f *processingFlags) processorConfig(cfg *config.Config, log *logger.Logger) pdf.ProcessorConfig {
+	// Set up dimensions: the flags win over flashcard_size, which wins over the
+	// default. The tolerance and layout of flashcard_size apply to either size.
+	dimensions := models.GoodNotesStandardPreset
+	if size := cfg.FlashcardSize; size.Width > 0 && size.Height > 0 &&
+		(size.Width != dimensions.Width || size.Height != dimensions.Height) {
+		dimensions.Name = "custom"
+		dimensions.Width = size.Width
+		dimensions.Height = size.Height
+	}
+
+	if *f.width > 0 && *f.height > 0 {
+		dimensions.Name = "custom"
+		dimensions.Width = *f.width
+		dimensions.Height = *f.height
+	}
+	if dimensions.Name == "custom" {
+		log.Debug("Using custom dimensions: %.2f x %.2f", dimensions.Width, dimensions.Height)
+	} else {
+		log.Debug("Using default standard size dimensions: %.2f x %.2f",
+			dimensions.Width, dimensions.Height)
+	}
+
+	dimensions.Tolerance = cfg.FlashcardSize.Tolerance
+	dimensions.Layout = cfg.FlashcardSize.Layout
+
+	acceptedPresets := cfg.AcceptPresets
+	if *f.presetNames != "" {
+		acceptedPresets = append(acceptedPresets, strings.Split(*f.presetNames, ",")...)
+	}
+	var presets []models.PageDimensions
+	for _, name := range acceptedPresets {
+		preset, ok := models.LookupPreset(name, cfg.Presets)
+		if !ok {
+			log.Fatal("Unknown dimension preset: %s (available: %s)", name, strings.Join(models.PresetNames(cfg.Presets), ", "))
+		}
+		log.Debug("Also accepting %s pages: %.2f x %.2f", preset.Name, preset.Width, preset.Height)
+		presets = append(presets, preset)
+	}
+
+	layout := models.SingleCardLayout
+	if cfg.Layout != nil {
+		layout = *cfg.Layout
+	}
+	if *f.layoutName != "" {
+		profile, ok := models.LookupLayout(*f.layoutName)
+		if !ok {
+			log.Fatal("Unknown layout: %s", *f.layoutName)
+		}
+		profile.Split = layout.Split
+		layout = profile
+	}
+	switch direction := models.SplitDirection(*f.splitDirection); direction {
+	case models.SplitAuto:
+	case models.SplitHorizontal, models.SplitVertical:
+		layout.Split.Direction = direction
+	default:
+		log.Fatal("Unknown split direction: %s", *f.splitDirection)
+	}
+	log.Debug("Using layout %s (%d cards per page)", layout.Name, layout.CellCount())
+
+	pairingMode, err := pdf.ParsePairingMode(*f.pairPages)
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+
+	postProcessing := pdf.PostProcessingOptions{
+		EraseMarkers:        cfg.PostProcessing.EraseMarkers || *f.eraseMarkers,
+		TrimWhitespace:      cfg.PostProcessing.TrimWhitespace || *f.trimWhitespace,
+		TrimPadding:         cfg.PostProcessing.TrimPadding,
+		BackgroundTolerance: cfg.PostProcessing.BackgroundTolerance,
+		BeforeHash:          cfg.PostProcessing.BeforeHash,
+	}
+	for _, value := range cfg.PostProcessing.BackgroundColors {
+		backgroundColor, err := pdf.ParseHexColor(value)
+		if err != nil {
+			log.Fatal("Error in post_processing.background_colors: %v", err)
+		}
+		postProcessing.BackgroundColors = append(postProcessing.BackgroundColors, backgroundColor)
+	}
+
+	scanOptions := pdf.ScanOptions{
+		Enabled:           cfg.Scan.Enabled,
+		Deskew:            cfg.Scan.Deskew,
+		NormalizeContrast: cfg.Scan.NormalizeContrast,
+		Threshold:         cfg.Scan.Threshold,
+		DetectCard:        cfg.Scan.DetectCard,
+		MaxSkewDegrees:    cfg.Scan.MaxSkewDegrees,
+	}
+	if *f.scanned {
+		scanOptions = pdf.AllScanSteps()
+		scanOptions.MaxSkewDegrees = cfg.Scan.MaxSkewDegrees
+	}
+
+	var ocr pdf.OCREngine
+	if cfg.OCR.Enabled || *f.ocr {
+		ocr = newOCREngine(cfg, log)
+	}
+
+	outlineDepth := cfg.OutlineDecks.MaxDepth
+	if *f.outlineDepth >= 0 {
+		outlineDepth = *f.outlineDepth
+	}
+
+	renderer := f.newRenderer(cfg, log)
+	worker := f.workerOptions(cfg, log)
+	if _, ok := renderer.(pdf.FitzRenderer); worker.Enabled && !ok {
+		log.Info("Ignoring the render worker: poppler already renders in separate processes")
+	}
+
+	var debugOverlayDir string
+	if *f.debugOverlays {
+		debugOverlayDir = *f.debugDir
+		log.Info("Writing debug overlays to %s", debugOverlayDir)
+	}
+
+	return pdf.ProcessorConfig{
+		TempDir:    filepath.Join(os.TempDir(), "notesankify-temp"),
+		OutputDir:  *f.outputDir,
+		Dimensions: dimensions,
+		Presets:    presets,
+		Layout:     layout,
+		ProcessingOptions: pdf.ProcessingOptions{
+			CheckDimensions: !*f.disableDimensionCheck, // Enabled by default
+			CheckMarkers:    !*f.disableMarkerCheck,    // Enabled by default
+			PagePairing:     pairingMode,
+			OutlineDecks:    cfg.OutlineDecks.Enabled || *f.outlineDecks,
+			OutlineDepth:    outlineDepth,
+			Pages:           f.pageRules(cfg, log),
+			ExtraRegions:    extraRegions(cfg),
+			Directives: pdf.DirectiveOptions{
+				Enabled: cfg.Directives.Enabled || *f.directives,
+				Mask:    cfg.Directives.Mask,
+			},
+		},
+		PostProcessing:  postProcessing,
+		Scan:            scanOptions,
+		DebugOverlayDir: debugOverlayDir,
+		OCR:             ocr,
+		Passwords:       f.passwordProviders(cfg, log),
+		Renderer:        renderer,
+		Worker:          worker,
+		Logger:          log,
+	}
+}
+
+// extraRegions lists the hint and notes boxes the config file gives markers for.
+func extraRegions(cfg *config.Config) []pdf.ExtraRegion {
+	var regions []pdf.ExtraRegion
+	if cfg.ExtraRegions.Hint != "" {
+		regions = append(regions, pdf.ExtraRegion{Name: models.HintRegion, Marker: cfg.ExtraRegions.Hint})
+	}
+	if cfg.ExtraRegions.Extra != "" {
+		regions = append(regions, pdf.ExtraRegion{Name: models.ExtraRegion, Marker: cfg.ExtraRegions.Extra})
+	}
+	return regions
+}
+
+// pageRules lists the page selections: the -pages flag for every PDF, then the
+// config file's patterns.
+func (f *processingFlags) pageRules(cfg *config.Config, log *logger.Logger) pdf.PageRules {
+	var rules pdf.PageRules
+	if *f.pages != "" {
+		selection, err := pdf.ParsePageSelection(*f.pages)
+		if err != nil {
+			log.Fatal("Error in -pages: %v", err)
+		}
+		rules = append(rules, pdf.PageRule{Pages: selection})
+	}
+
+	for _, entry := range cfg.PageSelection {
+		if _, err := filepath.Match(entry.Pattern, ""); err != nil {
+			log.Fatal("Invalid page selection pattern %s: %v", entry.Pattern, err)
+		}
+		selection, err := pdf.ParsePageSelection(entry.Pages)
+		if err != nil {
+			log.Fatal("Error in page_selection for %s: %v", entry.Pattern, err)
+		}
+		rules = append(rules, pdf.PageRule{Pattern: entry.Pattern, Pages: selection})
+	}
+	return rules
+}
+
+// newRenderer sets up the rendering backend named by the flag or the config file.
+func (f *processingFlags) newRenderer(cfg *config.Config, log *logger.Logger) pdf.Renderer {
+	name := cfg.Renderer.Name
+	if *f.renderer != "" {
+		name = *f.renderer
+	}
+
+	switch name {
+	case "", pdf.RendererFitz:
+		return pdf.FitzRenderer{}
+	case pdf.RendererPoppler:
+		renderer := &pdf.PopplerRenderer{Dir: cfg.Renderer.PopplerDir}
+		if err := renderer.Check(); err != nil {
+			log.Fatal("Poppler not found, install it or set renderer.poppler_dir: %v", err)
+		}
+		log.Debug("Rendering with poppler")
+		return renderer
+	default:
+		log.Fatal("Unknown renderer: %s (available: %s, %s)", name, pdf.RendererFitz, pdf.RendererPoppler)
+		return nil
+	}
+}
+
+// workerOptions sets up the render worker, which runs this executable again with
+// the worker subcommand.
+func (f *processingFlags) workerOptions(cfg *config.Config, log *logger.Logger) pdf.WorkerOptions {
+	options := pdf.WorkerOptions{
+		Enabled:     cfg.Worker.Enabled || *f.isolate,
+		PageTimeout: firstDuration(*f.pageTimeout, cfg.Worker.PageTimeout, pdf.DefaultPageTimeout),
+		FileTimeout: firstDuration(*f.fileTimeout, cfg.Worker.FileTimeout, pdf.DefaultFileTimeout),
+		MemoryLimit: cfg.Worker.MemoryLimitMB << 20,
+	}
+	if *f.workerMemoryMB > 0 {
+		options.MemoryLimit = *f.workerMemoryMB << 20
+	}
+	if !options.Enabled {
+		return options
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatal("Error locating executable for the render worker: %v", err)
+	}
+	options.Command = []string{executable, pdf.WorkerSubcommand}
+	log.Debug("Rendering in a worker process (page timeout %v, file timeout %v)", options.PageTimeout, options.FileTimeout)
+	return options
+}
+
+// firstDuration returns the first of the durations that is set.
+func firstDuration(durations ...time.Duration) time.Duration {
+	for _, duration := range durations {
+		if duration > 0 {
+			return duration
+		}
+	}
+	return 0
+}
+
+// passwordProviders lists the passwords tried on encrypted PDFs: the config file's
+// rules, then the password environment variable, then a prompt when run in a terminal.
+func (f *processingFlags) passwordProviders(cfg *config.Config, log *logger.Logger) []pdf.PasswordProvider {
+	var rules pdf.PasswordRules
+	for _, entry := range cfg.Passwords {
+		if _, err := filepath.Match(entry.Pattern, ""); err != nil {
+			log.Fatal("Invalid password pattern %s: %v", entry.Pattern, err)
+		}
+		password := entry.Password
+		if entry.PasswordEnv != "" {
+			password = os.Getenv(entry.PasswordEnv)
+			if password == "" {
+				log.Info("Environment variable %s for password pattern %s is not set", entry.PasswordEnv, entry.Pattern)
+				continue
+			}
+		}
+		rules = append(rules, pdf.PasswordRule{Pattern: entry.Pattern, Password: password})
+	}
+
+	providers := []pdf.PasswordProvider{rules, pdf.EnvPassword(pdf.PasswordEnvVar)}
+	if !*f.noPasswordPrompt && term.IsTerminal(int(os.Stdin.Fd())) {
+		providers = append(providers, pdf.PasswordFunc(promptPassword))
+	}
+	return providers
+}
+
+// promptPassword asks for a PDF's password without echoing it. An empty answer skips the file.
+func promptPassword(pdfPath string) (string, bool) {
+	fmt.Fprintf(os.Stderr, "Password for %s (empty to skip): ", pdfPath)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil || len(password) == 0 {
+		return "", false
+	}
+	return string(password), true
+}
+
+func newOCREngine(cfg *config.Config, log *logger.Logger) pdf.OCREngine {
+	engine := &pdf.TesseractOCR{Command: cfg.OCR.Command, Language: cfg.OCR.Language}
+	command := engine.Executable()
+	if _, err := exec.LookPath(command); err != nil {
+		log.Fatal("OCR engine %s not found, install it or set ocr.command: %v", command, err)
+	}
+
+	cacheDir := cfg.OCR.CacheDir
+	if cacheDir == "" {
+		cacheDir = pdf.DefaultOCRCacheDir()
+	}
+	cached, err := pdf.NewCachedOCR(engine, cacheDir)
+	if err != nil {
+		log.Fatal("Error setting up OCR: %v", err)
+	}
+	log.Debug("Using OCR command %s, cache in %s", command, cacheDir)
+	return cached
+}
+
+// openState opens the state file named by the flag, the config file or the default.
+func openState(cfg *config.Config, path string, log *logger.Logger) *state.Store {
+	if path == "" {
+		path = cfg.StateFile
+	}
+	if path == "" {
+		path = state.DefaultPath()
+	}
+
+	store, err := state.Open(path)
+	if err != nil {
+		log.Fatal("Error opening state: %v", err)
+	}
+	log.Debug("Using state file %s", path)
+	return store
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kpauljoseph/notesankify/internal/config"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/scanner"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+// runSurvey implements `notesankify survey <dir>`: it clusters the page sizes of
+// every PDF under dir and optionally saves one cluster as a preset in the config.
+func runSurvey(args []string) {
+	flags := flag.NewFlagSet("survey", flag.ExitOnError)
+	configPath := flags.String("config", "config.yaml", "config file to save presets to")
+	save := flags.Int("save", 0, "save the cluster with this number as a preset without prompting")
+	name := flags.String("name", "", "name of the saved preset")
+	verbose := flags.Bool("verbose", false, "enable verbose logging")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: notesankify survey [flags] <dir>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	rootDir := flags.Arg(0)
+
+	log := logger.New(logger.WithPrefix("[notesankify] "))
+	log.SetVerbose(*verbose)
+
+	pdfs, err := scanner.New(log).FindPDFs(context.Background(), rootDir)
+	if err != nil {
+		log.Fatal("Error scanning directory: %v", err)
+	}
+
+	clusters, err := pdf.SurveyPDFs(context.Background(), pdfs, log)
+	if err != nil {
+		log.Fatal("Error surveying PDFs: %v", err)
+	}
+	if len(clusters) == 0 {
+		fmt.Println("No pages found.")
+		return
+	}
+
+	var customPresets []models.PageDimensions
+	if cfg, err := config.Load(*configPath); err == nil {
+		customPresets = cfg.Presets
+	}
+
+	fmt.Printf("Page sizes in %d PDFs under %s:\n\n", len(pdfs), rootDir)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tSIZE (PT)\tPAGES\tFILES\tMARKERS\tPRESET")
+	for i, cluster := range clusters {
+		markers := "-"
+		if cluster.HasMarkers() {
+			markers = fmt.Sprintf("yes (%d pages)", cluster.MarkerPages)
+		}
+		known := "-"
+		if preset, ok := cluster.KnownPreset(customPresets); ok {
+			known = preset.Name
+		}
+		fmt.Fprintf(writer, "%d\t%.2f x %.2f\t%d\t%d\t%s\t%s\n",
+			i+1, cluster.Width, cluster.Height, cluster.PageCount, len(cluster.Files), markers, known)
+	}
+	writer.Flush()
+
+	choice := *save
+	presetName := *name
+	if choice == 0 && isTerminal(os.Stdin) {
+		choice, presetName = promptForPreset(len(clusters), presetName)
+	}
+	if choice == 0 {
+		return
+	}
+	if choice < 1 || choice > len(clusters) {
+		log.Fatal("No cluster numbered %d", choice)
+	}
+	if presetName == "" {
+		log.Fatal("A preset name is required, use -name")
+	}
+
+	preset := clusters[choice-1].Preset(presetName)
+	if err := config.SavePreset(*configPath, preset); err != nil {
+		log.Fatal("Error saving preset: %v", err)
+	}
+	fmt.Printf("Saved preset %q (%.2f x %.2f) to %s\n", preset.Name, preset.Width, preset.Height, *configPath)
+}
+
+func promptForPreset(clusterCount int, name string) (int, string) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Printf("\nSave a cluster as a preset? Enter its number (1-%d) or press Enter to skip: ", clusterCount)
+	line, _ := reader.ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return 0, ""
+	}
+
+	for name == "" {
+		fmt.Print("Preset name: ")
+		line, err := reader.ReadString('\n')
+		name = strings.TrimSpace(line)
+		if err != nil {
+			break
+		}
+	}
+	return choice, name
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,114 @@
+package anki_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/anki"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+)
+
+func TestAnki(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Anki Suite")
+}
+
+// ankiRequest is a request the stub received.
+type ankiRequest struct {
+	Action string
+	Params map[string]interface{}
+}
+
+// ankiStub is an AnkiConnect server answering each action with a canned result,
+// null for actions without one. It records every request it receives.
+type ankiStub struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	results  map[string]func(params map[string]interface{}) interface{}
+	requests []ankiRequest
+}
+
+func newAnkiStub() *ankiStub {
+	stub := &ankiStub{results: make(map[string]func(map[string]interface{}) interface{})}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.serve))
+	DeferCleanup(stub.server.Close)
+	return stub
+}
+
+func (s *ankiStub) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	Expect(err).NotTo(HaveOccurred())
+	var request struct {
+		Action string                 `json:"action"`
+		Params map[string]interface{} `json:"params"`
+	}
+	Expect(json.Unmarshal(body, &request)).To(Succeed())
+
+	s.mu.Lock()
+	s.requests = append(s.requests, ankiRequest{Action: request.Action, Params: request.Params})
+	result := s.results[request.Action]
+	s.mu.Unlock()
+
+	var value interface{}
+	if result != nil {
+		value = result(request.Params)
+	}
+	Expect(json.NewEncoder(w).Encode(map[string]interface{}{"result": value, "error": nil})).To(Succeed())
+}
+
+// on answers action with a fixed result.
+func (s *ankiStub) on(action string, result interface{}) {
+	s.onParams(action, func(map[string]interface{}) interface{} { return result })
+}
+
+// onParams answers action with a result computed from its parameters.
+func (s *ankiStub) onParams(action string, result func(params map[string]interface{}) interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[action] = result
+}
+
+// sent returns the requests received for action, in order.
+func (s *ankiStub) sent(action string) []ankiRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matching []ankiRequest
+	for _, request := range s.requests {
+		if request.Action == action {
+			matching = append(matching, request)
+		}
+	}
+	return matching
+}
+
+// service returns a service talking to the stub.
+func (s *ankiStub) service() *anki.Service {
+	service := anki.NewService(logger.New(logger.WithOutput(GinkgoWriter), logger.WithPrefix("[anki-test] "), logger.WithFlags(0)))
+	service.SetAnkiConnectURL(s.server.URL)
+	return service
+}
+
+// cardPair returns a card whose question and answer images exist on disk, as
+// AddFlashcard uploads them before adding a note.
+func cardPair(hash string, pageNum int) pdf.ImagePair {
+	dir := GinkgoT().TempDir()
+	pair := pdf.ImagePair{
+		Question: filepath.Join(dir, "question.png"),
+		Answer:   filepath.Join(dir, "answer.png"),
+		Hash:     hash,
+		Source:   pdf.CardSource{PageNumber: pageNum},
+	}
+	Expect(os.WriteFile(pair.Question, []byte("question"), 0o644)).To(Succeed())
+	Expect(os.WriteFile(pair.Answer, []byte("answer"), 0o644)).To(Succeed())
+	return pair
+}
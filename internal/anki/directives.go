@@ -0,0 +1,50 @@
+package anki
+
+import (
+	"fmt"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+// applyCardDirectives suspends or flags the cards of a newly added note as its
+// page's directives ask.
+func (s *Service) applyCardDirectives(noteId int, directives pdf.Directives) error {
+	if !directives.Suspend && directives.Flag == 0 {
+		return nil
+	}
+
+	cards, _, err := s.noteCards(noteId)
+	if err != nil {
+		return err
+	}
+
+	if directives.Suspend {
+		if _, err := s.sendRequest(AnkiConnectRequest{
+			Action:  "suspend",
+			Version: ANKI_CONNECT_VERSION,
+			Params:  map[string]interface{}{"cards": cards},
+		}); err != nil {
+			return fmt.Errorf("failed to suspend note %d: %w", noteId, err)
+		}
+		s.logger.Debug("Suspended the cards of note %d", noteId)
+	}
+
+	if directives.Flag != 0 {
+		for _, card := range cards {
+			if _, err := s.sendRequest(AnkiConnectRequest{
+				Action:  "setSpecificValueOfCard",
+				Version: ANKI_CONNECT_VERSION,
+				Params: map[string]interface{}{
+					"card":          card,
+					"keys":          []string{"flags"},
+					"newValues":     []int{directives.Flag},
+					"warning_check": true,
+				},
+			}); err != nil {
+				return fmt.Errorf("failed to flag card %d: %w", card, err)
+			}
+		}
+		s.logger.Debug("Set flag %d on the cards of note %d", directives.Flag, noteId)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package anki_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/anki"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("Card directives", func() {
+	var (
+		stub   *ankiStub
+		report *anki.ProcessingReport
+		source anki.SourceFile
+	)
+
+	// Every card is new; the added note 5 has cards 50 and 51.
+	BeforeEach(func() {
+		stub = newAnkiStub()
+		report = &anki.ProcessingReport{}
+		source = anki.SourceFile{RelativePath: "Biology/cells.pdf", AbsolutePath: "/notes/Biology/cells.pdf"}
+
+		stub.on("findNotes", []int{})
+		stub.on("addNote", 5)
+		stub.on("notesInfo", []map[string]interface{}{{"noteId": 5, "cards": []int{50, 51}}})
+	})
+
+	It("should add the note to the directive's deck with its tags", func() {
+		pair := cardPair("aaa", 1)
+		pair.Directives = pdf.Directives{Deck: "Exam::Hard", Tags: []string{"anatomy"}}
+
+		Expect(stub.service().AddFlashcard("Biology", source, pair, 1, report)).To(Succeed())
+
+		Expect(stub.sent("createDeck")).To(HaveLen(1))
+		Expect(stub.sent("createDeck")[0].Params).To(HaveKeyWithValue("deck", "Exam::Hard"))
+		Expect(stub.sent("addNote")).To(HaveLen(1))
+		note := stub.sent("addNote")[0].Params["note"]
+		Expect(note).To(HaveKeyWithValue("deckName", "Exam::Hard"))
+		Expect(note).To(HaveKeyWithValue("tags", ContainElement("anatomy")))
+		Expect(stub.sent("suspend")).To(BeEmpty())
+		Expect(stub.sent("setSpecificValueOfCard")).To(BeEmpty())
+	})
+
+	It("should suspend and flag the cards of the added note", func() {
+		pair := cardPair("aaa", 1)
+		pair.Directives = pdf.Directives{Suspend: true, Flag: 4}
+
+		Expect(stub.service().AddFlashcard("Biology", source, pair, 1, report)).To(Succeed())
+
+		Expect(stub.sent("notesInfo")).To(HaveLen(1))
+		Expect(stub.sent("notesInfo")[0].Params["notes"]).To(ConsistOf(BeNumerically("==", 5)))
+		Expect(stub.sent("suspend")).To(HaveLen(1))
+		Expect(stub.sent("suspend")[0].Params["cards"]).To(ConsistOf(BeNumerically("==", 50), BeNumerically("==", 51)))
+
+		flagged := stub.sent("setSpecificValueOfCard")
+		Expect(flagged).To(HaveLen(2))
+		for i, request := range flagged {
+			Expect(request.Params).To(HaveKeyWithValue("card", BeNumerically("==", 50+i)))
+			Expect(request.Params).To(HaveKeyWithValue("keys", ConsistOf("flags")))
+			Expect(request.Params).To(HaveKeyWithValue("newValues", ConsistOf(BeNumerically("==", 4))))
+		}
+		Expect(report.AddedCount).To(Equal(1))
+	})
+
+	It("should leave the cards of a duplicate alone", func() {
+		stub.on("findNotes", []int{9})
+		stub.on("notesInfo", []map[string]interface{}{{"noteId": 9, "cards": []int{90}}})
+		stub.on("cardsInfo", []map[string]interface{}{{"deckName": "Biology"}})
+		pair := cardPair("aaa", 1)
+		pair.Directives = pdf.Directives{Suspend: true, Flag: 1}
+
+		Expect(stub.service().AddFlashcard("Biology", source, pair, 1, report)).To(Succeed())
+
+		Expect(stub.sent("addNote")).To(BeEmpty())
+		Expect(stub.sent("suspend")).To(BeEmpty())
+		Expect(stub.sent("setSpecificValueOfCard")).To(BeEmpty())
+		Expect(report.SkippedCount).To(Equal(1))
+	})
+})
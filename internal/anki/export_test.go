@@ -0,0 +1,11 @@
+package anki
+
+// SetAnkiConnectURL points the service at a test server.
+func (s *Service) SetAnkiConnectURL(url string) {
+	s.ankiConnectURL = url
+}
+
+// EnsureModelExists exposes ensureModelExists to the tests.
+func (s *Service) EnsureModelExists() error {
+	return s.ensureModelExists()
+}
@@ -0,0 +1,288 @@
+package anki
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/state"
+)
+
+// MovePolicy decides what happens to an existing note whose deck differs from the
+// deck its PDF now maps to, after the PDF was moved or renamed.
+type MovePolicy string
+
+const (
+	MoveKeep   MovePolicy = "keep"   // leave the note where it is
+	MoveReport MovePolicy = "report" // leave it, but list it in the report
+	MoveNotes  MovePolicy = "move"   // move its cards to the new deck, retag it and update its source
+)
+
+// ParseMovePolicy parses a policy name. An empty name is MoveReport.
+func ParseMovePolicy(name string) (MovePolicy, error) {
+	switch policy := MovePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return MoveReport, nil
+	case MoveKeep, MoveReport, MoveNotes:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown moved note policy %q (expected keep, report or move)", name)
+	}
+}
+
+// MovedNoteInfo is an existing note found in a different deck than its PDF's path
+// gives. Moved reports whether its cards were moved to ToDeck.
+type MovedNoteInfo struct {
+	NoteID     int
+	Hash       string
+	PageNumber int
+	FromDeck   string
+	ToDeck     string
+	Moved      bool
+}
+
+// Summary describes the note and what happened to it.
+func (m MovedNoteInfo) Summary() string {
+	action := "left in"
+	if m.Moved {
+		action = "moved from"
+	}
+	return fmt.Sprintf("Note %d (Page %d, Hash:%s) %s %s, its PDF maps to %s", m.NoteID, m.PageNumber, m.Hash, action, m.FromDeck, m.ToDeck)
+}
+
+// SetMovePolicy sets what happens to existing notes found in another deck.
+func (s *Service) SetMovePolicy(policy MovePolicy) {
+	s.movePolicy = policy
+}
+
+// checkExistingNote applies the move policy to a duplicate of the card being added
+// to deckName with tags. It returns the deck the note is in afterwards, "" when that
+// is unknown, and the tags NotesAnkify gave it. The note's deck is taken from the
+// state when it was recorded, and otherwise checked against the notes of deckName,
+// found once per deck, so only notes in another deck cost further requests. Under
+// MoveKeep it is not checked at all.
+func (s *Service) checkExistingNote(noteId int, deckName string, source SourceFile, pair pdf.ImagePair, pageNum int, tags []string, report *ProcessingReport) (string, []string, error) {
+	var recorded state.Card
+	if s.state != nil {
+		if card, found := s.state.Lookup(pair.Hash); found && card.NoteID == noteId {
+			recorded = card
+		}
+	}
+	known := recorded.DeckName != ""
+	if s.movePolicy == MoveKeep {
+		// The note's deck is not checked, so only a recorded deck is known.
+		return recorded.DeckName, recorded.Tags, nil
+	}
+
+	if known && recorded.DeckName == deckName {
+		return deckName, recorded.Tags, nil
+	}
+	if !known {
+		inDeck, err := s.deckHasNote(deckName, noteId, report)
+		if err != nil {
+			return "", nil, err
+		}
+		if inDeck {
+			return deckName, nil, nil
+		}
+	}
+
+	// The note is elsewhere. Without a recorded deck, or before moving it, ask Anki.
+	currentDeck := recorded.DeckName
+	var cards []int
+	if !known || s.movePolicy == MoveNotes {
+		var err error
+		if cards, _, err = s.noteCards(noteId); err != nil {
+			return recorded.DeckName, recorded.Tags, err
+		}
+		if currentDeck, err = s.cardDeck(cards); err != nil {
+			return recorded.DeckName, recorded.Tags, err
+		}
+		if currentDeck == deckName {
+			return deckName, recorded.Tags, nil
+		}
+	}
+
+	moved := MovedNoteInfo{
+		NoteID:     noteId,
+		Hash:       pair.Hash,
+		PageNumber: pageNum,
+		FromDeck:   currentDeck,
+		ToDeck:     deckName,
+	}
+	if s.movePolicy != MoveNotes {
+		s.logger.Info("Note %d is in deck %s instead of %s", noteId, currentDeck, deckName)
+		report.MovedNotes = append(report.MovedNotes, moved)
+		return currentDeck, recorded.Tags, nil
+	}
+
+	oldTags := recorded.Tags
+	if oldTags == nil {
+		// Before tags were recorded, only the deck's tag is known to be NotesAnkify's.
+		oldTags = []string{getDeckNameUnderscoreSeparatedForTag(currentDeck)}
+	}
+	if err := s.moveNote(noteId, cards, currentDeck, deckName, report); err != nil {
+		return currentDeck, recorded.Tags, err
+	}
+	if err := s.retagNote(noteId, oldTags, tags); err != nil {
+		return deckName, recorded.Tags, err
+	}
+	if err := s.updateNoteFields(noteId, SourceFields(source, pair.Source, s.pageLinks)); err != nil {
+		return deckName, tags, err
+	}
+	s.logger.Info("Moved note %d from deck %s to %s", noteId, currentDeck, deckName)
+	moved.Moved = true
+	report.MovedNotes = append(report.MovedNotes, moved)
+	return deckName, tags, nil
+}
+
+// deckHasNote reports whether a note is in the deck itself, not a sub-deck. The
+// deck's notes are searched once per run and cached in the report.
+func (s *Service) deckHasNote(deckName string, noteId int, report *ProcessingReport) (bool, error) {
+	notes, ok := report.deckNotes[deckName]
+	if !ok {
+		deck := searchEscape(deckName)
+		result, err := s.sendRequest(AnkiConnectRequest{
+			Action:  "findNotes",
+			Version: ANKI_CONNECT_VERSION,
+			Params:  map[string]interface{}{"query": fmt.Sprintf(`"deck:%s" -"deck:%s::*"`, deck, deck)},
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to search deck %s: %w", deckName, err)
+		}
+		var noteIds []int
+		if err := json.Unmarshal(result, &noteIds); err != nil {
+			return false, fmt.Errorf("failed to parse note IDs: %w", err)
+		}
+
+		notes = make(map[int]bool, len(noteIds))
+		for _, id := range noteIds {
+			notes[id] = true
+		}
+		if report.deckNotes == nil {
+			report.deckNotes = make(map[string]map[int]bool)
+		}
+		report.deckNotes[deckName] = notes
+	}
+	return notes[noteId], nil
+}
+
+// addDeckNote adds a note just added to a deck to the deck's cached notes.
+func (r *ProcessingReport) addDeckNote(deckName string, noteId int) {
+	if notes, ok := r.deckNotes[deckName]; ok {
+		notes[noteId] = true
+	}
+}
+
+// noteCards returns the card IDs and tags of a note.
+func (s *Service) noteCards(noteId int) ([]int, []string, error) {
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "notesInfo",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"notes": []int{noteId}},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	var notes []struct {
+		Cards []int    `json:"cards"`
+		Tags  []string `json:"tags"`
+	}
+	if err := json.Unmarshal(result, &notes); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse note: %w", err)
+	}
+	if len(notes) == 0 || len(notes[0].Cards) == 0 {
+		return nil, nil, fmt.Errorf("note %d has no cards", noteId)
+	}
+	return notes[0].Cards, notes[0].Tags, nil
+}
+
+// cardDeck returns the deck of the first card.
+func (s *Service) cardDeck(cards []int) (string, error) {
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "cardsInfo",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"cards": cards[:1]},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get cards: %w", err)
+	}
+
+	var infos []struct {
+		DeckName string `json:"deckName"`
+	}
+	if err := json.Unmarshal(result, &infos); err != nil {
+		return "", fmt.Errorf("failed to parse cards: %w", err)
+	}
+	if len(infos) == 0 {
+		return "", fmt.Errorf("card %d not found", cards[0])
+	}
+	return infos[0].DeckName, nil
+}
+
+// updateNoteFields replaces the given fields of a note.
+func (s *Service) updateNoteFields(noteId int, fields map[string]string) error {
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "updateNoteFields",
+		Version: ANKI_CONNECT_VERSION,
+		Params: map[string]interface{}{
+			"note": map[string]interface{}{"id": noteId, "fields": fields},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to update note %d: %w", noteId, err)
+	}
+	return nil
+}
+
+// moveNote moves the note's cards to the new deck.
+func (s *Service) moveNote(noteId int, cards []int, fromDeck, toDeck string, report *ProcessingReport) error {
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "changeDeck",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"cards": cards, "deck": toDeck},
+	}); err != nil {
+		return fmt.Errorf("failed to move note %d from %s: %w", noteId, fromDeck, err)
+	}
+	delete(report.deckNotes, fromDeck)
+	report.addDeckNote(toDeck, noteId)
+	return nil
+}
+
+// retagNote replaces the tags NotesAnkify gave a moved note with the ones it gets
+// in its new place, keeping the tags added in Anki.
+func (s *Service) retagNote(noteId int, oldTags, newTags []string) error {
+	var stale []string
+	for _, tag := range oldTags {
+		if !containsTag(newTags, tag) {
+			stale = append(stale, tag)
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := s.sendRequest(AnkiConnectRequest{
+			Action:  "removeTags",
+			Version: ANKI_CONNECT_VERSION,
+			Params:  map[string]interface{}{"notes": []int{noteId}, "tags": strings.Join(stale, " ")},
+		}); err != nil {
+			return fmt.Errorf("failed to remove tags %s from note %d: %w", strings.Join(stale, " "), noteId, err)
+		}
+	}
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "addTags",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"notes": []int{noteId}, "tags": strings.Join(newTags, " ")},
+	}); err != nil {
+		return fmt.Errorf("failed to add tags to note %d: %w", noteId, err)
+	}
+	return nil
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, other := range tags {
+		if strings.EqualFold(other, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package anki_test
+
+import (
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/anki"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/state"
+)
+
+var _ = Describe("Moved notes", func() {
+	var (
+		stub   *ankiStub
+		report *anki.ProcessingReport
+		source anki.SourceFile
+	)
+
+	// Notes 1 and 2 are in Biology, note 3 in Old::Biology.
+	BeforeEach(func() {
+		stub = newAnkiStub()
+		report = &anki.ProcessingReport{}
+		source = anki.SourceFile{RelativePath: "Biology/cells.pdf", AbsolutePath: "/notes/Biology/cells.pdf"}
+
+		stub.onParams("findNotes", func(params map[string]interface{}) interface{} {
+			query := params["query"].(string)
+			switch {
+			case strings.HasPrefix(query, "Hash:"):
+				return []int{map[string]int{"aaa": 1, "bbb": 2, "ccc": 3}[strings.TrimPrefix(query, "Hash:")]}
+			case strings.Contains(query, `"deck:Biology"`):
+				return []int{1, 2}
+			}
+			return []int{}
+		})
+		stub.on("notesInfo", []map[string]interface{}{{"noteId": 3, "cards": []int{30, 31}, "tags": []string{"notesankify", "old::biology"}}})
+		stub.on("cardsInfo", []map[string]interface{}{{"deckName": "Old::Biology"}})
+	})
+
+	addDuplicates := func(service *anki.Service, hashes ...string) {
+		for i, hash := range hashes {
+			pair := pdf.ImagePair{Hash: hash, Source: pdf.CardSource{PageNumber: i + 1}}
+			Expect(service.AddFlashcard("Biology", source, pair, i+1, report, "cs101")).To(Succeed())
+		}
+	}
+
+	It("should look up the notes of a deck once when reporting moved notes", func() {
+		addDuplicates(stub.service(), "aaa", "bbb", "ccc")
+
+		var deckSearches int
+		for _, request := range stub.sent("findNotes") {
+			if strings.Contains(request.Params["query"].(string), "deck:") {
+				deckSearches++
+			}
+		}
+		Expect(deckSearches).To(Equal(1))
+		Expect(stub.sent("notesInfo")).To(HaveLen(1))
+		Expect(stub.sent("cardsInfo")).To(HaveLen(1))
+		Expect(stub.sent("changeDeck")).To(BeEmpty())
+
+		Expect(report.SkippedCount).To(Equal(3))
+		Expect(report.MovedNotes).To(HaveLen(1))
+		Expect(report.MovedNotes[0].NoteID).To(Equal(3))
+		Expect(report.MovedNotes[0].FromDeck).To(Equal("Old::Biology"))
+		Expect(report.MovedNotes[0].Moved).To(BeFalse())
+	})
+
+	It("should take the deck of a note from the state when recorded", func() {
+		store, err := state.Open(filepath.Join(GinkgoT().TempDir(), "state.json"))
+		Expect(err).NotTo(HaveOccurred())
+		store.Record(state.Card{Hash: "aaa", NoteID: 1, DeckName: "Biology"})
+		store.Record(state.Card{Hash: "ccc", NoteID: 3, DeckName: "Old::Biology"})
+		service := stub.service()
+		service.SetState(store)
+
+		addDuplicates(service, "aaa", "ccc")
+
+		Expect(stub.sent("findNotes")).To(HaveLen(2)) // the two hash lookups
+		Expect(stub.sent("notesInfo")).To(BeEmpty())
+		Expect(stub.sent("cardsInfo")).To(BeEmpty())
+		Expect(report.MovedNotes).To(HaveLen(1))
+		Expect(report.MovedNotes[0].FromDeck).To(Equal("Old::Biology"))
+	})
+
+	It("should move a note and replace the tags NotesAnkify gave it", func() {
+		store, err := state.Open(filepath.Join(GinkgoT().TempDir(), "state.json"))
+		Expect(err).NotTo(HaveOccurred())
+		store.Record(state.Card{Hash: "ccc", NoteID: 3, DeckName: "Old::Biology",
+			Tags: []string{"notesankify", "Old::Biology", "old-title", "cs101"}})
+		service := stub.service()
+		service.SetState(store)
+		service.SetMovePolicy(anki.MoveNotes)
+
+		addDuplicates(service, "aaa", "bbb", "ccc")
+
+		moves := stub.sent("changeDeck")
+		Expect(moves).To(HaveLen(1))
+		Expect(moves[0].Params["cards"]).To(ConsistOf(30.0, 31.0))
+		Expect(moves[0].Params["deck"]).To(Equal("Biology"))
+
+		removed := stub.sent("removeTags")
+		Expect(removed).To(HaveLen(1))
+		Expect(strings.Fields(removed[0].Params["tags"].(string))).To(ConsistOf("Old::Biology", "old-title"))
+		added := stub.sent("addTags")
+		Expect(added).To(HaveLen(1))
+		Expect(strings.Fields(added[0].Params["tags"].(string))).To(ConsistOf("notesankify", "Biology", "cs101"))
+
+		updates := stub.sent("updateNoteFields")
+		Expect(updates).To(HaveLen(1))
+		fields := updates[0].Params["note"].(map[string]interface{})["fields"].(map[string]interface{})
+		Expect(fields["Source"]).To(ContainSubstring("Biology/cells.pdf"))
+
+		Expect(report.MovedNotes).To(HaveLen(1))
+		Expect(report.MovedNotes[0].Moved).To(BeTrue())
+		card, found := store.Lookup("ccc")
+		Expect(found).To(BeTrue())
+		Expect(card.DeckName).To(Equal("Biology"))
+		Expect(card.Tags).To(ConsistOf("notesankify", "Biology", "cs101"))
+	})
+
+	It("should not record the new deck for a note left in place under keep", func() {
+		store, err := state.Open(filepath.Join(GinkgoT().TempDir(), "state.json"))
+		Expect(err).NotTo(HaveOccurred())
+		keeping := stub.service()
+		keeping.SetState(store)
+		keeping.SetMovePolicy(anki.MoveKeep)
+
+		addDuplicates(keeping, "ccc")
+		Expect(stub.sent("changeDeck")).To(BeEmpty())
+		card, found := store.Lookup("ccc")
+		Expect(found).To(BeTrue())
+		Expect(card.DeckName).To(BeEmpty())
+
+		moving := stub.service()
+		moving.SetState(store)
+		moving.SetMovePolicy(anki.MoveNotes)
+
+		addDuplicates(moving, "ccc")
+		Expect(stub.sent("changeDeck")).To(HaveLen(1))
+		card, _ = store.Lookup("ccc")
+		Expect(card.DeckName).To(Equal("Biology"))
+	})
+})
+
+var _ = Describe("Move policies", func() {
+	It("should parse the moved note policies", func() {
+		for name, expected := range map[string]anki.MovePolicy{"": anki.MoveReport, "keep": anki.MoveKeep, "Move": anki.MoveNotes} {
+			policy, err := anki.ParseMovePolicy(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(expected))
+		}
+		_, err := anki.ParseMovePolicy("delete")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should say whether a note was moved", func() {
+		note := anki.MovedNoteInfo{NoteID: 7, Hash: "abc", PageNumber: 2, FromDeck: "Root::old", ToDeck: "Root::new"}
+		Expect(note.Summary()).To(Equal("Note 7 (Page 2, Hash:abc) left in Root::old, its PDF maps to Root::new"))
+		note.Moved = true
+		Expect(note.Summary()).To(HavePrefix("Note 7 (Page 2, Hash:abc) moved from Root::old"))
+	})
+})
@@ -4,18 +4,88 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"html"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/state"
+	"github.com/kpauljoseph/notesankify/pkg/models"
 )
 
+// requiredModelFields are the fields a note type must have for cards to be added as it.
+var requiredModelFields = []string{"Front", "Back", "Hash"}
+
+// modelFields are the NotesAnkify note fields in order. SortOrder comes first, as Anki
+// sorts the browser by a new note type's first field and createModel cannot pick
+// another. QuestionText and AnswerText hold the cards' typed text so Anki's search
+// finds them; the templates never show them. Source, Page and SortOrder record where
+// the card came from, see SourceFields. Hint and Extra hold the card's extra regions,
+// see regionFields.
+var modelFields = []string{"SortOrder", "Front", "Back", "Hash", "QuestionText", "AnswerText", "Source", "Page", "Hint", "Extra"}
+
+// sortField is the field NotesAnkify's note types sort the browser by.
+const sortField = "SortOrder"
+
+// regionFields maps the extra regions of a card to the note fields they fill.
+var regionFields = map[string]string{
+	models.HintRegion:  "Hint",
+	models.ExtraRegion: "Extra",
+}
+
+// templateSection is the part of the card templates showing one optional field.
+// Models created before the field existed get it added, see addTemplateSections.
+type templateSection struct {
+	field   string
+	side    string // "Front" or "Back"
+	snippet string
+	css     string
+}
+
+var templateSections = []templateSection{
+	{
+		field:   "Hint",
+		side:    "Front",
+		snippet: `{{#Hint}}<div class="hint">{{hint:Hint}}</div>{{/Hint}}`,
+		css:     `.hint { margin-top: 1em; font-size: 16px; }`,
+	},
+	{
+		field:   "Extra",
+		side:    "Back",
+		snippet: `{{#Extra}}<div class="extra">{{Extra}}</div>{{/Extra}}`,
+		css:     `.extra { margin-top: 1em; }`,
+	},
+	{
+		field:   "Source",
+		side:    "Back",
+		snippet: `{{#Source}}<div class="source">{{Source}}, page {{Page}}</div>{{/Source}}`,
+		css:     `.source { margin-top: 2em; font-size: 12px; color: #999; }`,
+	},
+}
+
+// templateSnippets joins the sections shown on one side of the card.
+func templateSnippets(side string) string {
+	var snippets []string
+	for _, section := range templateSections {
+		if section.side == side {
+			snippets = append(snippets, section.snippet)
+		}
+	}
+	return strings.Join(snippets, "\n")
+}
+
+// hashTemplate hides the card's hash on the front of the NotesAnkify note type.
+const hashTemplate = `<div class="hash">{{Hash}}</div>`
+
 const (
 	DefaultAnkiConnectURL = "http://localhost:8765"
 	NotesAnkifyModelName  = "NotesAnkify"
@@ -25,7 +95,13 @@ const (
 
 type Service struct {
 	ankiConnectURL string
-	logger         *logger.Logger
+	modelName      string // note type cards are added as, created when missing
+	pageLinks      bool   // link sources with #page=N
+	movePolicy     MovePolicy
+	// unfinishedPolicy handles cards with a blank answer, "" when not detecting them.
+	unfinishedPolicy UnfinishedPolicy
+	state            *state.Store
+	logger           *logger.Logger
 }
 
 type AnkiConnectRequest struct {
@@ -67,12 +143,25 @@ type Fields struct {
 type ProcessingReport struct {
 	TotalProcessed  int
 	AddedCount      int
+	UpdatedCount    int // unfinished notes whose answer was filled in
 	SkippedCount    int
 	SkippedCards    []SkippedCardInfo
+	MovedNotes      []MovedNoteInfo
+	UnfinishedCards []UnfinishedCardInfo
+	UnpairedPages   []UnpairedPageInfo
+	PresetMatches   []PresetMatchInfo
+	PageSelections  []PageSelectionInfo
+	SkippedPages    []SkippedPageInfo
+	EncryptedPDFs   []EncryptedPDFInfo
+	RenderFailures  []RenderFailureInfo
 	ProcessedPDFs   int
 	TotalFlashcards int
 	StartTime       time.Time
 	EndTime         time.Time
+
+	// deckNotes caches the notes of each deck duplicates were looked for in during
+	// the run, see deckHasNote.
+	deckNotes map[string]map[int]bool
 }
 
 type SkippedCardInfo struct {
@@ -81,13 +170,81 @@ type SkippedCardInfo struct {
 	PageNumber int
 }
 
+// UnpairedPageInfo is a page left without a partner when building two-page cards.
+type UnpairedPageInfo struct {
+	FilePath   string
+	PageNumber int
+}
+
+// PresetMatchInfo records which dimension preset a processed page matched.
+type PresetMatchInfo struct {
+	FilePath   string
+	PageNumber int
+	Preset     string
+}
+
+// PageSelectionInfo records the pages processed in a PDF when not all of them were.
+type PageSelectionInfo struct {
+	FilePath      string
+	SelectedPages string
+	PageCount     int
+}
+
+// EncryptedPDFInfo is an encrypted PDF skipped because no supplied password opened it.
+type EncryptedPDFInfo struct {
+	FilePath string
+	Reason   string
+}
+
+// RenderFailureInfo is a crash or timeout of the render worker. PageNumber is 0 when
+// the failure was not on a particular page.
+type RenderFailureInfo struct {
+	FilePath   string
+	PageNumber int
+	Reason     string
+}
+
+// SkippedPageInfo explains why a page did not become a card.
+type SkippedPageInfo struct {
+	FilePath string
+	Decision pdf.PageDecision
+}
+
 func NewService(logger *logger.Logger) *Service {
 	return &Service{
 		ankiConnectURL: DefaultAnkiConnectURL,
+		modelName:      NotesAnkifyModelName,
+		movePolicy:     MoveReport,
 		logger:         logger,
 	}
 }
 
+// WithModel returns a service that adds cards as the named note type, which is
+// created with the NotesAnkify fields and template when it does not exist. An
+// existing note type must have the Front, Back and Hash fields, and is only given
+// the newer NotesAnkify fields when NotesAnkify created it. An empty name keeps
+// the current note type.
+func (s *Service) WithModel(modelName string) *Service {
+	if modelName == "" {
+		return s
+	}
+	service := *s
+	service.modelName = modelName
+	return &service
+}
+
+// SetPageLinks makes the source links of new cards open the PDF at the card's
+// page by appending #page=N, which browsers and most PDF viewers follow.
+func (s *Service) SetPageLinks(enabled bool) {
+	s.pageLinks = enabled
+}
+
+// SetState makes the service remember the cards it adds, and the existing notes it
+// finds, in store.
+func (s *Service) SetState(store *state.Store) {
+	s.state = store
+}
+
 func (s *Service) ensureModelExists() error {
 	request := AnkiConnectRequest{
 		Action:  "modelNames",
@@ -106,9 +263,9 @@ func (s *Service) ensureModelExists() error {
 	}
 
 	for _, name := range modelNames {
-		if name == NotesAnkifyModelName {
-			s.logger.Debug("NotesAnkify model already exists")
-			return nil
+		if name == s.modelName {
+			s.logger.Debug("%s model already exists", s.modelName)
+			return s.checkExistingModel()
 		}
 	}
 
@@ -116,12 +273,8 @@ func (s *Service) ensureModelExists() error {
 		Action:  "createModel",
 		Version: ANKI_CONNECT_VERSION,
 		Params: map[string]interface{}{
-			"modelName": NotesAnkifyModelName,
-			"inOrderFields": []string{
-				"Front",
-				"Back",
-				"Hash",
-			},
+			"modelName":     s.modelName,
+			"inOrderFields": modelFields,
 			"css": `.card {
                 font-family: arial;
                 font-size: 20px;
@@ -129,15 +282,18 @@ func (s *Service) ensureModelExists() error {
                 color: black;
                 background-color: white;
             }
-            .hash { display: none; }`,
+            .hash { display: none; }
+            ` + templateCSS(nil),
 			"cardTemplates": []map[string]interface{}{
 				{
 					"Name": "Card 1",
 					"Front": `{{Front}}
-                        <div class="hash">{{Hash}}</div>`,
+                        ` + hashTemplate + `
+                        ` + templateSnippets("Front"),
 					"Back": `{{FrontSide}}
                         <hr id="answer">
-                        {{Back}}`,
+                        {{Back}}
+                        ` + templateSnippets("Back"),
 				},
 			},
 		},
@@ -148,7 +304,247 @@ func (s *Service) ensureModelExists() error {
 		return fmt.Errorf("failed to create model: %w", err)
 	}
 
-	s.logger.Info("Created NotesAnkify model")
+	s.logger.Info("Created %s model", s.modelName)
+	return nil
+}
+
+// checkExistingModel makes sure cards can be added as an existing note type. Note
+// types NotesAnkify created get the fields introduced since; others are never
+// changed, and are refused when they lack the required fields.
+func (s *Service) checkExistingModel() error {
+	fieldNames, err := s.modelFieldNames()
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, name := range requiredModelFields {
+		if !containsField(fieldNames, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("note type %s lacks fields NotesAnkify needs (%s); choose another note_model, or a new name for NotesAnkify to create",
+			s.modelName, strings.Join(missing, ", "))
+	}
+
+	if s.modelName != NotesAnkifyModelName {
+		created, err := s.createdByNotesAnkify()
+		if err != nil {
+			return err
+		}
+		if !created {
+			s.logger.Debug("Using note type %s as it is, as NotesAnkify did not create it", s.modelName)
+			return nil
+		}
+	}
+	if err := s.ensureModelFields(fieldNames); err != nil {
+		return err
+	}
+	s.checkSortField()
+	return nil
+}
+
+// checkSortField tells how to sort the browser by SortOrder when a note type created
+// before it became the first field still sorts by another. AnkiConnect cannot change
+// a note type's sort field, so this is left to the user.
+func (s *Service) checkSortField() {
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "findModelsByName",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"modelNames": []string{s.modelName}},
+	})
+	if err != nil {
+		s.logger.Debug("Warning: failed to get the sort field of the %s model: %v", s.modelName, err)
+		return
+	}
+
+	var foundModels []struct {
+		SortField int `json:"sortf"`
+		Fields    []struct {
+			Name string `json:"name"`
+		} `json:"flds"`
+	}
+	if err := json.Unmarshal(result, &foundModels); err != nil || len(foundModels) == 0 {
+		s.logger.Debug("Warning: failed to parse the %s model: %v", s.modelName, err)
+		return
+	}
+	model := foundModels[0]
+	if model.SortField < len(model.Fields) && model.Fields[model.SortField].Name != sortField {
+		s.logger.Info("The %s model sorts the browser by %s; to list cards in page order, pick %s under "+
+			"Tools > Manage Note Types > Fields > \"Sort by this field in the browser\"",
+			s.modelName, model.Fields[model.SortField].Name, sortField)
+	}
+}
+
+// createdByNotesAnkify reports whether the model's templates hold the hidden hash
+// NotesAnkify puts on the front of the note types it creates.
+func (s *Service) createdByNotesAnkify() (bool, error) {
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "modelTemplates",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"modelName": s.modelName},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get model templates: %w", err)
+	}
+
+	var templates map[string]map[string]string
+	if err := json.Unmarshal(result, &templates); err != nil {
+		return false, fmt.Errorf("failed to parse model templates: %w", err)
+	}
+	for _, template := range templates {
+		if strings.Contains(template["Front"], hashTemplate) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Service) modelFieldNames() ([]string, error) {
+	request := AnkiConnectRequest{
+		Action:  "modelFieldNames",
+		Version: ANKI_CONNECT_VERSION,
+		Params: map[string]interface{}{
+			"modelName": s.modelName,
+		},
+	}
+
+	result, err := s.sendRequest(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model fields: %w", err)
+	}
+
+	var fieldNames []string
+	if err := json.Unmarshal(result, &fieldNames); err != nil {
+		return nil, fmt.Errorf("failed to parse model fields: %w", err)
+	}
+	return fieldNames, nil
+}
+
+// ensureModelFields adds fields introduced after the model was first created.
+func (s *Service) ensureModelFields(fieldNames []string) error {
+	existing := make(map[string]bool, len(fieldNames))
+	for _, name := range fieldNames {
+		existing[name] = true
+	}
+
+	var added []string
+	for index, name := range modelFields {
+		if existing[name] {
+			continue
+		}
+
+		addRequest := AnkiConnectRequest{
+			Action:  "modelFieldAdd",
+			Version: ANKI_CONNECT_VERSION,
+			Params: map[string]interface{}{
+				"modelName": s.modelName,
+				"fieldName": name,
+				"index":     index,
+			},
+		}
+		if _, err := s.sendRequest(addRequest); err != nil {
+			return fmt.Errorf("failed to add model field %s: %w", name, err)
+		}
+		s.logger.Info("Added field %s to the %s model", name, s.modelName)
+		added = append(added, name)
+	}
+	return s.addTemplateSections(added)
+}
+
+// templateCSS returns the styling of the template sections of the given fields, or
+// of all sections when fields is nil.
+func templateCSS(fields []string) string {
+	var css []string
+	for _, section := range templateSections {
+		if fields == nil || containsField(fields, section.field) {
+			css = append(css, section.css)
+		}
+	}
+	return strings.Join(css, "\n")
+}
+
+func containsField(fields []string, field string) bool {
+	for _, name := range fields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// addTemplateSections adds the template sections of newly added fields to the
+// templates and styling of a model created before the fields existed.
+func (s *Service) addTemplateSections(fields []string) error {
+	var sections []templateSection
+	for _, section := range templateSections {
+		if containsField(fields, section.field) {
+			sections = append(sections, section)
+		}
+	}
+	if len(sections) == 0 {
+		return nil
+	}
+
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "modelTemplates",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"modelName": s.modelName},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get model templates: %w", err)
+	}
+
+	var templates map[string]map[string]string
+	if err := json.Unmarshal(result, &templates); err != nil {
+		return fmt.Errorf("failed to parse model templates: %w", err)
+	}
+	for name, template := range templates {
+		for _, section := range sections {
+			if !strings.Contains(template[section.side], "{{"+section.field+"}}") &&
+				!strings.Contains(template[section.side], ":"+section.field+"}}") {
+				template[section.side] += "\n" + section.snippet
+			}
+		}
+		templates[name] = template
+	}
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "updateModelTemplates",
+		Version: ANKI_CONNECT_VERSION,
+		Params: map[string]interface{}{
+			"model": map[string]interface{}{"name": s.modelName, "templates": templates},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to update model templates: %w", err)
+	}
+
+	result, err = s.sendRequest(AnkiConnectRequest{
+		Action:  "modelStyling",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"modelName": s.modelName},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get model styling: %w", err)
+	}
+
+	var styling struct {
+		CSS string `json:"css"`
+	}
+	if err := json.Unmarshal(result, &styling); err != nil {
+		return fmt.Errorf("failed to parse model styling: %w", err)
+	}
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "updateModelStyling",
+		Version: ANKI_CONNECT_VERSION,
+		Params: map[string]interface{}{
+			"model": map[string]interface{}{"name": s.modelName, "css": styling.CSS + "\n" + templateCSS(fields)},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to update model styling: %w", err)
+	}
+
+	s.logger.Info("Updated the templates of the %s model for fields %s", s.modelName, strings.Join(fields, ", "))
 	return nil
 }
 
@@ -211,20 +607,52 @@ func (s *Service) findExistingNoteByHash(hash string) (int, error) {
 	return 0, nil
 }
 
-func (s *Service) AddFlashcard(deckName string, pair pdf.ImagePair, pageNum int, report *ProcessingReport) error {
+// AddFlashcard adds one card from the source PDF to the deck. Extra tags are added
+// alongside the default ones. The directives written on a new card can choose
+// another deck, add tags, and suspend or flag it. Cards with a blank answer follow
+// the unfinished policy, and update their note once the answer is written.
+func (s *Service) AddFlashcard(deckName string, source SourceFile, pair pdf.ImagePair, pageNum int, report *ProcessingReport, tags ...string) error {
 	report.TotalProcessed++
 
+	if pair.Directives.Deck != "" && pair.Directives.Deck != deckName {
+		s.logger.Debug("Page %d asks for deck %s instead of %s", pageNum, pair.Directives.Deck, deckName)
+		deckName = pair.Directives.Deck
+		if err := s.CreateDeck(deckName); err != nil {
+			return fmt.Errorf("failed to create deck %s: %w", deckName, err)
+		}
+	}
+
+	if pair.BlankAnswer && s.unfinishedPolicy == UnfinishedSkip {
+		s.logger.Info("Skipping unfinished card on page %d: its answer is blank", pageNum)
+		report.addUnfinished(deckName, pair, pageNum, "skipped")
+		return nil
+	}
+
 	s.logger.Debug("Processing new flashcard for deck: %s", deckName)
 	s.logger.Debug("Question image: %s", pair.Question)
 	s.logger.Debug("Answer image: %s", pair.Answer)
 	s.logger.Debug("Using content hash: %s", pair.Hash)
 
+	noteTags := append(append([]string{"notesankify", getDeckNameUnderscoreSeparatedForTag(deckName)}, tags...),
+		NormalizeTags(pair.Directives.Tags)...)
+
 	// Check for existing note with same hash
 	existingNoteId, err := s.findExistingNoteByHash(pair.Hash)
 	if err != nil {
 		s.logger.Debug("Warning: failed to check for existing note: %v", err)
 	} else if existingNoteId != 0 {
 		s.logger.Info("Skipping duplicate flashcard with hash: %s", pair.Hash)
+		noteDeck, recordedTags, err := s.checkExistingNote(existingNoteId, deckName, source, pair, pageNum, noteTags, report)
+		if err != nil {
+			s.logger.Info("Warning: failed to check the deck of note %d: %v", existingNoteId, err)
+		}
+		s.recordCard(existingNoteId, noteDeck, source, pair, recordedTags)
+		if pair.BlankAnswer {
+			if noteDeck == "" {
+				noteDeck = deckName
+			}
+			report.addUnfinished(noteDeck, pair, pageNum, "already in Anki")
+		}
 		report.SkippedCount++
 		report.SkippedCards = append(report.SkippedCards,
 			SkippedCardInfo{
@@ -235,35 +663,36 @@ func (s *Service) AddFlashcard(deckName string, pair pdf.ImagePair, pageNum int,
 		return nil
 	}
 
-	questionImage, err := s.readAndEncodeImage(pair.Question)
-	if err != nil {
-		return fmt.Errorf("failed to read question image: %w", err)
+	if s.unfinishedPolicy == UnfinishedTag || s.unfinishedPolicy == UnfinishedSuspend {
+		if updated, err := s.updateUnfinishedNote(deckName, source, pair, pageNum, report); updated || err != nil {
+			return err
+		}
 	}
 
-	answerImage, err := s.readAndEncodeImage(pair.Answer)
-	if err != nil {
-		return fmt.Errorf("failed to read answer image: %w", err)
+	if err := s.storeCardMedia(pair); err != nil {
+		return err
 	}
 
-	if err := s.storeMediaFiles(map[string]string{
-		filepath.Base(pair.Question): questionImage,
-		filepath.Base(pair.Answer):   answerImage,
-	}); err != nil {
-		return fmt.Errorf("failed to store media files: %w", err)
+	addedTags := noteTags
+	directives := pair.Directives
+	if pair.BlankAnswer {
+		switch s.unfinishedPolicy {
+		case UnfinishedSuspend:
+			directives.Suspend = true
+			fallthrough
+		case UnfinishedTag:
+			addedTags = append(append([]string(nil), noteTags...), UnfinishedTagName)
+		}
 	}
 
 	note := Note{
 		DeckName:  deckName,
-		ModelName: NotesAnkifyModelName,
-		Fields: map[string]string{
-			"Front": fmt.Sprintf("<img src=\"%s\">", filepath.Base(pair.Question)),
-			"Back":  fmt.Sprintf("<img src=\"%s\">", filepath.Base(pair.Answer)),
-			"Hash":  pair.Hash,
-		},
+		ModelName: s.modelName,
+		Fields:    s.noteFields(source, pair),
 		Options: map[string]interface{}{
 			"allowDuplicate": false,
 		},
-		Tags: []string{"notesankify", getDeckNameUnderscoreSeparatedForTag(deckName)},
+		Tags: addedTags,
 	}
 
 	request := AnkiConnectRequest{
@@ -274,17 +703,178 @@ func (s *Service) AddFlashcard(deckName string, pair pdf.ImagePair, pageNum int,
 		},
 	}
 
-	_, err = s.sendRequest(request)
+	result, err := s.sendRequest(request)
 	if err != nil {
 		return fmt.Errorf("failed to add note: %w", err)
 	}
 
+	var noteId int
+	if err := json.Unmarshal(result, &noteId); err != nil {
+		s.logger.Debug("Warning: failed to parse ID of the added note: %v", err)
+	}
+	s.recordCard(noteId, deckName, source, pair, noteTags)
+	if noteId != 0 {
+		report.addDeckNote(deckName, noteId)
+		if err := s.applyCardDirectives(noteId, directives); err != nil {
+			s.logger.Info("Warning: failed to apply the directives of page %d: %v", pageNum, err)
+		}
+	}
+	if pair.BlankAnswer {
+		action := "added tagged " + UnfinishedTagName
+		if s.unfinishedPolicy == UnfinishedSuspend {
+			action = "added suspended, tagged " + UnfinishedTagName
+		}
+		report.addUnfinished(deckName, pair, pageNum, action)
+	}
+
 	s.logger.Debug("Successfully added new flashcard with hash: %s", pair.Hash)
 	report.AddedCount++
 	return nil
 }
 
-func (s *Service) AddAllFlashcards(deckName string, pairs []pdf.ImagePair, pageNumbers []int, report *ProcessingReport) error {
+// storeCardMedia uploads the images of a card to Anki's media folder.
+func (s *Service) storeCardMedia(pair pdf.ImagePair) error {
+	questionImage, err := s.readAndEncodeImage(pair.Question)
+	if err != nil {
+		return fmt.Errorf("failed to read question image: %w", err)
+	}
+
+	answerImage, err := s.readAndEncodeImage(pair.Answer)
+	if err != nil {
+		return fmt.Errorf("failed to read answer image: %w", err)
+	}
+
+	media := map[string]string{
+		filepath.Base(pair.Question): questionImage,
+		filepath.Base(pair.Answer):   answerImage,
+	}
+	for _, extra := range pair.Extras {
+		if media[filepath.Base(extra.Path)], err = s.readAndEncodeImage(extra.Path); err != nil {
+			return fmt.Errorf("failed to read %s image: %w", extra.Name, err)
+		}
+	}
+	if err := s.storeMediaFiles(media); err != nil {
+		return fmt.Errorf("failed to store media files: %w", err)
+	}
+	return nil
+}
+
+// noteFields returns the fields of a card's note.
+func (s *Service) noteFields(source SourceFile, pair pdf.ImagePair) map[string]string {
+	fields := map[string]string{
+		"Front":        imageTag(filepath.Base(pair.Question), pair.QuestionText),
+		"Back":         imageTag(filepath.Base(pair.Answer), pair.AnswerText),
+		"Hash":         pair.Hash,
+		"QuestionText": html.EscapeString(pair.QuestionText),
+		"AnswerText":   html.EscapeString(pair.AnswerText),
+	}
+	for name, value := range SourceFields(source, pair.Source, s.pageLinks) {
+		fields[name] = value
+	}
+	for _, extra := range pair.Extras {
+		if field, ok := regionFields[extra.Name]; ok {
+			fields[field] = imageTag(filepath.Base(extra.Path), extra.Text)
+		}
+	}
+	return fields
+}
+
+// recordCard remembers where a card's note came from and the tags NotesAnkify gave
+// it, when the service keeps state. An empty deckName, for a note whose deck was not
+// checked, keeps the deck recorded before.
+func (s *Service) recordCard(noteId int, deckName string, source SourceFile, pair pdf.ImagePair, tags []string) {
+	if s.state == nil {
+		return
+	}
+	if deckName == "" {
+		if previous, found := s.state.Lookup(pair.Hash); found && previous.NoteID == noteId {
+			deckName = previous.DeckName
+		}
+	}
+
+	sourcePath := source.AbsolutePath
+	if absolute, err := filepath.Abs(sourcePath); err == nil {
+		sourcePath = absolute
+	}
+	s.state.Record(state.Card{
+		Hash:         pair.Hash,
+		NoteID:       noteId,
+		DeckName:     deckName,
+		SourcePath:   sourcePath,
+		RelativePath: filepath.ToSlash(source.RelativePath),
+		PageNumber:   pair.Source.PageNumber,
+		CellIndex:    pair.Source.CellIndex,
+		Tags:         tags,
+		Unfinished:   pair.BlankAnswer,
+	})
+}
+
+// LocateNote finds the source of a note, given its content hash or note ID, from
+// the Hash, Source and Page fields of the note in Anki.
+func (s *Service) LocateNote(hashOrNoteID string) (state.Card, error) {
+	noteIds := []int{}
+	if noteId, err := strconv.Atoi(hashOrNoteID); err == nil {
+		noteIds = append(noteIds, noteId)
+	} else {
+		result, err := s.sendRequest(AnkiConnectRequest{
+			Action:  "findNotes",
+			Version: ANKI_CONNECT_VERSION,
+			Params:  map[string]interface{}{"query": fmt.Sprintf("Hash:%s", hashOrNoteID)},
+		})
+		if err != nil {
+			return state.Card{}, fmt.Errorf("failed to search notes: %w", err)
+		}
+		if err := json.Unmarshal(result, &noteIds); err != nil {
+			return state.Card{}, fmt.Errorf("failed to parse note IDs: %w", err)
+		}
+	}
+	if len(noteIds) == 0 {
+		return state.Card{}, fmt.Errorf("no note with hash %s", hashOrNoteID)
+	}
+
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "notesInfo",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"notes": noteIds[:1]},
+	})
+	if err != nil {
+		return state.Card{}, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	var notes []struct {
+		NoteId int `json:"noteId"`
+		Fields map[string]struct {
+			Value string `json:"value"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(result, &notes); err != nil {
+		return state.Card{}, fmt.Errorf("failed to parse note: %w", err)
+	}
+	// notesInfo answers unknown IDs with an empty object.
+	if len(notes) == 0 || notes[0].NoteId == 0 {
+		return state.Card{}, fmt.Errorf("no note %s", hashOrNoteID)
+	}
+
+	note := notes[0]
+	card := state.Card{NoteID: note.NoteId, Hash: note.Fields["Hash"].Value}
+	var ok bool
+	card.SourcePath, card.RelativePath, ok = ParseSourceField(note.Fields["Source"].Value)
+	if !ok {
+		return card, fmt.Errorf("note %d has no source, it was added before notes recorded one", note.NoteId)
+	}
+	card.PageNumber, _ = strconv.Atoi(strings.TrimSpace(note.Fields["Page"].Value))
+	return card, nil
+}
+
+// imageTag embeds a card image, using the card's typed text as alt text.
+func imageTag(filename, altText string) string {
+	if altText == "" {
+		return fmt.Sprintf("<img src=\"%s\">", filename)
+	}
+	return fmt.Sprintf("<img src=\"%s\" alt=\"%s\">", filename, html.EscapeString(altText))
+}
+
+func (s *Service) AddAllFlashcards(deckName string, source SourceFile, pairs []pdf.ImagePair, pageNumbers []int, report *ProcessingReport, tags ...string) error {
 	var successCount, failCount int
 
 	if err := s.ensureModelExists(); err != nil {
@@ -292,7 +882,7 @@ func (s *Service) AddAllFlashcards(deckName string, pairs []pdf.ImagePair, pageN
 	}
 
 	for index, pair := range pairs {
-		if err := s.AddFlashcard(deckName, pair, pageNumbers[index], report); err != nil {
+		if err := s.AddFlashcard(deckName, source, pair, pageNumbers[index], report, tags...); err != nil {
 			s.logger.Debug("Error adding flashcard: %v", err)
 			failCount++
 			continue
@@ -396,6 +986,9 @@ func (r *ProcessingReport) Print(logger *logger.Logger) {
 	fmt.Printf("\nTotal PDFs Processed: %d", r.ProcessedPDFs)
 	fmt.Printf("\nTotal Flashcards Found: %d", r.TotalFlashcards)
 	fmt.Printf("\nCards Added: %d", r.AddedCount)
+	if r.UpdatedCount > 0 {
+		fmt.Printf("\nUnfinished Cards Completed: %d", r.UpdatedCount)
+	}
 	fmt.Printf("\nCards Skipped (Duplicates): %d", r.SkippedCount)
 	fmt.Printf("\nTime Taken: %v", r.TimeTaken())
 
@@ -409,4 +1002,164 @@ func (r *ProcessingReport) Print(logger *logger.Logger) {
 				card.Hash)
 		}
 	}
+
+	if len(r.MovedNotes) > 0 {
+		fmt.Printf("\n\n\nNotes In Another Deck Than Their PDF:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, note := range r.MovedNotes {
+			fmt.Printf("- %s\n", note.Summary())
+		}
+	}
+
+	if len(r.UnfinishedCards) > 0 {
+		fmt.Printf("\n\n\nUnfinished Cards (Blank Answers):")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, card := range r.UnfinishedCards {
+			fmt.Printf("- %s (Page %d, Hash:%s): %s\n", card.DeckName, card.PageNumber, card.Hash, card.Action)
+		}
+	}
+
+	if len(r.PresetMatches) > 0 {
+		fmt.Printf("\n\n\nMatched Page Sizes:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, match := range r.PresetMatches {
+			fmt.Printf("- %s (Page %d): %s\n", match.FilePath, match.PageNumber, match.Preset)
+		}
+	}
+
+	if len(r.PageSelections) > 0 {
+		fmt.Printf("\n\n\nPage Selections:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, selection := range r.PageSelections {
+			fmt.Printf("- %s: pages %s of %d\n", selection.FilePath, selection.SelectedPages, selection.PageCount)
+		}
+	}
+
+	if len(r.SkippedPages) > 0 {
+		fmt.Printf("\n\n\nPages Not Fully Turned Into Cards:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		files, byFile := r.SkippedPagesByFile()
+		for _, file := range files {
+			fmt.Printf("- %s: %d pages (run `notesankify inspect` on the file for details)\n", file, len(byFile[file]))
+		}
+	}
+
+	if len(r.EncryptedPDFs) > 0 {
+		fmt.Printf("\n\n\nEncrypted PDFs Skipped:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, file := range r.EncryptedPDFs {
+			fmt.Printf("- %s: %s\n", file.FilePath, file.Reason)
+		}
+	}
+
+	if len(r.RenderFailures) > 0 {
+		fmt.Printf("\n\n\nRender Failures:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, failure := range r.RenderFailures {
+			fmt.Printf("- %s: %s\n", failure.Location(), failure.Reason)
+		}
+	}
+
+	if len(r.UnpairedPages) > 0 {
+		fmt.Printf("\n\n\nUnpaired Pages:")
+		fmt.Printf("\n-------------------------------------------------------------\n")
+		for _, page := range r.UnpairedPages {
+			fmt.Printf("- %s (Page %d)\n", page.FilePath, page.PageNumber)
+		}
+	}
+}
+
+func (r *ProcessingReport) AddPresetMatches(filePath string, pagePresets map[int]string) {
+	pageNumbers := make([]int, 0, len(pagePresets))
+	for pageNum := range pagePresets {
+		pageNumbers = append(pageNumbers, pageNum)
+	}
+	sort.Ints(pageNumbers)
+
+	for _, pageNum := range pageNumbers {
+		r.PresetMatches = append(r.PresetMatches, PresetMatchInfo{
+			FilePath:   filePath,
+			PageNumber: pageNum,
+			Preset:     pagePresets[pageNum],
+		})
+	}
+}
+
+// AddPageSelection records the pages processed in the PDF, if a selection applied to it.
+func (r *ProcessingReport) AddPageSelection(filePath string, selectedPages string, pageCount int) {
+	if selectedPages == "" {
+		return
+	}
+	r.PageSelections = append(r.PageSelections, PageSelectionInfo{
+		FilePath:      filePath,
+		SelectedPages: selectedPages,
+		PageCount:     pageCount,
+	})
+}
+
+// AddPageDecisions keeps the decisions for pages that did not become cards, or
+// that lost some of their cells to errors.
+func (r *ProcessingReport) AddPageDecisions(filePath string, decisions []pdf.PageDecision) {
+	for _, decision := range decisions {
+		if decision.Outcome != pdf.PageCard || len(decision.FailedCells) > 0 {
+			r.SkippedPages = append(r.SkippedPages, SkippedPageInfo{
+				FilePath: filePath,
+				Decision: decision,
+			})
+		}
+	}
+}
+
+// SkippedPagesByFile groups the skipped pages by file, in the order files were processed.
+func (r *ProcessingReport) SkippedPagesByFile() ([]string, map[string][]pdf.PageDecision) {
+	var files []string
+	byFile := make(map[string][]pdf.PageDecision)
+	for _, page := range r.SkippedPages {
+		if _, seen := byFile[page.FilePath]; !seen {
+			files = append(files, page.FilePath)
+		}
+		byFile[page.FilePath] = append(byFile[page.FilePath], page.Decision)
+	}
+	return files, byFile
+}
+
+func (r *ProcessingReport) AddUnpairedPages(filePath string, pageNumbers []int) {
+	for _, pageNum := range pageNumbers {
+		r.UnpairedPages = append(r.UnpairedPages, UnpairedPageInfo{
+			FilePath:   filePath,
+			PageNumber: pageNum,
+		})
+	}
+}
+
+// RecordEncryptedPDF adds the file to the report if err means it was skipped for
+// being encrypted, and reports whether it did.
+func (r *ProcessingReport) RecordEncryptedPDF(filePath string, err error) bool {
+	if !pdf.IsEncryptedError(err) {
+		return false
+	}
+	reason := "no password supplied"
+	if errors.Is(err, pdf.ErrWrongPassword) {
+		reason = "wrong password"
+	}
+	r.EncryptedPDFs = append(r.EncryptedPDFs, EncryptedPDFInfo{FilePath: filePath, Reason: reason})
+	return true
+}
+
+func (r *ProcessingReport) AddRenderFailures(filePath string, failures []pdf.RenderFailure) {
+	for _, failure := range failures {
+		r.RenderFailures = append(r.RenderFailures, RenderFailureInfo{
+			FilePath:   filePath,
+			PageNumber: failure.PageNumber,
+			Reason:     failure.Reason,
+		})
+	}
+}
+
+// Location names the file, and the page when the failure was on one.
+func (f RenderFailureInfo) Location() string {
+	if f.PageNumber == 0 {
+		return f.FilePath
+	}
+	return fmt.Sprintf("%s (Page %d)", f.FilePath, f.PageNumber)
 }
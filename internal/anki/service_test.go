@@ -0,0 +1,77 @@
+package anki_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Note types", func() {
+	var stub *ankiStub
+
+	BeforeEach(func() {
+		stub = newAnkiStub()
+		stub.on("modelNames", []string{"NotesAnkify", "Basic", "Mine"})
+	})
+
+	It("should create a missing note type", func() {
+		Expect(stub.service().WithModel("NotesAnkify A4").EnsureModelExists()).To(Succeed())
+
+		created := stub.sent("createModel")
+		Expect(created).To(HaveLen(1))
+		Expect(created[0].Params["modelName"]).To(Equal("NotesAnkify A4"))
+		Expect(created[0].Params["inOrderFields"]).To(HaveExactElements(
+			"SortOrder", "Front", "Back", "Hash", "QuestionText", "AnswerText", "Source", "Page", "Hint", "Extra"))
+	})
+
+	It("should add newer fields and template sections to the NotesAnkify note type", func() {
+		stub.on("modelFieldNames", []string{"Front", "Back", "Hash"})
+		stub.on("modelTemplates", map[string]interface{}{
+			"Card 1": map[string]string{"Front": "{{Front}}", "Back": "{{FrontSide}}<hr id=answer>{{Back}}"},
+		})
+		stub.on("modelStyling", map[string]string{"css": ".card {}"})
+
+		Expect(stub.service().EnsureModelExists()).To(Succeed())
+
+		var added []interface{}
+		for _, request := range stub.sent("modelFieldAdd") {
+			added = append(added, request.Params["fieldName"])
+		}
+		Expect(added).To(ContainElements("Source", "Hint", "Extra"))
+		Expect(stub.sent("updateModelTemplates")).To(HaveLen(1))
+		Expect(stub.sent("updateModelStyling")).To(HaveLen(1))
+		Expect(stub.sent("findModelsByName")).To(HaveLen(1))
+	})
+
+	It("should refuse a note type without the fields NotesAnkify needs", func() {
+		stub.on("modelFieldNames", []string{"Front", "Back"})
+
+		err := stub.service().WithModel("Basic").EnsureModelExists()
+		Expect(err).To(MatchError(ContainSubstring("Basic lacks fields NotesAnkify needs (Hash)")))
+		Expect(stub.sent("modelFieldAdd")).To(BeEmpty())
+		Expect(stub.sent("updateModelTemplates")).To(BeEmpty())
+	})
+
+	It("should leave a user's note type with the needed fields unchanged", func() {
+		stub.on("modelFieldNames", []string{"Front", "Back", "Hash"})
+		stub.on("modelTemplates", map[string]interface{}{
+			"Card 1": map[string]string{"Front": "{{Front}}", "Back": "{{Back}}"},
+		})
+
+		Expect(stub.service().WithModel("Basic").EnsureModelExists()).To(Succeed())
+		Expect(stub.sent("modelFieldAdd")).To(BeEmpty())
+		Expect(stub.sent("updateModelTemplates")).To(BeEmpty())
+		Expect(stub.sent("updateModelStyling")).To(BeEmpty())
+	})
+
+	It("should extend a named note type NotesAnkify created", func() {
+		stub.on("modelFieldNames", []string{"Front", "Back", "Hash"})
+		stub.on("modelTemplates", map[string]interface{}{
+			"Card 1": map[string]string{"Front": `{{Front}} <div class="hash">{{Hash}}</div>`, "Back": "{{Back}}"},
+		})
+		stub.on("modelStyling", map[string]string{"css": ".card {}"})
+
+		Expect(stub.service().WithModel("Mine").EnsureModelExists()).To(Succeed())
+		Expect(stub.sent("modelFieldAdd")).NotTo(BeEmpty())
+		Expect(stub.sent("modelFieldAdd")[0].Params["modelName"]).To(Equal("Mine"))
+	})
+})
@@ -0,0 +1,169 @@
+package anki
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/internal/state"
+)
+
+// UnfinishedPolicy decides what happens to cards whose answer is still blank.
+type UnfinishedPolicy string
+
+const (
+	UnfinishedSkip    UnfinishedPolicy = "skip"    // leave them out until the answer is written
+	UnfinishedSuspend UnfinishedPolicy = "suspend" // add them suspended and tagged UnfinishedTagName
+	UnfinishedTag     UnfinishedPolicy = "tag"     // add them tagged UnfinishedTagName
+)
+
+// UnfinishedTagName is the tag of notes added with a blank answer, by which they
+// are found again once the answer is written.
+const UnfinishedTagName = "unfinished"
+
+// ParseUnfinishedPolicy parses a policy name. An empty name turns the detection of
+// unfinished cards off and returns "".
+func ParseUnfinishedPolicy(name string) (UnfinishedPolicy, error) {
+	switch policy := UnfinishedPolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "", UnfinishedSkip, UnfinishedSuspend, UnfinishedTag:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown unfinished card policy %q (expected skip, suspend or tag)", name)
+	}
+}
+
+// UnfinishedCardInfo is a card found with a blank answer, or a note added that way
+// whose answer has since been written.
+type UnfinishedCardInfo struct {
+	DeckName   string
+	Hash       string
+	PageNumber int
+	Action     string
+}
+
+// SetUnfinishedPolicy sets what happens to cards with a blank answer.
+func (s *Service) SetUnfinishedPolicy(policy UnfinishedPolicy) {
+	s.unfinishedPolicy = policy
+}
+
+func (r *ProcessingReport) addUnfinished(deckName string, pair pdf.ImagePair, pageNum int, action string) {
+	r.UnfinishedCards = append(r.UnfinishedCards, UnfinishedCardInfo{
+		DeckName:   deckName,
+		Hash:       pair.Hash,
+		PageNumber: pageNum,
+		Action:     action,
+	})
+}
+
+// updateUnfinishedNote updates the note added from the same place of the PDF while
+// its answer was blank, instead of adding the card again. It reports false when
+// there is no such note.
+func (s *Service) updateUnfinishedNote(deckName string, source SourceFile, pair pdf.ImagePair, pageNum int, report *ProcessingReport) (bool, error) {
+	previous, found, err := s.findUnfinishedNote(source, pair)
+	if err != nil {
+		s.logger.Debug("Warning: failed to look for an unfinished note of page %d: %v", pageNum, err)
+		return false, nil
+	}
+	if !found {
+		return false, nil
+	}
+
+	if err := s.storeCardMedia(pair); err != nil {
+		return true, err
+	}
+	if err := s.updateNoteFields(previous.NoteID, s.noteFields(source, pair)); err != nil {
+		return true, err
+	}
+
+	if previous.DeckName != "" {
+		deckName = previous.DeckName
+	}
+	action := "note updated, answer still blank"
+	if !pair.BlankAnswer {
+		if err := s.finishNote(previous.NoteID, !pair.Directives.Suspend); err != nil {
+			return true, err
+		}
+		action = "answer filled in, note updated"
+		report.UpdatedCount++
+	}
+	s.logger.Info("Updated unfinished note %d from page %d", previous.NoteID, pageNum)
+
+	if s.state != nil && previous.Hash != "" {
+		s.state.Forget(previous.Hash)
+	}
+	s.recordCard(previous.NoteID, deckName, source, pair, previous.Tags)
+	report.addUnfinished(deckName, pair, pageNum, action)
+	return true, nil
+}
+
+// findUnfinishedNote looks for an unfinished note from the card's place in the PDF,
+// in the state file or else by the note's SortOrder field and unfinished tag in Anki.
+func (s *Service) findUnfinishedNote(source SourceFile, pair pdf.ImagePair) (state.Card, bool, error) {
+	if s.state != nil {
+		sourcePath := source.AbsolutePath
+		if absolute, err := filepath.Abs(sourcePath); err == nil {
+			sourcePath = absolute
+		}
+		card, found := s.state.LookupLocation(sourcePath, pair.Source.PageNumber, pair.Source.CellIndex)
+		if found {
+			return card, card.Unfinished && card.NoteID != 0 && card.Hash != pair.Hash, nil
+		}
+	}
+
+	sortOrder := SourceFields(source, pair.Source, false)["SortOrder"]
+	result, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "findNotes",
+		Version: ANKI_CONNECT_VERSION,
+		Params: map[string]interface{}{
+			"query": fmt.Sprintf(`"note:%s" "SortOrder:%s" tag:%s`,
+				searchEscape(s.modelName), searchEscape(sortOrder), UnfinishedTagName),
+		},
+	})
+	if err != nil {
+		return state.Card{}, false, fmt.Errorf("failed to search notes: %w", err)
+	}
+
+	var noteIds []int
+	if err := json.Unmarshal(result, &noteIds); err != nil {
+		return state.Card{}, false, fmt.Errorf("failed to parse note IDs: %w", err)
+	}
+	if len(noteIds) == 0 {
+		return state.Card{}, false, nil
+	}
+	return state.Card{NoteID: noteIds[0]}, true, nil
+}
+
+// finishNote removes the unfinished tag from a note. Under the suspend policy, which
+// added the note suspended, its cards are also unsuspended when unsuspend is set.
+func (s *Service) finishNote(noteId int, unsuspend bool) error {
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "removeTags",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"notes": []int{noteId}, "tags": UnfinishedTagName},
+	}); err != nil {
+		return fmt.Errorf("failed to remove tag %s from note %d: %w", UnfinishedTagName, noteId, err)
+	}
+	if s.unfinishedPolicy != UnfinishedSuspend || !unsuspend {
+		return nil
+	}
+
+	cards, _, err := s.noteCards(noteId)
+	if err != nil {
+		return err
+	}
+	if _, err := s.sendRequest(AnkiConnectRequest{
+		Action:  "unsuspend",
+		Version: ANKI_CONNECT_VERSION,
+		Params:  map[string]interface{}{"cards": cards},
+	}); err != nil {
+		return fmt.Errorf("failed to unsuspend note %d: %w", noteId, err)
+	}
+	return nil
+}
+
+// searchEscape escapes a value for use inside a quoted Anki search term.
+func searchEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `*`, `\*`, `_`, `\_`).Replace(value)
+}
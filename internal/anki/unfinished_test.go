@@ -0,0 +1,111 @@
+package anki_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/anki"
+)
+
+var _ = Describe("Unfinished cards", func() {
+	var (
+		stub   *ankiStub
+		report *anki.ProcessingReport
+		source anki.SourceFile
+	)
+
+	// Note 7 was added from page 1 while its answer was blank.
+	BeforeEach(func() {
+		stub = newAnkiStub()
+		report = &anki.ProcessingReport{}
+		source = anki.SourceFile{RelativePath: "Biology/cells.pdf", AbsolutePath: "/notes/Biology/cells.pdf"}
+
+		stub.onParams("findNotes", func(params map[string]interface{}) interface{} {
+			if strings.Contains(params["query"].(string), "SortOrder:") {
+				return []int{7}
+			}
+			return []int{}
+		})
+		stub.on("addNote", 8)
+		stub.on("notesInfo", []map[string]interface{}{{"noteId": 7, "cards": []int{70}, "tags": []string{anki.UnfinishedTagName}}})
+	})
+
+	unfinishedSearches := func() []string {
+		var queries []string
+		for _, request := range stub.sent("findNotes") {
+			if query := request.Params["query"].(string); strings.Contains(query, "SortOrder:") {
+				queries = append(queries, query)
+			}
+		}
+		return queries
+	}
+
+	It("should find the unfinished note by its tag alone and update it", func() {
+		service := stub.service()
+		service.SetUnfinishedPolicy(anki.UnfinishedTag)
+
+		Expect(service.AddFlashcard("Biology", source, cardPair("aaa", 1), 1, report)).To(Succeed())
+
+		Expect(unfinishedSearches()).To(HaveLen(1))
+		Expect(unfinishedSearches()[0]).To(HaveSuffix(" tag:" + anki.UnfinishedTagName))
+		Expect(unfinishedSearches()[0]).NotTo(ContainSubstring("is:suspended"))
+
+		Expect(stub.sent("updateNoteFields")).To(HaveLen(1))
+		Expect(stub.sent("updateNoteFields")[0].Params["note"]).To(HaveKeyWithValue("id", BeNumerically("==", 7)))
+		Expect(stub.sent("removeTags")).To(HaveLen(1))
+		Expect(stub.sent("removeTags")[0].Params).To(HaveKeyWithValue("tags", anki.UnfinishedTagName))
+		Expect(stub.sent("unsuspend")).To(BeEmpty())
+		Expect(stub.sent("addNote")).To(BeEmpty())
+		Expect(report.UpdatedCount).To(Equal(1))
+	})
+
+	It("should unsuspend the note once its answer is written under the suspend policy", func() {
+		service := stub.service()
+		service.SetUnfinishedPolicy(anki.UnfinishedSuspend)
+
+		Expect(service.AddFlashcard("Biology", source, cardPair("aaa", 1), 1, report)).To(Succeed())
+
+		Expect(stub.sent("removeTags")).To(HaveLen(1))
+		Expect(stub.sent("unsuspend")).To(HaveLen(1))
+		Expect(stub.sent("unsuspend")[0].Params["cards"]).To(ConsistOf(BeNumerically("==", 70)))
+	})
+
+	It("should tag the blank cards it adds suspended", func() {
+		stub.on("findNotes", []int{})
+		service := stub.service()
+		service.SetUnfinishedPolicy(anki.UnfinishedSuspend)
+		pair := cardPair("aaa", 1)
+		pair.BlankAnswer = true
+
+		Expect(service.AddFlashcard("Biology", source, pair, 1, report)).To(Succeed())
+
+		Expect(stub.sent("addNote")).To(HaveLen(1))
+		Expect(stub.sent("addNote")[0].Params["note"]).To(HaveKeyWithValue("tags", ContainElement(anki.UnfinishedTagName)))
+		Expect(stub.sent("suspend")).To(HaveLen(1))
+	})
+
+	It("should not look for unfinished notes under the skip policy", func() {
+		service := stub.service()
+		service.SetUnfinishedPolicy(anki.UnfinishedSkip)
+
+		Expect(service.AddFlashcard("Biology", source, cardPair("aaa", 1), 1, report)).To(Succeed())
+
+		Expect(unfinishedSearches()).To(BeEmpty())
+		Expect(stub.sent("updateNoteFields")).To(BeEmpty())
+		Expect(stub.sent("addNote")).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Unfinished card policies", func() {
+	It("should parse the unfinished card policies", func() {
+		for name, expected := range map[string]anki.UnfinishedPolicy{"": "", "skip": anki.UnfinishedSkip, "Suspend": anki.UnfinishedSuspend, "tag": anki.UnfinishedTag} {
+			policy, err := anki.ParseUnfinishedPolicy(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(expected))
+		}
+		_, err := anki.ParseUnfinishedPolicy("delete")
+		Expect(err).To(HaveOccurred())
+	})
+})
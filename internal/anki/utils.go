@@ -1,15 +1,24 @@
 package anki
 
 import (
+	"fmt"
+	"html"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
 )
 
 const (
 	ANKI_CONNECT_VERSION = 6
 )
 
-func GetDeckNameFromPath(rootPrefix string, relativePath string) string {
+// GetDeckNameFromPath builds the deck for a PDF from its folders and file name.
+// Outline titles, if given, are appended as further sub-decks.
+func GetDeckNameFromPath(rootPrefix string, relativePath string, outline ...string) string {
 	// Get directory path without the file name
 	dirPath := filepath.Dir(relativePath)
 	if dirPath == "." {
@@ -35,6 +44,127 @@ func GetDeckNameFromPath(rootPrefix string, relativePath string) string {
 	// Add filename as final part
 	parts = append(parts, fileName)
 
+	// Add bookmark titles, which must not introduce levels of their own
+	for _, title := range outline {
+		title = strings.TrimSpace(strings.ReplaceAll(title, "::", ":"))
+		if title != "" {
+			parts = append(parts, title)
+		}
+	}
+
 	// Join with Anki's separator
 	return strings.Join(parts, "::")
 }
+
+// DeckPath returns the path a PDF's deck is named after. With useTitle, the file
+// name is replaced by the document's Title metadata when it has one.
+func DeckPath(relativePath string, metadata pdf.Metadata, useTitle bool) string {
+	title := strings.NewReplacer("/", "-", "\\", "-", "::", ":").Replace(metadata.Title)
+	title = strings.TrimSpace(title)
+	if !useTitle || title == "" {
+		return relativePath
+	}
+	return filepath.Join(filepath.Dir(relativePath), title+filepath.Ext(relativePath))
+}
+
+// MetadataTags turns the PDF's Keywords metadata into Anki tags, which cannot
+// contain spaces.
+func MetadataTags(metadata pdf.Metadata) []string {
+	return NormalizeTags(metadata.Keywords)
+}
+
+// NormalizeTags joins the words of each tag with underscores, as Anki tags cannot
+// contain spaces, and drops empty ones.
+func NormalizeTags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		if tag := strings.Join(strings.Fields(value), "_"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// DeckCards are the cards of one PDF that belong in the same deck.
+type DeckCards struct {
+	DeckName    string
+	Pairs       []pdf.ImagePair
+	PageNumbers []int
+}
+
+// GroupCardsByDeck splits a PDF's cards by deck, using each card's outline path.
+// Decks are returned in the order their first card appears.
+func GroupCardsByDeck(rootPrefix, relativePath string, pairs []pdf.ImagePair, pageNumbers []int) []DeckCards {
+	var groups []DeckCards
+	index := make(map[string]int)
+	for i, pair := range pairs {
+		deckName := GetDeckNameFromPath(rootPrefix, relativePath, pair.Source.Outline...)
+		position, ok := index[deckName]
+		if !ok {
+			position = len(groups)
+			index[deckName] = position
+			groups = append(groups, DeckCards{DeckName: deckName})
+		}
+		groups[position].Pairs = append(groups[position].Pairs, pair)
+		groups[position].PageNumbers = append(groups[position].PageNumbers, pageNumbers[i])
+	}
+	return groups
+}
+
+// SourceFile is the PDF cards are added from.
+type SourceFile struct {
+	RelativePath string // shown on the card
+	AbsolutePath string // target of the card's link
+}
+
+// SourceFields fills the Source, Page and SortOrder fields of a card's note. Source
+// links the file:// URL of the PDF, at the card's page with pageLink. SortOrder is the
+// path followed by zero-padded page and cell numbers, so sorting the browser by it
+// lists cards in page order. It is the sort field of the note types NotesAnkify
+// creates; older ones are sorted by it once the user picks it, see checkSortField.
+func SourceFields(source SourceFile, card pdf.CardSource, pageLink bool) map[string]string {
+	link := FileURL(source.AbsolutePath)
+	if pageLink {
+		link += fmt.Sprintf("#page=%d", card.PageNumber)
+	}
+	relativePath := filepath.ToSlash(source.RelativePath)
+
+	return map[string]string{
+		"Source":    fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(link), html.EscapeString(relativePath)),
+		"Page":      fmt.Sprint(card.PageNumber),
+		"SortOrder": html.EscapeString(fmt.Sprintf("%s %05d.%02d", relativePath, card.PageNumber, card.CellIndex+1)),
+	}
+}
+
+// FileURL returns the file:// URL of a path, made absolute.
+func FileURL(path string) string {
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive letters
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+var sourceLinkPattern = regexp.MustCompile(`(?s)<a href="([^"]*)">(.*?)</a>`)
+
+// ParseSourceField reads the PDF's absolute and relative paths back from a note's
+// Source field, as written by SourceFields.
+func ParseSourceField(value string) (absolutePath, relativePath string, ok bool) {
+	match := sourceLinkPattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", "", false
+	}
+
+	link, err := url.Parse(html.UnescapeString(match[1]))
+	if err != nil || link.Scheme != "file" {
+		return "", "", false
+	}
+	absolutePath = link.Path
+	if runtime.GOOS == "windows" {
+		absolutePath = strings.TrimPrefix(absolutePath, "/")
+	}
+	return filepath.FromSlash(absolutePath), filepath.FromSlash(html.UnescapeString(match[2])), true
+}
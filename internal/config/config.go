@@ -1,18 +1,126 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/kpauljoseph/notesankify/pkg/models"
 	"github.com/kpauljoseph/notesankify/pkg/utils"
 	"gopkg.in/yaml.v3"
 	"os"
+	"time"
 )
 
 type Config struct {
 	PDFSourceDir  string `yaml:"pdf_source_dir"`
 	AnkiDeckName  string `yaml:"anki_deck_name"`
 	FlashcardSize struct {
-		Width  float64 `yaml:"width"`
-		Height float64 `yaml:"height"`
+		Width     float64            `yaml:"width"`
+		Height    float64            `yaml:"height"`
+		Tolerance float64            `yaml:"tolerance"` // allowed deviation in points
+		Layout    *models.CardLayout `yaml:"layout"`    // layout for pages of this size
 	} `yaml:"flashcard_size"`
+	// Presets defines named page sizes in addition to the built-in ones.
+	Presets []models.PageDimensions `yaml:"presets"`
+	// AcceptPresets names further presets accepted alongside flashcard_size.
+	AcceptPresets  []string           `yaml:"accept_presets"`
+	Layout         *models.CardLayout `yaml:"layout"` // default layout for all other pages
+	PostProcessing struct {
+		EraseMarkers        bool     `yaml:"erase_markers"`
+		TrimWhitespace      bool     `yaml:"trim_whitespace"`
+		TrimPadding         int      `yaml:"trim_padding"`
+		BackgroundColors    []string `yaml:"background_colors"` // e.g. "#c8d8f0"
+		BackgroundTolerance int      `yaml:"background_tolerance"`
+		BeforeHash          bool     `yaml:"before_hash"`
+	} `yaml:"post_processing"`
+	// Scan cleans up image-only pages such as photographed or scanned paper cards.
+	Scan struct {
+		Enabled           bool    `yaml:"enabled"`
+		Deskew            bool    `yaml:"deskew"`
+		NormalizeContrast bool    `yaml:"normalize_contrast"`
+		Threshold         bool    `yaml:"threshold"`
+		DetectCard        bool    `yaml:"detect_card"`
+		MaxSkewDegrees    float64 `yaml:"max_skew_degrees"`
+	} `yaml:"scan"`
+	// OCR reads handwritten markers and card text with a locally installed engine.
+	OCR struct {
+		Enabled  bool   `yaml:"enabled"`
+		Command  string `yaml:"command"`   // tesseract compatible executable
+		Language string `yaml:"language"`  // e.g. "eng" or "eng+deu"
+		CacheDir string `yaml:"cache_dir"` // recognized text keyed by image hash
+	} `yaml:"ocr"`
+	// OutlineDecks files cards into sub-decks named after the PDF's bookmarks.
+	OutlineDecks struct {
+		Enabled  bool `yaml:"enabled"`
+		MaxDepth int  `yaml:"max_depth"` // bookmark levels used, 0 for all
+	} `yaml:"outline_decks"`
+	// DeckNames controls how decks are named. UseTitle names them after the PDF's
+	// Title metadata instead of the file name, where the PDF has one.
+	DeckNames struct {
+		UseTitle bool `yaml:"use_title"`
+	} `yaml:"deck_names"`
+	// Tags are added to every card, NoteModel names the note type cards are added as
+	// (NotesAnkify by default). Folders can change both with a .notesankify.yaml.
+	Tags      []string `yaml:"tags"`
+	NoteModel string   `yaml:"note_model"`
+	// SourceLinks controls the link to the PDF in each card's footer. PageAnchor
+	// adds #page=N so the PDF opens at the card's page.
+	SourceLinks struct {
+		PageAnchor bool `yaml:"page_anchor"`
+	} `yaml:"source_links"`
+	// StateFile remembers the notes added from every PDF page, for `notesankify
+	// locate`. It defaults to state.json in the user's configuration directory.
+	StateFile string `yaml:"state_file"`
+	// MovedNotes is what happens to notes found in another deck than their PDF's
+	// path gives, after PDFs were moved: keep, report (the default) or move.
+	MovedNotes string `yaml:"moved_notes"`
+	// ExtraRegions are the markers of further boxes on a card, such as "HINT" or
+	// "NOTES". A box runs from its marker to the next marker below, unless the
+	// layout's split has a region named "hint" or "extra".
+	ExtraRegions struct {
+		Hint  string `yaml:"hint"`  // shown on the front behind a click
+		Extra string `yaml:"extra"` // shown on the back below the answer
+	} `yaml:"extra_regions"`
+	// Directives are instructions written on cards, such as "#tag anatomy",
+	// "DECK: Exam::Hard", "!suspend" or "!flag red". Mask blanks them out of the images.
+	Directives struct {
+		Enabled bool `yaml:"enabled"`
+		Mask    bool `yaml:"mask"`
+	} `yaml:"directives"`
+	// UnfinishedCards are cards whose answer is still blank. Policy is skip, suspend
+	// or tag; empty leaves them undetected. Threshold is the ink coverage below which
+	// an answer is blank, 0.002 when zero.
+	UnfinishedCards struct {
+		Policy    string  `yaml:"policy"`
+		Threshold float64 `yaml:"threshold"`
+	} `yaml:"unfinished_cards"`
+	// Passwords for encrypted PDFs, matched against the end of each PDF's path.
+	// PasswordEnv names an environment variable to read the password from instead.
+	Passwords []struct {
+		Pattern     string `yaml:"pattern"`
+		Password    string `yaml:"password"`
+		PasswordEnv string `yaml:"password_env"`
+	} `yaml:"passwords"`
+	// PageSelection limits the pages processed in the PDFs matching each pattern,
+	// which is matched like the password patterns; an empty pattern matches every
+	// PDF. Pages are expressions such as "1-10,15,-3..", see pdf.ParsePageSelection.
+	PageSelection []struct {
+		Pattern string `yaml:"pattern"`
+		Pages   string `yaml:"pages"`
+	} `yaml:"page_selection"`
+	// Renderer picks the PDF rendering backend: "fitz" (MuPDF, the default) or
+	// "poppler", which runs a locally installed pdfinfo, pdftotext and pdftoppm.
+	Renderer struct {
+		Name       string `yaml:"name"`
+		PopplerDir string `yaml:"poppler_dir"` // directory of the poppler executables, PATH when empty
+	} `yaml:"renderer"`
+	// Worker renders PDFs in a separate process, so a malformed file cannot crash
+	// the whole run. Timeouts are durations such as "90s"; zero uses the defaults.
+	Worker struct {
+		Enabled       bool          `yaml:"enabled"`
+		PageTimeout   time.Duration `yaml:"page_timeout"`
+		FileTimeout   time.Duration `yaml:"file_timeout"`
+		MemoryLimitMB uint64        `yaml:"memory_limit_mb"`
+	} `yaml:"worker"`
 	Database struct {
 		Host     string `yaml:"host"`
 		Port     int    `yaml:"port"`
@@ -49,5 +157,109 @@ func Load(path string) (*Config, error) {
 		cfg.FlashcardSize.Height = utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT
 	}
 
+	if cfg.Layout != nil {
+		resolved := models.ResolveLayout(*cfg.Layout)
+		cfg.Layout = &resolved
+	}
+	if cfg.FlashcardSize.Layout != nil {
+		resolved := models.ResolveLayout(*cfg.FlashcardSize.Layout)
+		cfg.FlashcardSize.Layout = &resolved
+	}
+
+	for i, preset := range cfg.Presets {
+		if preset.Name == "" || preset.Width <= 0 || preset.Height <= 0 {
+			return nil, fmt.Errorf("preset %d needs a name, width and height", i+1)
+		}
+		if preset.Layout != nil {
+			resolved := models.ResolveLayout(*preset.Layout)
+			cfg.Presets[i].Layout = &resolved
+		}
+	}
+
 	return &cfg, nil
 }
+
+// savedPreset is the on-disk form of a preset written by SavePreset.
+type savedPreset struct {
+	Name   string  `yaml:"name"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+}
+
+// SavePreset writes a named page size into the config file's presets and adds it to
+// accept_presets, replacing any preset of the same name. Other settings and comments
+// are kept; the file is created if it does not exist.
+func SavePreset(path string, preset models.PageDimensions) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse config: top level is not a mapping")
+	}
+
+	var presetNode yaml.Node
+	if err := presetNode.Encode(savedPreset{Name: preset.Name, Width: preset.Width, Height: preset.Height}); err != nil {
+		return fmt.Errorf("failed to encode preset: %w", err)
+	}
+
+	presets := sequenceValue(document, "presets")
+	replaced := false
+	for i, existing := range presets.Content {
+		var saved savedPreset
+		if existing.Decode(&saved) == nil && saved.Name == preset.Name {
+			presets.Content[i] = &presetNode
+			replaced = true
+		}
+	}
+	if !replaced {
+		presets.Content = append(presets.Content, &presetNode)
+	}
+
+	accepted := sequenceValue(document, "accept_presets")
+	alreadyAccepted := false
+	for _, name := range accepted.Content {
+		alreadyAccepted = alreadyAccepted || name.Value == preset.Name
+	}
+	if !alreadyAccepted {
+		accepted.Content = append(accepted.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: preset.Name})
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// sequenceValue returns the sequence stored under key, adding an empty one if the
+// key is missing or not a sequence.
+func sequenceValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			if value.Kind != yaml.SequenceNode {
+				*value = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			}
+			return value
+		}
+	}
+
+	value := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return value
+}
@@ -0,0 +1,97 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/config"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+var _ = Describe("Config", func() {
+	var configPath string
+
+	BeforeEach(func() {
+		configPath = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	})
+
+	Context("saving presets", func() {
+		It("should add and accept the preset while keeping other settings", func() {
+			Expect(os.WriteFile(configPath, []byte("# my notes\nanki_deck_name: \"Biology\"\n"), 0644)).To(Succeed())
+
+			Expect(config.SavePreset(configPath, models.PageDimensions{Name: "planner", Width: 396, Height: 612})).To(Succeed())
+
+			cfg, err := config.Load(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.AnkiDeckName).To(Equal("Biology"))
+			Expect(cfg.Presets).To(HaveLen(1))
+			Expect(cfg.Presets[0].Name).To(Equal("planner"))
+			Expect(cfg.AcceptPresets).To(Equal([]string{"planner"}))
+
+			data, err := os.ReadFile(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("# my notes"))
+		})
+
+		It("should replace a preset with the same name", func() {
+			Expect(config.SavePreset(configPath, models.PageDimensions{Name: "planner", Width: 396, Height: 612})).To(Succeed())
+			Expect(config.SavePreset(configPath, models.PageDimensions{Name: "planner", Width: 400, Height: 600})).To(Succeed())
+
+			cfg, err := config.Load(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Presets).To(HaveLen(1))
+			Expect(cfg.Presets[0].Width).To(Equal(400.0))
+			Expect(cfg.AcceptPresets).To(Equal([]string{"planner"}))
+		})
+	})
+
+	Context("folder overrides", func() {
+		It("should replace inherited settings and add up tags", func() {
+			overridePath := filepath.Join(filepath.Dir(configPath), config.OverrideFileName)
+			Expect(os.WriteFile(overridePath, []byte("layout:\n  name: grid-2\ndeck_names:\n  use_title: false\ntags: [a4]\n"), 0644)).To(Succeed())
+
+			child, err := config.LoadOverrides(overridePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(child.Layout.CellCount()).To(Equal(2))
+
+			var parent config.Overrides
+			parent.Tags = []string{"course", "a4"}
+			parent.NoteModel = "Course"
+			merged := parent.Merge(child)
+			Expect(merged.Tags).To(Equal([]string{"course", "a4"}))
+			Expect(merged.NoteModel).To(Equal("Course"))
+			Expect(merged.UseTitle(true)).To(BeFalse())
+
+			checkMarkers, checkDimensions := true, true
+			dimensions := models.GoodNotesStandardPreset
+			layout := models.SingleCardLayout
+			merged.Apply(&checkMarkers, &checkDimensions, &dimensions, &layout)
+			Expect(checkMarkers).To(BeTrue())
+			Expect(dimensions.Width).To(Equal(models.GoodNotesStandardPreset.Width))
+			Expect(layout.CellCount()).To(Equal(2))
+		})
+
+		It("should read and inherit the page pairing", func() {
+			overridePath := filepath.Join(filepath.Dir(configPath), config.OverrideFileName)
+			Expect(os.WriteFile(overridePath, []byte("processing:\n  page_pairing: Consecutive\n"), 0644)).To(Succeed())
+
+			parent, err := config.LoadOverrides(overridePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parent.Processing.PagePairing).To(Equal("consecutive"))
+			Expect(parent.Merge(config.Overrides{}).Processing.PagePairing).To(Equal("consecutive"))
+
+			Expect(os.WriteFile(overridePath, []byte("processing:\n  page_pairing: sideways\n"), 0644)).To(Succeed())
+			_, err = config.LoadOverrides(overridePath)
+			Expect(err).To(MatchError(ContainSubstring("page_pairing")))
+		})
+	})
+
+	It("should reject presets without a size", func() {
+		Expect(os.WriteFile(configPath, []byte("presets:\n  - name: empty\n"), 0644)).To(Succeed())
+		_, err := config.Load(configPath)
+		Expect(err).To(HaveOccurred())
+	})
+})
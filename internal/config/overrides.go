@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// OverrideFileName is the per-folder configuration file. Its settings apply to the
+// PDFs in its directory and every subdirectory.
+const OverrideFileName = ".notesankify.yaml"
+
+// Overrides are the settings a folder's .notesankify.yaml changes. Unset fields keep
+// the value inherited from the parent folders, the root config and the command line.
+type Overrides struct {
+	// Processing turns the marker and dimension checks on or off and picks how pages
+	// are paired into cards.
+	Processing struct {
+		CheckMarkers    *bool  `yaml:"check_markers"`
+		CheckDimensions *bool  `yaml:"check_dimensions"`
+		PagePairing     string `yaml:"page_pairing"` // none, consecutive or markers
+	} `yaml:"processing"`
+	FlashcardSize struct {
+		Width     float64            `yaml:"width"`
+		Height    float64            `yaml:"height"`
+		Tolerance *float64           `yaml:"tolerance"`
+		Layout    *models.CardLayout `yaml:"layout"`
+	} `yaml:"flashcard_size"`
+	Layout    *models.CardLayout `yaml:"layout"`
+	DeckNames struct {
+		UseTitle *bool `yaml:"use_title"`
+		// Name replaces the folder's own name in deck names. It is not inherited.
+		Name string `yaml:"name"`
+	} `yaml:"deck_names"`
+	Tags      []string `yaml:"tags"`       // added to the tags of the parent folders
+	NoteModel string   `yaml:"note_model"` // note type the cards are added as
+}
+
+// pagePairingModes are the page pairing modes pdf.ParsePairingMode accepts.
+var pagePairingModes = []string{"none", "consecutive", "markers"}
+
+// LoadOverrides reads a folder's .notesankify.yaml.
+func LoadOverrides(path string) (Overrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Overrides{}, err
+	}
+
+	var overrides Overrides
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return Overrides{}, err
+	}
+
+	size := overrides.FlashcardSize
+	if (size.Width > 0) != (size.Height > 0) || size.Width < 0 || size.Height < 0 {
+		return Overrides{}, fmt.Errorf("flashcard_size needs both a width and a height")
+	}
+
+	pairing := strings.ToLower(strings.TrimSpace(overrides.Processing.PagePairing))
+	if pairing != "" && !containsString(pagePairingModes, pairing) {
+		return Overrides{}, fmt.Errorf("unknown page_pairing %q (expected %s)", overrides.Processing.PagePairing, strings.Join(pagePairingModes, ", "))
+	}
+	overrides.Processing.PagePairing = pairing
+
+	if overrides.Layout != nil {
+		resolved := models.ResolveLayout(*overrides.Layout)
+		overrides.Layout = &resolved
+	}
+	if overrides.FlashcardSize.Layout != nil {
+		resolved := models.ResolveLayout(*overrides.FlashcardSize.Layout)
+		overrides.FlashcardSize.Layout = &resolved
+	}
+	return overrides, nil
+}
+
+// Merge returns the settings of a subfolder, child's on top of o's. Tags accumulate
+// and the deck name stays with the folder that sets it.
+func (o Overrides) Merge(child Overrides) Overrides {
+	merged := o
+	merged.DeckNames.Name = ""
+
+	if child.Processing.CheckMarkers != nil {
+		merged.Processing.CheckMarkers = child.Processing.CheckMarkers
+	}
+	if child.Processing.CheckDimensions != nil {
+		merged.Processing.CheckDimensions = child.Processing.CheckDimensions
+	}
+	if child.Processing.PagePairing != "" {
+		merged.Processing.PagePairing = child.Processing.PagePairing
+	}
+	if child.FlashcardSize.Width > 0 {
+		merged.FlashcardSize.Width = child.FlashcardSize.Width
+		merged.FlashcardSize.Height = child.FlashcardSize.Height
+	}
+	if child.FlashcardSize.Tolerance != nil {
+		merged.FlashcardSize.Tolerance = child.FlashcardSize.Tolerance
+	}
+	if child.FlashcardSize.Layout != nil {
+		merged.FlashcardSize.Layout = child.FlashcardSize.Layout
+	}
+	if child.Layout != nil {
+		merged.Layout = child.Layout
+	}
+	if child.DeckNames.UseTitle != nil {
+		merged.DeckNames.UseTitle = child.DeckNames.UseTitle
+	}
+	if child.NoteModel != "" {
+		merged.NoteModel = child.NoteModel
+	}
+
+	merged.Tags = append([]string(nil), o.Tags...)
+	for _, tag := range child.Tags {
+		if !containsString(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	return merged
+}
+
+// Apply changes the processing settings of a PDF by the overrides, except for the
+// page pairing, whose mode belongs to the pdf package; see pdf.Processor.WithOverrides.
+func (o Overrides) Apply(checkMarkers, checkDimensions *bool, dimensions *models.PageDimensions, layout *models.CardLayout) {
+	if o.Processing.CheckMarkers != nil {
+		*checkMarkers = *o.Processing.CheckMarkers
+	}
+	if o.Processing.CheckDimensions != nil {
+		*checkDimensions = *o.Processing.CheckDimensions
+	}
+	if o.FlashcardSize.Width > 0 {
+		dimensions.Name = "custom"
+		dimensions.Width = o.FlashcardSize.Width
+		dimensions.Height = o.FlashcardSize.Height
+	}
+	if o.FlashcardSize.Tolerance != nil {
+		dimensions.Tolerance = *o.FlashcardSize.Tolerance
+	}
+	if o.FlashcardSize.Layout != nil {
+		dimensions.Layout = o.FlashcardSize.Layout
+	}
+	if o.Layout != nil {
+		*layout = *o.Layout
+	}
+}
+
+// UseTitle reports whether decks are named after the PDF's Title metadata, given
+// the run's setting.
+func (o Overrides) UseTitle(useTitle bool) bool {
+	if o.DeckNames.UseTitle != nil {
+		return *o.DeckNames.UseTitle
+	}
+	return useTitle
+}
+
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package pdf
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+const (
+	// DefaultBlankAnswerThreshold is the ink coverage of the answer below which a
+	// card counts as unfinished.
+	DefaultBlankAnswerThreshold = 0.002
+	// templateLineCoverage is the share of a row or column that must be ink for it
+	// to count as a printed line of the card template.
+	templateLineCoverage = 0.6
+)
+
+// BlankAnswerOptions controls the detection of unfinished cards, whose answer was
+// left empty to be filled in later.
+type BlankAnswerOptions struct {
+	Enabled bool
+	// Threshold is the ink coverage below which the answer is blank, once the
+	// markers and template lines are removed. Zero uses DefaultBlankAnswerThreshold.
+	Threshold float64
+}
+
+func (o BlankAnswerOptions) threshold() float64 {
+	if o.Threshold > 0 {
+		return o.Threshold
+	}
+	return DefaultBlankAnswerThreshold
+}
+
+// markBlankAnswer flags the card processCard just added as unfinished when its
+// answer region holds neither typed text nor handwriting. img is the cleaned card,
+// card its pixel bounds anchored at the origin and offset its position on the page
+// image, as for setCardText.
+func (p *Processor) markBlankAnswer(stats *ProcessingStats, img *image.RGBA, blocks []TextBlock, card image.Rectangle, offset image.Point, split models.SplitSpec, scale float64, markup cardMarkup) {
+	if !p.config.BlankAnswers.Enabled || len(stats.ImagePairs) == 0 {
+		return
+	}
+
+	_, answer := SplitRects(card, split.ForOrientation(card.Dx() > card.Dy()), scale)
+	cardPoints := models.Rect{
+		X:      float64(offset.X) / scale,
+		Y:      float64(offset.Y) / scale,
+		Width:  float64(card.Dx()) / scale,
+		Height: float64(card.Dy()) / scale,
+	}
+	answerPoints := models.Rect{
+		X:      cardPoints.X + float64(answer.Min.X)/scale,
+		Y:      cardPoints.Y + float64(answer.Min.Y)/scale,
+		Width:  float64(answer.Dx()) / scale,
+		Height: float64(answer.Dy()) / scale,
+	}
+	if RegionText(markup.cardText(blocks), answerPoints) != "" {
+		return
+	}
+
+	// The printed markers, extra regions and masked directives are not an answer.
+	var markers []models.Rect
+	for _, marker := range KeywordRects(blocks, utils.QuestionKeyword, utils.AnswerKeyword) {
+		if rectContainsCenter(cardPoints, marker) {
+			markers = append(markers, models.Rect{X: marker.X - cardPoints.X, Y: marker.Y - cardPoints.Y, Width: marker.Width, Height: marker.Height})
+		}
+	}
+	cleared := markup.blank(img)
+	if cleared == img {
+		cleared = cloneImage(img)
+	}
+	eraseRects(cleared, markers, scale)
+
+	if p.isBlankInk(cropImage(cleared, answer.Add(cleared.Bounds().Min))) {
+		pair := &stats.ImagePairs[len(stats.ImagePairs)-1]
+		pair.BlankAnswer = true
+		p.config.Logger.Debug("Page %d cell %d has a blank answer", pair.Source.PageNumber, pair.Source.CellIndex)
+	}
+}
+
+// answerPageIsBlank reports whether the rendered answer page of a page pair is
+// blank apart from its markers. Its text layer is checked by the caller.
+func (p *Processor) answerPageIsBlank(doc Document, pageIndex int, img *image.RGBA) bool {
+	cleared := cloneImage(img)
+	if blocks, err := doc.TextBlocks(pageIndex); err == nil {
+		eraseRects(cleared, KeywordRects(blocks, utils.QuestionKeyword, utils.AnswerKeyword), PointsToPixels)
+	}
+	return p.isBlankInk(cleared)
+}
+
+// isBlankInk reports whether img is blank once the template's ruled lines and
+// borders are removed.
+func (p *Processor) isBlankInk(img *image.RGBA) bool {
+	return InkCoverage(withoutTemplateLines(img)) < p.config.BlankAnswers.threshold()
+}
+
+// withoutTemplateLines returns a copy of img with the rows and columns that are
+// mostly ink, such as ruled lines and box borders, painted white.
+func withoutTemplateLines(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	inked := func(x, y int) bool {
+		return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < inkLuminanceThreshold
+	}
+
+	cleared := cloneImage(img)
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		count := 0
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if inked(x, y) {
+				count++
+			}
+		}
+		if float64(count) >= templateLineCoverage*float64(bounds.Dx()) {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				cleared.SetRGBA(x, y, white)
+			}
+		}
+	}
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		count := 0
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if inked(x, y) {
+				count++
+			}
+		}
+		if float64(count) >= templateLineCoverage*float64(bounds.Dy()) {
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				cleared.SetRGBA(x, y, white)
+			}
+		}
+	}
+	return cleared
+}
@@ -0,0 +1,76 @@
+package pdf_test
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+var _ = Describe("Blank answers", func() {
+	// template is a card with printed markers, a ruled line across the answer and a
+	// border down the left edge, all drawn as ink by the synthetic renderer.
+	template := []pdf.TextBlock{
+		{Text: "QUESTION", Rect: models.Rect{X: 40, Y: 40, Width: 80, Height: 14}},
+		{Text: "What is ATP?", Rect: models.Rect{X: 40, Y: 80, Width: 120, Height: 14}},
+		{Text: "ANSWER", Rect: models.Rect{X: 40, Y: 340, Width: 70, Height: 14}},
+		{Rect: models.Rect{X: 0, Y: 450, Width: 455, Height: 1}},
+		{Rect: models.Rect{X: 2, Y: 0, Width: 1, Height: 588}},
+	}
+
+	process := func(options pdf.BlankAnswerOptions, answer ...pdf.TextBlock) pdf.ImagePair {
+		tempDir, err := os.MkdirTemp("", "notesankify-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tempDir)
+
+		lines := append(append([]pdf.TextBlock(nil), template...), answer...)
+		processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+			TempDir:   tempDir,
+			OutputDir: tempDir,
+			Dimensions: models.PageDimensions{
+				Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+				Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+			},
+			ProcessingOptions: pdf.ProcessingOptions{
+				CheckDimensions: true,
+				CheckMarkers:    true,
+				BlankAnswers:    options,
+			},
+			Renderer: syntheticRenderer{pages: []syntheticPage{{width: 455, height: 588, lines: lines}}},
+			Logger:   processorTestLogger(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.ImagePairs).To(HaveLen(1))
+		return stats.ImagePairs[0]
+	}
+
+	It("should find an answer holding only markers and template lines", func() {
+		Expect(process(pdf.BlankAnswerOptions{Enabled: true}).BlankAnswer).To(BeTrue())
+	})
+
+	It("should not look for blank answers unless enabled", func() {
+		Expect(process(pdf.BlankAnswerOptions{}).BlankAnswer).To(BeFalse())
+	})
+
+	It("should count handwriting and typed text as an answer", func() {
+		handwriting := pdf.TextBlock{Rect: models.Rect{X: 60, Y: 400, Width: 200, Height: 20}}
+		Expect(process(pdf.BlankAnswerOptions{Enabled: true}, handwriting).BlankAnswer).To(BeFalse())
+
+		typed := pdf.TextBlock{Text: "Energy currency", Rect: models.Rect{X: 40, Y: 380, Width: 1, Height: 1}}
+		Expect(process(pdf.BlankAnswerOptions{Enabled: true}, typed).BlankAnswer).To(BeFalse())
+	})
+
+	It("should treat faint marks below the threshold as blank", func() {
+		speck := pdf.TextBlock{Rect: models.Rect{X: 200, Y: 500, Width: 4, Height: 4}}
+		Expect(process(pdf.BlankAnswerOptions{Enabled: true}, speck).BlankAnswer).To(BeTrue())
+		Expect(process(pdf.BlankAnswerOptions{Enabled: true, Threshold: 0.00001}, speck).BlankAnswer).To(BeFalse())
+	})
+})
@@ -0,0 +1,292 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+const (
+	// diffPixelThreshold is the per-channel difference above which a pixel counts as changed.
+	diffPixelThreshold = 32
+	// cardMatchDistance is the largest combined question and answer perceptual hash
+	// distance at which a changed card is still considered a revision of an old card.
+	cardMatchDistance = 16
+)
+
+// PageDiff compares one page position across two PDFs.
+type PageDiff struct {
+	PageNumber         int
+	InOld, InNew       bool
+	OldWidth           float64 // page size in points
+	OldHeight          float64
+	NewWidth           float64
+	NewHeight          float64
+	SameText           bool
+	OldText, NewText   string
+	SameHash           bool    // rendered pages are pixel identical
+	PerceptualDistance int     // bits differing between the pages' perceptual hashes
+	ChangedPixels      float64 // fraction of pixels that differ noticeably
+	Heatmap            string  // path of the diff heatmap, if one was written
+}
+
+func (d PageDiff) SameSize() bool {
+	return d.OldWidth == d.NewWidth && d.OldHeight == d.NewHeight
+}
+
+// ComparePDFs compares two PDFs page by page, opening them with renderer. When
+// heatmapDir is set, a diff heatmap is written there for every rendered page that changed.
+func ComparePDFs(ctx context.Context, oldPath, newPath, heatmapDir string, renderer Renderer, passwords []PasswordProvider) ([]PageDiff, error) {
+	oldDoc, err := renderer.Open(oldPath, passwords)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", oldPath, err)
+	}
+	defer oldDoc.Close()
+
+	newDoc, err := renderer.Open(newPath, passwords)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", newPath, err)
+	}
+	defer newDoc.Close()
+
+	if heatmapDir != "" {
+		if err := os.MkdirAll(heatmapDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create heatmap directory: %w", err)
+		}
+	}
+
+	var diffs []PageDiff
+	for pageIndex := 0; pageIndex < max(oldDoc.NumPage(), newDoc.NumPage()); pageIndex++ {
+		if err := ctx.Err(); err != nil {
+			return diffs, err
+		}
+
+		diff := PageDiff{
+			PageNumber: pageIndex + 1,
+			InOld:      pageIndex < oldDoc.NumPage(),
+			InNew:      pageIndex < newDoc.NumPage(),
+		}
+		if diff.InOld && diff.InNew {
+			if err := comparePage(oldDoc, newDoc, pageIndex, heatmapDir, &diff); err != nil {
+				return diffs, fmt.Errorf("failed to compare page %d: %w", diff.PageNumber, err)
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+func comparePage(oldDoc, newDoc Document, pageIndex int, heatmapDir string, diff *PageDiff) error {
+	oldBounds, err := oldDoc.Bound(pageIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get old bounds: %w", err)
+	}
+	newBounds, err := newDoc.Bound(pageIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get new bounds: %w", err)
+	}
+	diff.OldWidth, diff.OldHeight = float64(oldBounds.Dx()), float64(oldBounds.Dy())
+	diff.NewWidth, diff.NewHeight = float64(newBounds.Dx()), float64(newBounds.Dy())
+
+	if diff.OldText, err = oldDoc.Text(pageIndex); err != nil {
+		return fmt.Errorf("failed to extract old text: %w", err)
+	}
+	if diff.NewText, err = newDoc.Text(pageIndex); err != nil {
+		return fmt.Errorf("failed to extract new text: %w", err)
+	}
+	diff.SameText = diff.OldText == diff.NewText
+
+	oldImg, err := oldDoc.ImageDPI(pageIndex, RenderDPI)
+	if err != nil {
+		return fmt.Errorf("failed to extract old image: %w", err)
+	}
+	newImg, err := newDoc.ImageDPI(pageIndex, RenderDPI)
+	if err != nil {
+		return fmt.Errorf("failed to extract new image: %w", err)
+	}
+
+	oldHash, err := utils.GenerateImageHash(oldImg)
+	if err != nil {
+		return fmt.Errorf("failed to hash old image: %w", err)
+	}
+	newHash, err := utils.GenerateImageHash(newImg)
+	if err != nil {
+		return fmt.Errorf("failed to hash new image: %w", err)
+	}
+	diff.SameHash = oldHash == newHash
+	diff.PerceptualDistance = utils.HashDistance(utils.PerceptualHash(oldImg), utils.PerceptualHash(newImg))
+	if diff.SameHash {
+		return nil
+	}
+
+	heatmap, changed := DiffHeatmap(oldImg, newImg)
+	diff.ChangedPixels = changed
+	if heatmapDir == "" {
+		return nil
+	}
+
+	diff.Heatmap = filepath.Join(heatmapDir, fmt.Sprintf("page%03d_diff.png", diff.PageNumber))
+	if err := saveImage(heatmap, diff.Heatmap); err != nil {
+		return fmt.Errorf("failed to save heatmap: %w", err)
+	}
+	return nil
+}
+
+// DiffHeatmap draws the new page faded to grey with changed pixels in red, brighter
+// for larger differences. Areas covered by only one of the pages count as changed.
+// It also returns the fraction of pixels that changed.
+func DiffHeatmap(oldImg, newImg *image.RGBA) (*image.RGBA, float64) {
+	oldBounds := oldImg.Bounds().Sub(oldImg.Bounds().Min)
+	newBounds := newImg.Bounds().Sub(newImg.Bounds().Min)
+	bounds := oldBounds.Union(newBounds)
+	heatmap := image.NewRGBA(bounds)
+
+	var changed int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			point := image.Pt(x, y)
+			inOld, inNew := point.In(oldBounds), point.In(newBounds)
+
+			var oldPixel, newPixel color.RGBA
+			if inOld {
+				oldPixel = oldImg.RGBAAt(oldImg.Bounds().Min.X+x, oldImg.Bounds().Min.Y+y)
+			}
+			if inNew {
+				newPixel = newImg.RGBAAt(newImg.Bounds().Min.X+x, newImg.Bounds().Min.Y+y)
+			}
+
+			distance := 255
+			if inOld && inNew {
+				distance = colorDistance(oldPixel, newPixel)
+			}
+			if distance > diffPixelThreshold {
+				changed++
+				heatmap.SetRGBA(x, y, color.RGBA{R: uint8(128 + distance/2), A: 255})
+				continue
+			}
+
+			gray := color.GrayModel.Convert(newPixel).(color.Gray).Y
+			faded := 255 - (255-gray)/3
+			heatmap.SetRGBA(x, y, color.RGBA{R: faded, G: faded, B: faded, A: 255})
+		}
+	}
+
+	return heatmap, float64(changed) / float64(bounds.Dx()*bounds.Dy())
+}
+
+// CardStatus is how a card of the new PDF would be treated on import.
+type CardStatus string
+
+const (
+	CardNew       CardStatus = "new"       // no similar card in the old PDF
+	CardUpdated   CardStatus = "updated"   // a revision of an old card, so it gets a new hash
+	CardUnchanged CardStatus = "unchanged" // same hash as an old card, skipped as a duplicate
+	CardRemoved   CardStatus = "removed"   // an old card with no counterpart in the new PDF
+)
+
+// CardChange classifies one card. Previous is the old card it was matched with.
+type CardChange struct {
+	Card     ImagePair
+	Previous *ImagePair
+	Status   CardStatus
+	Distance int // perceptual distance to Previous for updated cards
+}
+
+// ClassifyCards matches the cards of a new PDF against those of an old one. Cards
+// with the same hash are unchanged, changed cards are matched to the most similar
+// remaining old card, and old cards left over are reported as removed.
+func ClassifyCards(oldCards, newCards []ImagePair) ([]CardChange, error) {
+	oldHashes := make([]cardHash, len(oldCards))
+	for i, card := range oldCards {
+		hash, err := perceptualCardHash(card)
+		if err != nil {
+			return nil, err
+		}
+		oldHashes[i] = hash
+	}
+
+	matched := make([]bool, len(oldCards))
+	changes := make([]CardChange, len(newCards))
+	var changedCards []int
+	for i, card := range newCards {
+		changes[i] = CardChange{Card: card, Status: CardNew}
+		for j, old := range oldCards {
+			if !matched[j] && old.Hash == card.Hash {
+				matched[j] = true
+				changes[i].Status = CardUnchanged
+				changes[i].Previous = &oldCards[j]
+				break
+			}
+		}
+		if changes[i].Status == CardNew {
+			changedCards = append(changedCards, i)
+		}
+	}
+
+	for _, i := range changedCards {
+		hash, err := perceptualCardHash(changes[i].Card)
+		if err != nil {
+			return nil, err
+		}
+
+		closest, closestDistance := -1, cardMatchDistance+1
+		for j := range oldCards {
+			if distance := hash.distance(oldHashes[j]); !matched[j] && distance < closestDistance {
+				closest, closestDistance = j, distance
+			}
+		}
+		if closest >= 0 {
+			matched[closest] = true
+			changes[i].Status = CardUpdated
+			changes[i].Previous = &oldCards[closest]
+			changes[i].Distance = closestDistance
+		}
+	}
+
+	for j := range oldCards {
+		if !matched[j] {
+			changes = append(changes, CardChange{Card: oldCards[j], Status: CardRemoved})
+		}
+	}
+	return changes, nil
+}
+
+type cardHash struct {
+	question, answer uint64
+}
+
+func (h cardHash) distance(other cardHash) int {
+	return utils.HashDistance(h.question, other.question) + utils.HashDistance(h.answer, other.answer)
+}
+
+func perceptualCardHash(card ImagePair) (cardHash, error) {
+	question, err := loadPNG(card.Question)
+	if err != nil {
+		return cardHash{}, err
+	}
+	answer, err := loadPNG(card.Answer)
+	if err != nil {
+		return cardHash{}, err
+	}
+	return cardHash{question: utils.PerceptualHash(question), answer: utils.PerceptualHash(answer)}, nil
+}
+
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	return img, nil
+}
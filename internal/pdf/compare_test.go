@@ -0,0 +1,120 @@
+package pdf_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+// sketch draws a white card with a black bar at the given position.
+func sketch(width, height int, bar image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, bar, image.Black, image.Point{}, draw.Src)
+	return img
+}
+
+var _ = Describe("PDF comparison", func() {
+	Context("DiffHeatmap", func() {
+		It("should report no changes for identical pages", func() {
+			page := sketch(100, 100, image.Rect(10, 10, 50, 20))
+			heatmap, changed := pdf.DiffHeatmap(page, page)
+			Expect(changed).To(BeZero())
+			Expect(heatmap.RGBAAt(30, 15).R).To(Equal(heatmap.RGBAAt(30, 15).G))
+		})
+
+		It("should mark changed pixels in red", func() {
+			oldPage := sketch(100, 100, image.Rect(10, 10, 50, 20))
+			newPage := sketch(100, 100, image.Rect(10, 10, 50, 30))
+
+			heatmap, changed := pdf.DiffHeatmap(oldPage, newPage)
+			Expect(changed).To(BeNumerically("~", 0.04, 0.0001))
+			Expect(heatmap.RGBAAt(30, 25)).To(Equal(color.RGBA{R: 255, A: 255}))
+			Expect(heatmap.RGBAAt(30, 15).G).To(Equal(heatmap.RGBAAt(30, 15).R))
+		})
+
+		It("should count areas covered by only one page as changed", func() {
+			oldPage := sketch(100, 100, image.Rectangle{})
+			newPage := sketch(100, 120, image.Rectangle{})
+
+			heatmap, changed := pdf.DiffHeatmap(oldPage, newPage)
+			Expect(heatmap.Bounds().Size()).To(Equal(image.Pt(100, 120)))
+			Expect(changed).To(BeNumerically("~", 20.0/120, 0.0001))
+		})
+	})
+
+	Context("perceptual hashes", func() {
+		It("should keep similar images close and different images apart", func() {
+			original := sketch(200, 100, image.Rect(20, 20, 120, 40))
+			retouched := sketch(200, 100, image.Rect(20, 20, 122, 41))
+			different := sketch(200, 100, image.Rect(150, 50, 190, 95))
+
+			originalHash := utils.PerceptualHash(original)
+			Expect(utils.HashDistance(originalHash, utils.PerceptualHash(retouched))).To(BeNumerically("<=", 2))
+			Expect(utils.HashDistance(originalHash, utils.PerceptualHash(different))).To(BeNumerically(">", 8))
+		})
+	})
+
+	Context("ClassifyCards", func() {
+		var cardDir string
+
+		BeforeEach(func() {
+			var err error
+			cardDir, err = os.MkdirTemp("", "notesankify-compare-*")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cardDir)).To(Succeed())
+		})
+
+		card := func(name, hash string, page int, bar image.Rectangle) pdf.ImagePair {
+			pair := pdf.ImagePair{
+				Question: filepath.Join(cardDir, name+"_question.png"),
+				Answer:   filepath.Join(cardDir, name+"_answer.png"),
+				Hash:     hash,
+				Source:   pdf.CardSource{PageNumber: page},
+			}
+			for _, path := range []string{pair.Question, pair.Answer} {
+				f, err := os.Create(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(png.Encode(f, sketch(200, 100, bar))).To(Succeed())
+				Expect(f.Close()).To(Succeed())
+			}
+			return pair
+		}
+
+		It("should sort cards into new, updated, unchanged and removed", func() {
+			oldCards := []pdf.ImagePair{
+				card("old1", "aaaa", 1, image.Rect(20, 20, 120, 40)),
+				card("old2", "bbbb", 2, image.Rect(20, 60, 180, 90)),
+				card("old3", "cccc", 3, image.Rect(150, 10, 160, 90)),
+			}
+			newCards := []pdf.ImagePair{
+				card("new1", "aaaa", 1, image.Rect(20, 20, 120, 40)),
+				card("new2", "dddd", 2, image.Rect(20, 60, 182, 91)),
+				card("new3", "eeee", 3, image.Rect(60, 0, 140, 100)),
+			}
+
+			changes, err := pdf.ClassifyCards(oldCards, newCards)
+			Expect(err).NotTo(HaveOccurred())
+
+			var statuses []string
+			for _, change := range changes {
+				statuses = append(statuses, fmt.Sprintf("%s:%s", change.Status, change.Card.Hash))
+			}
+			Expect(statuses).To(Equal([]string{"unchanged:aaaa", "updated:dddd", "new:eeee", "removed:cccc"}))
+			Expect(changes[1].Previous.Hash).To(Equal("bbbb"))
+		})
+	})
+})
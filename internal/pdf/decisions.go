@@ -0,0 +1,192 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+// PageOutcome is what happened to a page during processing.
+type PageOutcome string
+
+const (
+	PageCard     PageOutcome = "card"     // the page produced at least one card
+	PageSkipped  PageOutcome = "skipped"  // a check filtered the page out
+	PageFailed   PageOutcome = "failed"   // an error stopped the page from being processed
+	PageUnpaired PageOutcome = "unpaired" // no partner page was found in two-page mode
+)
+
+// PageDecision records the checks run on a page and why it did or did not become a card.
+type PageDecision struct {
+	PageNumber     int
+	Width          float64 // page size in points
+	Height         float64
+	Scanned        bool     // image-only page handled by the scan stage
+	PresetsTried   []string // presets the page size was compared against
+	Preset         string   // matched preset, empty when none matched
+	MarkersChecked bool
+	MarkersByOCR   bool // the text layer lacked markers, so the page was run through OCR
+	HasQuestion    bool
+	HasAnswer      bool
+	Cards          int
+	FailedCells    []CellFailure // grid cells that failed while the rest of the page was processed
+	Outcome        PageOutcome
+	Reason         string // why the page was skipped, failed or left unpaired
+}
+
+// CellFailure is an error that stopped one cell of a multi-card page from becoming a card.
+type CellFailure struct {
+	CellIndex int
+	Reason    string
+}
+
+// cellFailures is returned by processPageImage when some cells of a page failed.
+type cellFailures []CellFailure
+
+func (f cellFailures) Error() string {
+	reasons := make([]string, len(f))
+	for i, failure := range f {
+		reasons[i] = fmt.Sprintf("cell %d: %s", failure.CellIndex, failure.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func (d *PageDecision) skip(format string, args ...interface{}) {
+	d.Outcome = PageSkipped
+	d.Reason = fmt.Sprintf(format, args...)
+}
+
+func (d *PageDecision) fail(err error) {
+	d.Outcome = PageFailed
+	d.Reason = err.Error()
+}
+
+// Markers describes the marker check, e.g. "QUESTION only".
+func (d PageDecision) Markers() string {
+	switch {
+	case !d.MarkersChecked:
+		return "not checked"
+	case d.HasQuestion && d.HasAnswer:
+		return "found"
+	case d.HasQuestion:
+		return utils.QuestionKeyword + " only"
+	case d.HasAnswer:
+		return utils.AnswerKeyword + " only"
+	default:
+		return "missing"
+	}
+}
+
+// Summary explains the decision in one line.
+func (d PageDecision) Summary() string {
+	var summary strings.Builder
+	summary.WriteString(string(d.Outcome))
+	if d.Outcome == PageCard && d.Cards > 1 {
+		fmt.Fprintf(&summary, " (%d cards)", d.Cards)
+	}
+	if d.Reason != "" {
+		fmt.Fprintf(&summary, ": %s", d.Reason)
+	}
+
+	if d.Width > 0 {
+		fmt.Fprintf(&summary, "; size %.2f x %.2f", d.Width, d.Height)
+		if d.Preset != "" {
+			fmt.Fprintf(&summary, " matched %s", d.Preset)
+		}
+	}
+	if d.Scanned {
+		summary.WriteString("; scanned page")
+	}
+	if d.MarkersChecked {
+		fmt.Fprintf(&summary, "; markers %s", d.Markers())
+		if d.MarkersByOCR {
+			summary.WriteString(" (OCR)")
+		}
+	}
+	return summary.String()
+}
+
+// measurePage records the page size and the preset it matches.
+func (p *Processor) measurePage(doc Document, pageIndex int, decision *PageDecision) error {
+	bounds, err := doc.Bound(pageIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get bounds: %w", err)
+	}
+
+	decision.Width = float64(bounds.Dx())
+	decision.Height = float64(bounds.Dy())
+	p.config.Logger.Debug("Page %d dimensions: %.2f x %.2f", decision.PageNumber, decision.Width, decision.Height)
+
+	decision.PresetsTried = decision.PresetsTried[:0]
+	for _, preset := range p.presets() {
+		decision.PresetsTried = append(decision.PresetsTried, preset.DisplayName())
+	}
+	if preset, ok := p.MatchPreset(decision.Width, decision.Height); ok {
+		decision.Preset = preset.DisplayName()
+	}
+	return nil
+}
+
+// checkMarkers records which flashcard markers the page's text layer contains.
+func (p *Processor) checkMarkers(doc Document, pageIndex int, decision *PageDecision) error {
+	text, err := doc.Text(pageIndex)
+	if err != nil {
+		return fmt.Errorf("failed to extract text: %w", err)
+	}
+
+	decision.MarkersChecked = true
+	decision.HasQuestion = strings.Contains(text, utils.QuestionKeyword)
+	decision.HasAnswer = strings.Contains(text, utils.AnswerKeyword)
+	if (decision.HasQuestion && decision.HasAnswer) || p.config.OCR == nil {
+		return nil
+	}
+
+	// Handwritten markers are only visible to OCR.
+	img, err := doc.ImageDPI(pageIndex, RenderDPI)
+	if err != nil {
+		return fmt.Errorf("failed to extract image for OCR: %w", err)
+	}
+	recognized, err := p.config.OCR.Recognize(img)
+	if err != nil {
+		return fmt.Errorf("failed to run OCR: %w", err)
+	}
+	decision.MarkersByOCR = true
+	decision.HasQuestion = decision.HasQuestion || strings.Contains(strings.ToUpper(recognized), utils.QuestionKeyword)
+	decision.HasAnswer = decision.HasAnswer || strings.Contains(strings.ToUpper(recognized), utils.AnswerKeyword)
+	return nil
+}
+
+// dimensionMismatch is the skip reason for a page matching no accepted preset.
+func (d PageDecision) dimensionMismatch() string {
+	return fmt.Sprintf("size matched none of %s", strings.Join(d.PresetsTried, ", "))
+}
+
+// recordCards runs process and sets the decision's outcome from the cards it added.
+func recordCards(stats *ProcessingStats, decision *PageDecision, process func() error) {
+	before := stats.FlashcardCount
+	err := process()
+	decision.Cards = stats.FlashcardCount - before
+
+	// Failed cells only fail the page when no other cell became a card.
+	var failed cellFailures
+	if errors.As(err, &failed) {
+		decision.FailedCells = append(decision.FailedCells, failed...)
+		if decision.Cards > 0 {
+			err = nil
+		}
+	}
+
+	switch {
+	case err != nil:
+		decision.fail(err)
+	case decision.Cards == 0:
+		decision.skip("every card cell was empty")
+	default:
+		decision.Outcome = PageCard
+		if len(failed) > 0 {
+			decision.Reason = failed.Error()
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package pdf_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("Page decisions", func() {
+	DescribeTable("Markers",
+		func(decision pdf.PageDecision, expected string) {
+			Expect(decision.Markers()).To(Equal(expected))
+		},
+		Entry("not checked", pdf.PageDecision{}, "not checked"),
+		Entry("both markers", pdf.PageDecision{MarkersChecked: true, HasQuestion: true, HasAnswer: true}, "found"),
+		Entry("question only", pdf.PageDecision{MarkersChecked: true, HasQuestion: true}, "QUESTION only"),
+		Entry("answer only", pdf.PageDecision{MarkersChecked: true, HasAnswer: true}, "ANSWER only"),
+		Entry("no markers", pdf.PageDecision{MarkersChecked: true}, "missing"),
+	)
+
+	DescribeTable("Summary",
+		func(decision pdf.PageDecision, expected string) {
+			Expect(decision.Summary()).To(Equal(expected))
+		},
+		Entry("card page",
+			pdf.PageDecision{Outcome: pdf.PageCard, Cards: 1, Width: 455, Height: 369, Preset: "goodnotes-standard", MarkersChecked: true, HasQuestion: true, HasAnswer: true},
+			"card; size 455.00 x 369.00 matched goodnotes-standard; markers found"),
+		Entry("page with several cards",
+			pdf.PageDecision{Outcome: pdf.PageCard, Cards: 4},
+			"card (4 cards)"),
+		Entry("skipped for size",
+			pdf.PageDecision{Outcome: pdf.PageSkipped, Reason: "size matched none of goodnotes-standard", Width: 612, Height: 792},
+			"skipped: size matched none of goodnotes-standard; size 612.00 x 792.00"),
+		Entry("skipped scanned page",
+			pdf.PageDecision{Outcome: pdf.PageSkipped, Reason: "every card cell was empty", Scanned: true},
+			"skipped: every card cell was empty; scanned page"),
+		Entry("skipped for markers",
+			pdf.PageDecision{Outcome: pdf.PageSkipped, Reason: "QUESTION/ANSWER markers QUESTION only", MarkersChecked: true, HasQuestion: true},
+			"skipped: QUESTION/ANSWER markers QUESTION only; markers QUESTION only"),
+	)
+})
@@ -0,0 +1,133 @@
+package pdf
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Directives are instructions written on a card, one per text line:
+//
+//	#tag anatomy        adds tags to the note
+//	DECK: Exam::Hard    adds the note to this deck instead of the PDF's
+//	!suspend            suspends the new card
+//	!flag red           flags the new card, by color name or number 1-7
+//
+// They are read from the text layer, and from the recognized text when OCR is
+// configured. Directives found by OCR have no position, so they are not masked.
+type Directives struct {
+	Tags    []string
+	Deck    string
+	Suspend bool
+	Flag    int // Anki flag number, 0 for none
+}
+
+// IsEmpty reports whether the card carries no directives.
+func (d Directives) IsEmpty() bool {
+	return len(d.Tags) == 0 && d.Deck == "" && !d.Suspend && d.Flag == 0
+}
+
+// merge combines the directives of a card's question and answer pages. other's deck
+// and flag win.
+func (d Directives) merge(other Directives) Directives {
+	merged := d
+	merged.Tags = append([]string(nil), d.Tags...)
+	for _, tag := range other.Tags {
+		if !slices.Contains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	if other.Deck != "" {
+		merged.Deck = other.Deck
+	}
+	if other.Flag != 0 {
+		merged.Flag = other.Flag
+	}
+	merged.Suspend = d.Suspend || other.Suspend
+	return merged
+}
+
+// DirectiveOptions controls reading directives from the cards.
+type DirectiveOptions struct {
+	Enabled bool
+	Mask    bool // if true, blank the directive lines out of the card images
+}
+
+// flagColors are Anki's flag numbers by color.
+var flagColors = map[string]int{
+	"red":       1,
+	"orange":    2,
+	"green":     3,
+	"blue":      4,
+	"pink":      5,
+	"turquoise": 6,
+	"purple":    7,
+}
+
+// ParseFlag returns the Anki flag number of a color name or number.
+func ParseFlag(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if flag, ok := flagColors[value]; ok {
+		return flag, nil
+	}
+	if flag, err := strconv.Atoi(value); err == nil && flag >= 1 && flag <= len(flagColors) {
+		return flag, nil
+	}
+	return 0, fmt.Errorf("unknown flag %q (expected red, orange, green, blue, pink, turquoise, purple or 1-7)", value)
+}
+
+// parseDirective adds the directive on a text line to directives. It reports false
+// when the line is not a directive.
+func parseDirective(line string, directives *Directives) bool {
+	line = strings.TrimSpace(line)
+	lower := strings.ToLower(line)
+
+	switch {
+	case strings.HasPrefix(lower, "#tag "):
+		tags := strings.Fields(line[len("#tag "):])
+		if len(tags) == 0 {
+			return false
+		}
+		directives.Tags = append(directives.Tags, tags...)
+	case strings.HasPrefix(lower, "deck:"):
+		deck := strings.TrimSpace(line[len("deck:"):])
+		if deck == "" {
+			return false
+		}
+		directives.Deck = deck
+	case lower == "!suspend":
+		directives.Suspend = true
+	case strings.HasPrefix(lower, "!flag "):
+		flag, err := ParseFlag(line[len("!flag "):])
+		if err != nil {
+			return false
+		}
+		directives.Flag = flag
+	default:
+		return false
+	}
+	return true
+}
+
+// ParseDirectives reads the directives in text, one per line, ignoring other lines.
+func ParseDirectives(text string) Directives {
+	var directives Directives
+	for _, line := range strings.Split(text, "\n") {
+		parseDirective(line, &directives)
+	}
+	return directives
+}
+
+// findDirectives reads the directives among the text lines of a card and returns
+// them with the lines that hold them.
+func findDirectives(blocks []TextBlock) (Directives, []TextBlock) {
+	var directives Directives
+	var lines []TextBlock
+	for _, block := range blocks {
+		if parseDirective(block.Text, &directives) {
+			lines = append(lines, block)
+		}
+	}
+	return directives, lines
+}
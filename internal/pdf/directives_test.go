@@ -0,0 +1,140 @@
+package pdf_test
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+var _ = Describe("Directives", func() {
+	It("should read directives and ignore other lines", func() {
+		directives := pdf.ParseDirectives("What is ATP?\n#tag anatomy exam\n  deck: Exam::Hard \n!suspend\n!flag Red\n#tagged\n!flag brown")
+
+		Expect(directives).To(Equal(pdf.Directives{
+			Tags:    []string{"anatomy", "exam"},
+			Deck:    "Exam::Hard",
+			Suspend: true,
+			Flag:    1,
+		}))
+		Expect(pdf.ParseDirectives("Just notes").IsEmpty()).To(BeTrue())
+	})
+
+	DescribeTable("parsing flags",
+		func(value string, expected int) {
+			flag, err := pdf.ParseFlag(value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(flag).To(Equal(expected))
+		},
+		Entry("by color", "purple", 7),
+		Entry("by number", "4", 4),
+	)
+
+	It("should reject unknown flags", func() {
+		_, err := pdf.ParseFlag("8")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should take directives out of the card text and optionally its images", func() {
+		tempDir, err := os.MkdirTemp("", "notesankify-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tempDir)
+
+		renderer := syntheticRenderer{
+			pages: []syntheticPage{
+				{width: 455, height: 588, lines: []pdf.TextBlock{
+					{Text: "QUESTION", Rect: models.Rect{X: 40, Y: 40, Width: 80, Height: 14}},
+					{Text: "What is ATP?", Rect: models.Rect{X: 40, Y: 80, Width: 120, Height: 14}},
+					{Text: "#tag biology", Rect: models.Rect{X: 40, Y: 250, Width: 110, Height: 14}},
+					{Text: "ANSWER", Rect: models.Rect{X: 40, Y: 340, Width: 70, Height: 14}},
+					{Text: "Energy currency", Rect: models.Rect{X: 40, Y: 380, Width: 150, Height: 14}},
+					{Text: "!suspend", Rect: models.Rect{X: 40, Y: 540, Width: 70, Height: 14}},
+				}},
+			},
+		}
+
+		process := func(options pdf.DirectiveOptions) (pdf.ImagePair, float64, float64) {
+			outputDir, err := os.MkdirTemp("", "notesankify-output-*")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(os.RemoveAll, outputDir)
+
+			processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+					Directives:      options,
+				},
+				Renderer: renderer,
+				Logger:   processorTestLogger(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.ImagePairs).To(HaveLen(1))
+			pair := stats.ImagePairs[0]
+			return pair, pdf.InkCoverage(readImage(pair.Question)), pdf.InkCoverage(readImage(pair.Answer))
+		}
+
+		plain, plainQuestionInk, plainAnswerInk := process(pdf.DirectiveOptions{})
+		Expect(plain.Directives.IsEmpty()).To(BeTrue())
+		Expect(plain.QuestionText).To(ContainSubstring("#tag biology"))
+
+		read, readQuestionInk, _ := process(pdf.DirectiveOptions{Enabled: true})
+		Expect(read.Directives).To(Equal(pdf.Directives{Tags: []string{"biology"}, Suspend: true}))
+		Expect(read.QuestionText).To(Equal("What is ATP?"))
+		Expect(read.AnswerText).To(Equal("Energy currency"))
+		Expect(readQuestionInk).To(Equal(plainQuestionInk))
+
+		masked, maskedQuestionInk, maskedAnswerInk := process(pdf.DirectiveOptions{Enabled: true, Mask: true})
+		Expect(masked.Hash).To(Equal(plain.Hash))
+		Expect(maskedQuestionInk).To(BeNumerically("<", plainQuestionInk))
+		Expect(maskedAnswerInk).To(BeNumerically("<", plainAnswerInk))
+	})
+
+	It("should read handwritten directives from the recognized text", func() {
+		tempDir, err := os.MkdirTemp("", "notesankify-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tempDir)
+
+		processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+			TempDir:   tempDir,
+			OutputDir: tempDir,
+			Dimensions: models.PageDimensions{
+				Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+				Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+			},
+			ProcessingOptions: pdf.ProcessingOptions{
+				CheckDimensions: true,
+				CheckMarkers:    true,
+				Directives:      pdf.DirectiveOptions{Enabled: true},
+			},
+			Renderer: syntheticRenderer{pages: []syntheticPage{{width: 455, height: 588, lines: []pdf.TextBlock{
+				{Text: "QUESTION", Rect: models.Rect{X: 40, Y: 40, Width: 80, Height: 14}},
+				{Text: "ANSWER", Rect: models.Rect{X: 40, Y: 340, Width: 70, Height: 14}},
+			}}}},
+			OCR:    &countingOCR{text: "Mitochondria\n#tag cells\n!flag blue"},
+			Logger: processorTestLogger(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.ImagePairs).To(HaveLen(1))
+		pair := stats.ImagePairs[0]
+		Expect(pair.Directives).To(Equal(pdf.Directives{Tags: []string{"cells"}, Flag: 4}))
+		Expect(pair.QuestionText).To(Equal("Mitochondria"))
+		Expect(pair.AnswerText).To(Equal("Mitochondria"))
+	})
+})
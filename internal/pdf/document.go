@@ -0,0 +1,79 @@
+package pdf
+
+import (
+	"image"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// RenderDPI is the resolution pages are rasterized at for cards.
+const RenderDPI = 300.0
+
+// Document is the part of an open PDF the processor reads. Page numbers are zero
+// based and sizes are in PDF points.
+type Document interface {
+	NumPage() int
+	Bound(pageNumber int) (image.Rectangle, error)
+	Text(pageNumber int) (string, error)
+	// TextBlocks returns the page's lines of text with their positions.
+	TextBlocks(pageNumber int) ([]TextBlock, error)
+	// ImageDPI rasterizes the page at the given resolution.
+	ImageDPI(pageNumber int, dpi float64) (*image.RGBA, error)
+	// ToC returns the document's bookmarks, flattened in document order.
+	ToC() ([]OutlineEntry, error)
+	Metadata() map[string]string
+	Close() error
+}
+
+// Renderer opens PDFs as Documents. FitzRenderer, built on MuPDF, is the default;
+// PopplerRenderer drives a locally installed poppler instead.
+type Renderer interface {
+	Open(path string, passwords []PasswordProvider) (Document, error)
+}
+
+// Renderer names accepted in the configuration.
+const (
+	RendererFitz    = "fitz"
+	RendererPoppler = "poppler"
+)
+
+// FitzRenderer renders PDFs in process with MuPDF through go-fitz.
+type FitzRenderer struct{}
+
+var _ Renderer = FitzRenderer{}
+
+func (FitzRenderer) Open(path string, passwords []PasswordProvider) (Document, error) {
+	doc, err := OpenDocument(path, passwords)
+	if err != nil {
+		return nil, err
+	}
+	return fitzDocument{doc}, nil
+}
+
+// fitzDocument adds positioned text, parsed from MuPDF's HTML output, to a fitz document.
+type fitzDocument struct {
+	*fitz.Document
+}
+
+var _ Document = fitzDocument{}
+
+// ToC converts fitz's outline into the backend-neutral entries.
+func (d fitzDocument) ToC() ([]OutlineEntry, error) {
+	items, err := d.Document.ToC()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]OutlineEntry, len(items))
+	for i, item := range items {
+		entries[i] = OutlineEntry{Title: item.Title, Page: item.Page, Level: item.Level}
+	}
+	return entries, nil
+}
+
+func (d fitzDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	markup, err := d.HTML(pageNumber, false)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTextBlocks(markup), nil
+}
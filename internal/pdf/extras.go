@@ -0,0 +1,220 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+// ExtraRegion is a further part of a card, such as a hint box, saved as an image of
+// its own on cards that show its marker. Its rectangle is the split's region of the
+// same name or, without one, the band of the card from the marker's line down to the
+// next marker. The region is blanked out of the question and answer images.
+type ExtraRegion struct {
+	Name   string // region and note field it fills: models.HintRegion or models.ExtraRegion
+	Marker string // case-sensitive label of the region, e.g. "HINT"; empty to always use the split's region
+}
+
+// ExtraImage is the image and typed text of an extra region of a card.
+type ExtraImage struct {
+	Name string
+	Path string
+	Text string
+}
+
+// cardRegion is an extra region found on a card. rect is the region in pixels from
+// the card's corner and page the same region in page points.
+type cardRegion struct {
+	name   string
+	marker string
+	rect   image.Rectangle
+	page   models.Rect
+	text   string
+}
+
+// cardMarkup is what a card holds besides its question and answer: extra regions
+// and directive lines. Both are left out of the question and answer.
+type cardMarkup struct {
+	regions    []cardRegion
+	directives Directives
+	lines      []models.Rect     // directive lines in page points
+	masks      []image.Rectangle // directive lines blanked from the images, in pixels from the card's corner
+}
+
+// findCardMarkup reads a card's extra regions and directives from the page's text.
+// card is the card's pixel bounds anchored at the origin and offset its position
+// on the page image, as for setCardText.
+func (p *Processor) findCardMarkup(blocks []TextBlock, card image.Rectangle, offset image.Point, split models.SplitSpec, scale float64) cardMarkup {
+	var markup cardMarkup
+	if len(p.config.ExtraRegions) == 0 && !p.config.Directives.Enabled {
+		return markup
+	}
+
+	cardPoints := models.Rect{
+		X:      float64(offset.X) / scale,
+		Y:      float64(offset.Y) / scale,
+		Width:  float64(card.Dx()) / scale,
+		Height: float64(card.Dy()) / scale,
+	}
+	var cardBlocks []TextBlock
+	for _, block := range blocks {
+		if rectContainsCenter(cardPoints, block.Rect) {
+			cardBlocks = append(cardBlocks, block)
+		}
+	}
+
+	if p.config.Directives.Enabled {
+		directives, lines := findDirectives(cardBlocks)
+		markup.directives = directives
+		markup.addDirectiveLines(lines, cardPoints, card, scale, p.config.Directives.Mask)
+		cardBlocks = markup.cardText(cardBlocks)
+	}
+
+	markup.regions = p.findExtraRegions(cardBlocks, cardPoints, card, split, scale)
+	return markup
+}
+
+// addDirectiveLines records the directive lines of a card whose bounds are cardPoints
+// in page points and card in pixels, masking them from the images if mask is set.
+func (m *cardMarkup) addDirectiveLines(lines []TextBlock, cardPoints models.Rect, card image.Rectangle, scale float64, mask bool) {
+	for _, line := range lines {
+		m.lines = append(m.lines, line.Rect)
+		if mask {
+			local := models.Rect{X: line.Rect.X - cardPoints.X, Y: line.Rect.Y - cardPoints.Y, Width: line.Rect.Width, Height: line.Rect.Height}
+			m.masks = append(m.masks, scaleRect(local, card.Min, scale).Inset(-2).Intersect(card))
+		}
+	}
+}
+
+// findExtraRegions locates the extra regions among the text lines of a card, whose
+// bounds are cardPoints in page points and card in pixels.
+func (p *Processor) findExtraRegions(cardBlocks []TextBlock, cardPoints models.Rect, card image.Rectangle, split models.SplitSpec, scale float64) []cardRegion {
+	if len(p.config.ExtraRegions) == 0 {
+		return nil
+	}
+
+	markers := []string{utils.QuestionKeyword, utils.AnswerKeyword}
+	for _, extra := range p.config.ExtraRegions {
+		if extra.Marker != "" {
+			markers = append(markers, extra.Marker)
+		}
+	}
+
+	var regions []cardRegion
+	for _, extra := range p.config.ExtraRegions {
+		var marker models.Rect
+		if extra.Marker != "" {
+			found := KeywordRects(cardBlocks, extra.Marker)
+			if len(found) == 0 {
+				continue
+			}
+			marker = found[0]
+			marker.X -= cardPoints.X
+			marker.Y -= cardPoints.Y
+		}
+
+		region, fixed := split.Regions[extra.Name]
+		if !fixed {
+			if extra.Marker == "" {
+				continue
+			}
+			bottom := cardPoints.Height
+			for _, other := range KeywordRects(cardBlocks, markers...) {
+				otherTop := other.Y - cardPoints.Y
+				if otherTop > marker.Y+marker.Height/2 && otherTop < bottom {
+					bottom = otherTop
+				}
+			}
+			region = models.Rect{Y: marker.Y, Width: cardPoints.Width, Height: bottom - marker.Y}
+		}
+
+		rect := scaleRect(region, card.Min, scale).Intersect(card)
+		if rect.Empty() {
+			continue
+		}
+		pageRegion := models.Rect{X: region.X + cardPoints.X, Y: region.Y + cardPoints.Y, Width: region.Width, Height: region.Height}
+		text := withoutMarker(RegionText(cardBlocks, pageRegion), extra.Marker)
+		regions = append(regions, cardRegion{name: extra.Name, marker: extra.Marker, rect: rect, page: pageRegion, text: text})
+	}
+	return regions
+}
+
+// blank returns a copy of img with the extra regions and masked directives painted
+// over in the paper color, or img itself when there are none.
+func (m cardMarkup) blank(img *image.RGBA) *image.RGBA {
+	if len(m.regions) == 0 && len(m.masks) == 0 {
+		return img
+	}
+	areas := append([]image.Rectangle(nil), m.masks...)
+	for _, region := range m.regions {
+		areas = append(areas, region.rect)
+	}
+
+	blanked := cloneImage(img)
+	fill := image.NewUniform(paperColor(img))
+	for _, area := range areas {
+		draw.Draw(blanked, area.Add(img.Bounds().Min), fill, image.Point{}, draw.Src)
+	}
+	return blanked
+}
+
+// saveExtraImages writes the card's extra regions next to its question and answer images.
+func (p *Processor) saveExtraImages(img *image.RGBA, regions []cardRegion, baseName, fullHash string) ([]ExtraImage, error) {
+	var extras []ExtraImage
+	for _, region := range regions {
+		path := filepath.Join(p.config.OutputDir, fmt.Sprintf("%s_%s_%s.png", baseName, fullHash[:8], region.name))
+		regionImg := cropImage(img, region.rect.Add(img.Bounds().Min))
+		if err := p.splitter.saveImage(regionImg, path); err != nil {
+			return nil, fmt.Errorf("failed to save %s image: %w", region.name, err)
+		}
+		text := region.text
+		if text == "" && p.config.OCR != nil {
+			text = withoutMarker(p.recognizeText(regionImg), region.marker)
+		}
+		extras = append(extras, ExtraImage{Name: region.name, Path: path, Text: text})
+	}
+	return extras, nil
+}
+
+func withoutMarker(text, marker string) string {
+	if marker != "" {
+		text = strings.ReplaceAll(text, marker, "")
+	}
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text), ":"))
+}
+
+// cardText drops the text lines inside the extra regions and the directive lines,
+// so they do not end up in the question or answer text.
+func (m cardMarkup) cardText(blocks []TextBlock) []TextBlock {
+	if len(m.regions) == 0 && len(m.lines) == 0 {
+		return blocks
+	}
+	excluded := append([]models.Rect(nil), m.lines...)
+	for _, region := range m.regions {
+		excluded = append(excluded, region.page)
+	}
+
+	var kept []TextBlock
+	for _, block := range blocks {
+		inside := false
+		for _, area := range excluded {
+			inside = inside || rectContainsCenter(area, block.Rect)
+		}
+		if !inside {
+			kept = append(kept, block)
+		}
+	}
+	return kept
+}
+
+func rectContainsCenter(region, rect models.Rect) bool {
+	centerX := rect.X + rect.Width/2
+	centerY := rect.Y + rect.Height/2
+	return centerX >= region.X && centerX < region.X+region.Width &&
+		centerY >= region.Y && centerY < region.Y+region.Height
+}
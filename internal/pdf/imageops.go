@@ -0,0 +1,65 @@
+package pdf
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+const (
+	// inkLuminanceThreshold is the grey level below which a pixel counts as ink.
+	inkLuminanceThreshold = 200
+	// blankCellCoverage is the ink coverage below which a card cell is treated as empty.
+	blankCellCoverage = 0.001
+)
+
+// CellRect returns the pixel rectangle of a card cell within a rendered page.
+func CellRect(bounds image.Rectangle, layout models.CardLayout, cellIndex int) image.Rectangle {
+	rows, columns := layout.GridSize()
+	row := cellIndex / columns
+	column := cellIndex % columns
+
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	return image.Rect(
+		bounds.Min.X+column*width/columns,
+		bounds.Min.Y+row*height/rows,
+		bounds.Min.X+(column+1)*width/columns,
+		bounds.Min.Y+(row+1)*height/rows,
+	)
+}
+
+// cropImage copies the given region of src into a new image anchored at the origin.
+func cropImage(src image.Image, rect image.Rectangle) *image.RGBA {
+	rect = rect.Intersect(src.Bounds())
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// InkCoverage returns the fraction of pixels in img that are dark enough to be ink.
+func InkCoverage(img image.Image) float64 {
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return 0
+	}
+
+	var inked int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < inkLuminanceThreshold {
+				inked++
+			}
+		}
+	}
+
+	return float64(inked) / float64(total)
+}
+
+func isBlank(img image.Image) bool {
+	return InkCoverage(img) < blankCellCoverage
+}
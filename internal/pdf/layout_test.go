@@ -0,0 +1,52 @@
+package pdf_test
+
+import (
+	"image"
+	"image/color"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+var _ = Describe("Card Layout Geometry", func() {
+	DescribeTable("CellRect",
+		func(layout models.CardLayout, cellIndex int, expected image.Rectangle) {
+			bounds := image.Rect(0, 0, 200, 300)
+			Expect(pdf.CellRect(bounds, layout, cellIndex)).To(Equal(expected))
+		},
+		Entry("single card covers the page", models.SingleCardLayout, 0, image.Rect(0, 0, 200, 300)),
+		Entry("top card of two", models.TwoCardLayout, 0, image.Rect(0, 0, 200, 150)),
+		Entry("bottom card of two", models.TwoCardLayout, 1, image.Rect(0, 150, 200, 300)),
+		Entry("top right card of four", models.FourCardLayout, 1, image.Rect(100, 0, 200, 150)),
+		Entry("bottom left card of six", models.SixCardLayout, 4, image.Rect(0, 200, 100, 300)),
+	)
+
+	Context("ink coverage", func() {
+		It("should report zero for a white image", func() {
+			img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+			for y := 0; y < 10; y++ {
+				for x := 0; x < 10; x++ {
+					img.Set(x, y, color.White)
+				}
+			}
+			Expect(pdf.InkCoverage(img)).To(BeZero())
+		})
+
+		It("should count dark pixels", func() {
+			img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+			for y := 0; y < 10; y++ {
+				for x := 0; x < 10; x++ {
+					if x < 5 {
+						img.Set(x, y, color.Black)
+					} else {
+						img.Set(x, y, color.White)
+					}
+				}
+			}
+			Expect(pdf.InkCoverage(img)).To(BeNumerically("~", 0.5, 0.001))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+package pdf
+
+import (
+	"strings"
+)
+
+// Metadata is the document information of a PDF. Note apps often export files with
+// names like "Untitled 3.pdf" while keeping a meaningful title here.
+type Metadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords []string
+}
+
+// readMetadata reads the document information dictionary. Missing entries are empty.
+func readMetadata(doc Document) Metadata {
+	info := doc.Metadata()
+	return Metadata{
+		Title:    metadataValue(info["title"]),
+		Author:   metadataValue(info["author"]),
+		Subject:  metadataValue(info["subject"]),
+		Keywords: ParseKeywords(metadataValue(info["keywords"])),
+	}
+}
+
+// metadataValue cleans up a value returned by fitz, which comes in a fixed size,
+// NUL padded buffer.
+func metadataValue(value string) string {
+	value, _, _ = strings.Cut(value, "\x00")
+	return strings.TrimSpace(strings.ToValidUTF8(value, ""))
+}
+
+// ParseKeywords splits a Keywords entry on commas and semicolons, dropping empty
+// and repeated keywords.
+func ParseKeywords(value string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, keyword := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" || seen[strings.ToLower(keyword)] {
+			continue
+		}
+		seen[strings.ToLower(keyword)] = true
+		keywords = append(keywords, keyword)
+	}
+	return keywords
+}
@@ -0,0 +1,20 @@
+package pdf_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("ParseKeywords", func() {
+	DescribeTable("splitting the Keywords entry",
+		func(value string, expected []string) {
+			Expect(pdf.ParseKeywords(value)).To(Equal(expected))
+		},
+		Entry("empty", "", nil),
+		Entry("comma separated", "biology, cells ,exam", []string{"biology", "cells", "exam"}),
+		Entry("semicolon separated", "biology;cell biology", []string{"biology", "cell biology"}),
+		Entry("empty and repeated keywords", "biology,, Biology; ;exam", []string{"biology", "exam"}),
+	)
+})
@@ -0,0 +1,157 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// OCREngine recognizes text in rendered images. It lets handwritten pages, which
+// have no text layer, take part in marker detection and searchable text fields.
+type OCREngine interface {
+	Recognize(img *image.RGBA) (string, error)
+}
+
+// TesseractOCR runs a locally installed tesseract compatible command line engine.
+type TesseractOCR struct {
+	Command  string // executable, "tesseract" when empty
+	Language string // language passed with -l, "eng" when empty
+}
+
+var _ OCREngine = (*TesseractOCR)(nil)
+
+// Executable returns the command that will be run.
+func (t *TesseractOCR) Executable() string {
+	if t.Command == "" {
+		return "tesseract"
+	}
+	return t.Command
+}
+
+func (t *TesseractOCR) Recognize(img *image.RGBA) (string, error) {
+	command := t.Executable()
+	language := t.Language
+	if language == "" {
+		language = "eng"
+	}
+
+	input, err := os.CreateTemp("", "notesankify-ocr-*.png")
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR input: %w", err)
+	}
+	defer os.Remove(input.Name())
+
+	if err := png.Encode(input, img); err != nil {
+		input.Close()
+		return "", fmt.Errorf("failed to write OCR input: %w", err)
+	}
+	if err := input.Close(); err != nil {
+		return "", fmt.Errorf("failed to write OCR input: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command, input.Name(), "stdout", "-l", language)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to run %s: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// CachedOCR stores recognized text on disk keyed by image content, so re-runs over
+// the same pages do not repeat OCR.
+type CachedOCR struct {
+	engine OCREngine
+	dir    string
+}
+
+var _ OCREngine = (*CachedOCR)(nil)
+
+func NewCachedOCR(engine OCREngine, dir string) (*CachedOCR, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create OCR cache directory: %w", err)
+	}
+	return &CachedOCR{engine: engine, dir: dir}, nil
+}
+
+// DefaultOCRCacheDir returns the OCR cache inside the user's cache directory.
+func DefaultOCRCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "notesankify", "ocr")
+}
+
+func (c *CachedOCR) Recognize(img *image.RGBA) (string, error) {
+	path := filepath.Join(c.dir, pixelHash(img)+".txt")
+	if cached, err := os.ReadFile(path); err == nil {
+		return string(cached), nil
+	}
+
+	text, err := c.engine.Recognize(img)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to cache OCR result: %w", err)
+	}
+	return text, nil
+}
+
+// pixelHash identifies an image by its size and raw pixels.
+func pixelHash(img *image.RGBA) string {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%dx%d:", img.Bounds().Dx(), img.Bounds().Dy())
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		start := img.PixOffset(bounds.Min.X, y)
+		hasher.Write(img.Pix[start : start+bounds.Dx()*4])
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// recognizeText runs OCR on a card image and cleans up the result the way text
+// layer text is, dropping markers and empty lines. Failures leave the text empty.
+func (p *Processor) recognizeText(img *image.RGBA) string {
+	text, err := p.config.OCR.Recognize(img)
+	if err != nil {
+		p.config.Logger.Debug("OCR failed: %v", err)
+		return ""
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = stripMarkers(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// recognizeCardText is recognizeText for a question or answer. When directives are
+// enabled, the directive lines of the recognized text are left out of it and
+// returned as directives.
+func (p *Processor) recognizeCardText(img *image.RGBA) (string, Directives) {
+	text := p.recognizeText(img)
+	if !p.config.Directives.Enabled || text == "" {
+		return text, Directives{}
+	}
+
+	var directives Directives
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if !parseDirective(line, &directives) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), directives
+}
@@ -0,0 +1,75 @@
+package pdf_test
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+// countingOCR returns fixed text and counts how often it was asked.
+type countingOCR struct {
+	text  string
+	calls int
+}
+
+func (c *countingOCR) Recognize(img *image.RGBA) (string, error) {
+	c.calls++
+	return c.text, nil
+}
+
+var _ = Describe("OCR", func() {
+	var workDir string
+
+	BeforeEach(func() {
+		var err error
+		workDir, err = os.MkdirTemp("", "notesankify-ocr-*")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	It("should cache results by image content", func() {
+		engine := &countingOCR{text: "mitochondria"}
+		cached, err := pdf.NewCachedOCR(engine, filepath.Join(workDir, "cache"))
+		Expect(err).NotTo(HaveOccurred())
+
+		first := sketch(60, 40, image.Rect(5, 5, 30, 10))
+		second := sketch(60, 40, image.Rect(5, 20, 30, 25))
+
+		for _, img := range []*image.RGBA{first, first, second, first} {
+			text, err := cached.Recognize(img)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(text).To(Equal("mitochondria"))
+		}
+		Expect(engine.calls).To(Equal(2))
+
+		reopened, err := pdf.NewCachedOCR(engine, filepath.Join(workDir, "cache"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = reopened.Recognize(second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(engine.calls).To(Equal(2))
+	})
+
+	It("should run the configured command with the language", func() {
+		script := filepath.Join(workDir, "fake-tesseract")
+		Expect(os.WriteFile(script, []byte("#!/bin/sh\n[ -f \"$1\" ] && echo \"$2 $3 $4\"\n"), 0755)).To(Succeed())
+
+		engine := &pdf.TesseractOCR{Command: script, Language: "deu"}
+		text, err := engine.Recognize(sketch(20, 20, image.Rectangle{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(text).To(Equal("stdout -l deu\n"))
+	})
+
+	It("should report a failing command", func() {
+		engine := &pdf.TesseractOCR{Command: filepath.Join(workDir, "missing")}
+		_, err := engine.Recognize(sketch(20, 20, image.Rectangle{}))
+		Expect(err).To(HaveOccurred())
+	})
+})
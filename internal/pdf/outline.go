@@ -0,0 +1,94 @@
+package pdf
+
+import (
+	"sort"
+)
+
+// OutlineEntry is a bookmark of a flattened table of contents. Level starts at 1
+// for top-level bookmarks, and Page is the zero based page the bookmark points to,
+// or negative for links to other documents.
+type OutlineEntry struct {
+	Title string
+	Page  int
+	Level int
+}
+
+// Outline maps pages to the bookmarks that enclose them. A bookmark covers its own
+// page and every following page until the next bookmark.
+type Outline struct {
+	entries []outlinePage // in page order
+}
+
+type outlinePage struct {
+	pageIndex int
+	path      []string // titles from the top-level bookmark down to this one
+}
+
+// NewOutline builds the page mapping from a flattened table of contents, where
+// each item's Level places it under the item before it.
+func NewOutline(items []OutlineEntry) *Outline {
+	outline := &Outline{}
+	var stack []string
+	for _, item := range items {
+		if item.Level < 1 {
+			continue
+		}
+		if item.Level-1 < len(stack) {
+			stack = stack[:item.Level-1]
+		}
+		// A skipped level keeps the path aligned with the nesting.
+		for len(stack) < item.Level-1 {
+			stack = append(stack, "")
+		}
+		stack = append(stack, item.Title)
+
+		// Links to other documents have no page in this one.
+		if item.Page < 0 {
+			continue
+		}
+		outline.entries = append(outline.entries, outlinePage{
+			pageIndex: item.Page,
+			path:      append([]string(nil), stack...),
+		})
+	}
+
+	sort.SliceStable(outline.entries, func(i, j int) bool {
+		return outline.entries[i].pageIndex < outline.entries[j].pageIndex
+	})
+	return outline
+}
+
+// PathForPage returns the titles of the bookmarks enclosing the page, outermost
+// first and at most maxDepth long (0 means no limit). Pages before the first
+// bookmark have no path.
+func (o *Outline) PathForPage(pageNum, maxDepth int) []string {
+	var path []string
+	for _, entry := range o.entries {
+		if entry.pageIndex > pageNum-1 {
+			break
+		}
+		path = entry.path
+	}
+
+	var titles []string
+	for _, title := range path {
+		if title == "" {
+			continue
+		}
+		if maxDepth > 0 && len(titles) == maxDepth {
+			break
+		}
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+// loadOutline reads the document's bookmarks. Documents without any have an empty outline.
+func (p *Processor) loadOutline(doc Document) *Outline {
+	items, err := doc.ToC()
+	if err != nil {
+		p.config.Logger.Debug("No outline found: %v", err)
+		return &Outline{}
+	}
+	return NewOutline(items)
+}
@@ -0,0 +1,49 @@
+package pdf_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("Outline", func() {
+	items := []pdf.OutlineEntry{
+		{Level: 1, Title: "Biology", Page: 2},
+		{Level: 2, Title: "Cells", Page: 2},
+		{Level: 2, Title: "Genetics", Page: 5},
+		{Level: 3, Title: "Mendel", Page: 6},
+		{Level: 1, Title: "Chemistry", Page: 9},
+		{Level: 1, Title: "Elsewhere", Page: -1},
+	}
+
+	DescribeTable("mapping pages to bookmarks",
+		func(pageNum, maxDepth int, expected []string) {
+			Expect(pdf.NewOutline(items).PathForPage(pageNum, maxDepth)).To(Equal(expected))
+		},
+		Entry("page before the first bookmark", 2, 0, nil),
+		Entry("first page of a bookmark", 3, 0, []string{"Biology", "Cells"}),
+		Entry("page after a bookmark", 5, 0, []string{"Biology", "Cells"}),
+		Entry("nested bookmark", 7, 0, []string{"Biology", "Genetics", "Mendel"}),
+		Entry("depth limit", 7, 2, []string{"Biology", "Genetics"}),
+		Entry("later top-level bookmark", 12, 0, []string{"Chemistry"}),
+	)
+
+	It("should skip missing levels", func() {
+		outline := pdf.NewOutline([]pdf.OutlineEntry{
+			{Level: 1, Title: "Part I", Page: 0},
+			{Level: 3, Title: "Deep", Page: 1},
+		})
+		Expect(outline.PathForPage(2, 0)).To(Equal([]string{"Part I", "Deep"}))
+		Expect(outline.PathForPage(2, 1)).To(Equal([]string{"Part I"}))
+	})
+
+	It("should order bookmarks by page", func() {
+		outline := pdf.NewOutline([]pdf.OutlineEntry{
+			{Level: 1, Title: "Appendix", Page: 8},
+			{Level: 1, Title: "Intro", Page: 0},
+		})
+		Expect(outline.PathForPage(4, 0)).To(Equal([]string{"Intro"}))
+		Expect(outline.PathForPage(9, 0)).To(Equal([]string{"Appendix"}))
+	})
+})
@@ -0,0 +1,173 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Overlay colors. Masks are translucent so the erased content stays visible.
+var (
+	overlayCellColor     = color.NRGBA{R: 0x1f, G: 0x77, B: 0xb4, A: 255}
+	overlayMarkerColor   = color.NRGBA{R: 0xff, G: 0x7f, B: 0x0e, A: 255}
+	overlaySplitColor    = color.NRGBA{R: 0xd6, G: 0x27, B: 0x28, A: 255}
+	overlayQuestionColor = color.NRGBA{R: 0x2c, G: 0xa0, B: 0x2c, A: 255}
+	overlayAnswerColor   = color.NRGBA{R: 0x94, G: 0x67, B: 0xbd, A: 255}
+	overlayMaskColor     = color.NRGBA{R: 0xd6, G: 0x27, B: 0x28, A: 96}
+)
+
+// overlayLabelWidth is the page width, in pixels, at which labels are drawn at the
+// font's native size. Wider renders scale the labels up to stay legible.
+const overlayLabelWidth = 800
+
+// pageOverlay annotates a copy of a rendered page with what the processor saw on it:
+// marker boxes, card cells, split lines, crop regions and erased areas.
+type pageOverlay struct {
+	img    *image.RGBA
+	labels []string
+	stroke int
+}
+
+func newPageOverlay(page *image.RGBA) *pageOverlay {
+	return &pageOverlay{
+		img:    cloneImage(page),
+		stroke: max(2, page.Bounds().Dx()/400),
+	}
+}
+
+func (o *pageOverlay) label(format string, args ...interface{}) {
+	o.labels = append(o.labels, fmt.Sprintf(format, args...))
+}
+
+// outline draws the border of rect inside the rectangle itself.
+func (o *pageOverlay) outline(rect image.Rectangle, c color.NRGBA) {
+	rect = rect.Intersect(o.img.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	stroke := min(o.stroke, rect.Dx()/2, rect.Dy()/2)
+	stroke = max(stroke, 1)
+	o.fill(image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+stroke), c)
+	o.fill(image.Rect(rect.Min.X, rect.Max.Y-stroke, rect.Max.X, rect.Max.Y), c)
+	o.fill(image.Rect(rect.Min.X, rect.Min.Y+stroke, rect.Min.X+stroke, rect.Max.Y-stroke), c)
+	o.fill(image.Rect(rect.Max.X-stroke, rect.Min.Y+stroke, rect.Max.X, rect.Max.Y-stroke), c)
+}
+
+func (o *pageOverlay) fill(rect image.Rectangle, c color.NRGBA) {
+	draw.Draw(o.img, rect.Intersect(o.img.Bounds()), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// split marks the boundary between a card's question and answer regions, offset
+// by the card's position on the page. Regions that do not touch only get outlines.
+func (o *pageOverlay) split(question, answer image.Rectangle, offset image.Point) {
+	question, answer = question.Add(offset), answer.Add(offset)
+	half := o.stroke / 2
+
+	switch {
+	case question.Max.Y == answer.Min.Y:
+		y := question.Max.Y
+		o.fill(image.Rect(min(question.Min.X, answer.Min.X), y-half, max(question.Max.X, answer.Max.X), y-half+o.stroke), overlaySplitColor)
+	case question.Max.X == answer.Min.X:
+		x := question.Max.X
+		o.fill(image.Rect(x-half, min(question.Min.Y, answer.Min.Y), x-half+o.stroke, max(question.Max.Y, answer.Max.Y)), overlaySplitColor)
+	}
+}
+
+// save draws the labels in the top-left corner and writes the overlay as a PNG.
+func (o *pageOverlay) save(path string) error {
+	o.drawLabels()
+	return saveImage(o.img, path)
+}
+
+func (o *pageOverlay) drawLabels() {
+	if len(o.labels) == 0 {
+		return
+	}
+
+	face := basicfont.Face7x13
+	const padding = 4
+	lineHeight := face.Height
+
+	width := 0
+	for _, line := range o.labels {
+		width = max(width, font.MeasureString(face, line).Ceil())
+	}
+	panel := image.NewRGBA(image.Rect(0, 0, width+2*padding, len(o.labels)*lineHeight+2*padding))
+	draw.Draw(panel, panel.Bounds(), image.White, image.Point{}, draw.Src)
+
+	drawer := font.Drawer{Dst: panel, Src: image.Black, Face: face}
+	for i, line := range o.labels {
+		drawer.Dot = fixed.P(padding, padding+i*lineHeight+face.Ascent)
+		drawer.DrawString(line)
+	}
+
+	// Scale the panel up with nearest neighbour sampling to keep the glyphs crisp.
+	factor := max(1, o.img.Bounds().Dx()/overlayLabelWidth)
+	origin := o.img.Bounds().Min.Add(image.Pt(o.stroke, o.stroke))
+	area := image.Rectangle{Min: origin, Max: origin.Add(panel.Bounds().Size().Mul(factor))}.Intersect(o.img.Bounds())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			o.img.SetRGBA(x, y, panel.RGBAAt((x-origin.X)/factor, (y-origin.Y)/factor))
+		}
+	}
+	o.outline(area, overlayCellColor)
+}
+
+// newOverlay starts the debug overlay of a rendered page, labelled with the file,
+// page size and matched preset and with the detected marker boxes outlined.
+func (p *Processor) newOverlay(doc Document, pageIndex int, img *image.RGBA, baseName, preset string) *pageOverlay {
+	overlay := newPageOverlay(img)
+	overlay.label("%s page %d", baseName, pageIndex+1)
+
+	if bounds, err := doc.Bound(pageIndex); err == nil {
+		overlay.label("page %d x %d pt, image %d x %d px", bounds.Dx(), bounds.Dy(), img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	if preset == "" {
+		preset = "none"
+	}
+	overlay.label("preset: %s", preset)
+
+	blocks, err := doc.TextBlocks(pageIndex)
+	if err != nil {
+		p.config.Logger.Debug("Page %d: failed to locate markers for overlay: %v", pageIndex+1, err)
+		return overlay
+	}
+	for _, rect := range KeywordRects(blocks, utils.QuestionKeyword, utils.AnswerKeyword) {
+		overlay.outline(scaleRect(rect, img.Bounds().Min, PointsToPixels), overlayMarkerColor)
+	}
+	return overlay
+}
+
+// annotateCard outlines the question and answer regions of a card located at
+// offset on the page, after whitespace trimming when that is enabled.
+func (p *Processor) annotateCard(overlay *pageOverlay, card *image.RGBA, offset image.Point, split models.SplitSpec, scale float64) {
+	bounds := card.Bounds()
+	question, answer := SplitRects(bounds, split.ForOrientation(bounds.Dx() > bounds.Dy()), scale)
+	overlay.split(question, answer, offset)
+
+	if p.config.PostProcessing.TrimWhitespace {
+		question = trimmedBounds(cropImage(card, question), p.config.PostProcessing.TrimPadding).Add(question.Min)
+		answer = trimmedBounds(cropImage(card, answer), p.config.PostProcessing.TrimPadding).Add(answer.Min)
+	}
+	overlay.outline(question.Add(offset), overlayQuestionColor)
+	overlay.outline(answer.Add(offset), overlayAnswerColor)
+}
+
+// saveOverlay writes the overlay to the debug directory, named by PDF and page.
+func (p *Processor) saveOverlay(overlay *pageOverlay, baseName string, pageNum int) {
+	path := filepath.Join(p.config.DebugOverlayDir, fmt.Sprintf("%s_page%03d.png", baseName, pageNum))
+	if err := overlay.save(path); err != nil {
+		p.config.Logger.Info("Failed to save debug overlay for page %d: %v", pageNum, err)
+		return
+	}
+	p.config.Logger.Debug("Saved debug overlay: %s", path)
+}
@@ -0,0 +1,199 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageSelection picks the pages of a PDF to process. It is parsed from a comma
+// separated list of pages and ranges such as "1-10,15,-3..":
+//
+//	15       page 15
+//	1-10     pages 1 to 10, also written 1..10
+//	5..      page 5 to the last page, also written 5-
+//	..10     the first page to page 10
+//	-3..     negative numbers count from the end: the last three pages
+//	!12-14   leaves pages 12 to 14 out
+//
+// Exclusions alone start from every page. Pages past the end of a PDF are ignored.
+// The zero value selects every page.
+type PageSelection struct {
+	expression string
+	include    []pageRange
+	exclude    []pageRange
+}
+
+// pageRange is an inclusive range of page numbers. Negative numbers count back from
+// the last page, and zero leaves that end of the range open.
+type pageRange struct {
+	first, last int
+}
+
+// ParsePageSelection parses a page selection expression. An empty expression
+// selects every page.
+func ParsePageSelection(expression string) (PageSelection, error) {
+	selection := PageSelection{expression: strings.TrimSpace(expression)}
+	if selection.expression == "" {
+		return selection, nil
+	}
+
+	for _, item := range strings.Split(selection.expression, ",") {
+		item = strings.TrimSpace(item)
+		exclude := strings.HasPrefix(item, "!")
+		pages, err := parsePageRange(strings.TrimSpace(strings.TrimPrefix(item, "!")))
+		if err != nil {
+			return PageSelection{}, fmt.Errorf("invalid page selection %q: %w", expression, err)
+		}
+		if exclude {
+			selection.exclude = append(selection.exclude, pages)
+		} else {
+			selection.include = append(selection.include, pages)
+		}
+	}
+	return selection, nil
+}
+
+func parsePageRange(item string) (pageRange, error) {
+	if item == "" {
+		return pageRange{}, errors.New("empty page range")
+	}
+
+	first, last, isRange := strings.Cut(item, "..")
+	if !isRange {
+		// A dash after the first character separates a range; a leading one is a sign.
+		if i := strings.Index(item[1:], "-"); i >= 0 {
+			first, last, isRange = item[:i+1], item[i+2:], true
+		}
+	}
+	if !isRange {
+		page, err := parsePageNumber(item)
+		return pageRange{first: page, last: page}, err
+	}
+
+	if strings.TrimSpace(first) == "" && strings.TrimSpace(last) == "" {
+		return pageRange{}, fmt.Errorf("range %q has no ends", item)
+	}
+	var pages pageRange
+	var err error
+	if strings.TrimSpace(first) != "" {
+		if pages.first, err = parsePageNumber(first); err != nil {
+			return pageRange{}, err
+		}
+	}
+	if strings.TrimSpace(last) != "" {
+		if pages.last, err = parsePageNumber(last); err != nil {
+			return pageRange{}, err
+		}
+	}
+	return pages, nil
+}
+
+func parsePageNumber(value string) (int, error) {
+	page, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a page number", strings.TrimSpace(value))
+	}
+	if page == 0 {
+		return 0, errors.New("page numbers start at 1")
+	}
+	return page, nil
+}
+
+// All reports whether the selection keeps every page.
+func (s PageSelection) All() bool {
+	return s.expression == ""
+}
+
+// String returns the expression the selection was parsed from.
+func (s PageSelection) String() string {
+	return s.expression
+}
+
+// Pages returns the selected page numbers, in order, of a PDF with numPage pages.
+func (s PageSelection) Pages(numPage int) []int {
+	selected := make([]bool, numPage+1)
+	if len(s.include) == 0 {
+		for pageNum := range selected {
+			selected[pageNum] = true
+		}
+	}
+	for _, pages := range s.include {
+		pages.mark(selected, true)
+	}
+	for _, pages := range s.exclude {
+		pages.mark(selected, false)
+	}
+
+	var pageNumbers []int
+	for pageNum := 1; pageNum <= numPage; pageNum++ {
+		if selected[pageNum] {
+			pageNumbers = append(pageNumbers, pageNum)
+		}
+	}
+	return pageNumbers
+}
+
+// mark sets the pages of the range in selected, indexed by page number.
+func (r pageRange) mark(selected []bool, value bool) {
+	numPage := len(selected) - 1
+	resolve := func(page, open int) int {
+		switch {
+		case page == 0:
+			return open
+		case page < 0:
+			return numPage + 1 + page
+		}
+		return page
+	}
+
+	first, last := max(resolve(r.first, 1), 1), min(resolve(r.last, numPage), numPage)
+	for pageNum := first; pageNum <= last; pageNum++ {
+		selected[pageNum] = value
+	}
+}
+
+// FormatPageRanges lists page numbers compactly, e.g. "1-10, 15", or "none".
+func FormatPageRanges(pageNumbers []int) string {
+	if len(pageNumbers) == 0 {
+		return "none"
+	}
+
+	var ranges []string
+	for start := 0; start < len(pageNumbers); {
+		end := start
+		for end+1 < len(pageNumbers) && pageNumbers[end+1] == pageNumbers[end]+1 {
+			end++
+		}
+		if end == start {
+			ranges = append(ranges, strconv.Itoa(pageNumbers[start]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", pageNumbers[start], pageNumbers[end]))
+		}
+		start = end + 1
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// PageRule applies a page selection to the PDFs matching Pattern, which is matched
+// against the end of each PDF's path like PasswordRule patterns. An empty pattern
+// matches every PDF.
+type PageRule struct {
+	Pattern string
+	Pages   PageSelection
+}
+
+// PageRules pick each PDF's pages by the first matching rule.
+type PageRules []PageRule
+
+// SelectionFor returns the selection of the first rule matching the PDF, or every
+// page when none does.
+func (r PageRules) SelectionFor(pdfPath string) PageSelection {
+	for _, rule := range r {
+		if rule.Pattern == "" || matchPathSuffix(rule.Pattern, pdfPath) {
+			return rule.Pages
+		}
+	}
+	return PageSelection{}
+}
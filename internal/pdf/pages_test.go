@@ -0,0 +1,63 @@
+package pdf_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("PageSelection", func() {
+	DescribeTable("selecting pages of a 20 page PDF",
+		func(expression string, expected []int) {
+			selection, err := pdf.ParsePageSelection(expression)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selection.Pages(20)).To(Equal(expected))
+		},
+		Entry("everything when empty", "", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}),
+		Entry("pages and ranges", "1-3, 15", []int{1, 2, 3, 15}),
+		Entry("ranges with dots", "4..6", []int{4, 5, 6}),
+		Entry("open ranges", "..2,19-", []int{1, 2, 19, 20}),
+		Entry("the last pages", "-3..", []int{18, 19, 20}),
+		Entry("a page counted from the end", "-1", []int{20}),
+		Entry("ranges counted from the end", "-5..-4", []int{16, 17}),
+		Entry("exclusions", "1-5,!2-3", []int{1, 4, 5}),
+		Entry("exclusions alone", "!3..", []int{1, 2}),
+		Entry("overlapping ranges in order", "3,1-4", []int{1, 2, 3, 4}),
+		Entry("pages past the end", "18-30,25", []int{18, 19, 20}),
+	)
+
+	DescribeTable("rejecting invalid expressions",
+		func(expression string) {
+			_, err := pdf.ParsePageSelection(expression)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("page zero", "0-3"),
+		Entry("not a number", "first"),
+		Entry("empty item", "1,,3"),
+		Entry("range without ends", ".."),
+	)
+
+	It("should describe page numbers as ranges", func() {
+		Expect(pdf.FormatPageRanges([]int{1, 2, 3, 5, 7, 8})).To(Equal("1-3, 5, 7-8"))
+		Expect(pdf.FormatPageRanges(nil)).To(Equal("none"))
+	})
+
+	It("should pick the selection of the first matching rule", func() {
+		lectures, err := pdf.ParsePageSelection("-3..")
+		Expect(err).NotTo(HaveOccurred())
+		textbook, err := pdf.ParsePageSelection("1-180")
+		Expect(err).NotTo(HaveOccurred())
+
+		rules := pdf.PageRules{
+			{Pattern: "lectures/*.pdf", Pages: lectures},
+			{Pattern: "textbook.pdf", Pages: textbook},
+		}
+		Expect(rules.SelectionFor("/notes/lectures/week1.pdf").String()).To(Equal("-3.."))
+		Expect(rules.SelectionFor("/notes/textbook.pdf").String()).To(Equal("1-180"))
+		Expect(rules.SelectionFor("/notes/other.pdf").All()).To(BeTrue())
+
+		everything := append(pdf.PageRules{{Pages: textbook}}, rules...)
+		Expect(everything.SelectionFor("/notes/lectures/week1.pdf").String()).To(Equal("1-180"))
+	})
+})
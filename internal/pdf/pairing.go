@@ -0,0 +1,261 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+// PairingMode controls whether a card is built from one page or from two.
+type PairingMode int
+
+const (
+	// PairingNone treats every page as a complete card.
+	PairingNone PairingMode = iota
+	// PairingConsecutive pairs eligible pages in order: the first is the question, the next the answer.
+	// With the marker check on, only pages carrying a QUESTION or ANSWER marker are eligible.
+	PairingConsecutive
+	// PairingMarkers pairs a page marked QUESTION with the next page marked ANSWER.
+	// Pages carrying both markers are processed as regular single-page cards.
+	PairingMarkers
+)
+
+var pairingModeNames = map[string]PairingMode{
+	"":            PairingNone,
+	"none":        PairingNone,
+	"consecutive": PairingConsecutive,
+	"markers":     PairingMarkers,
+}
+
+func ParsePairingMode(name string) (PairingMode, error) {
+	mode, ok := pairingModeNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return PairingNone, fmt.Errorf("unknown page pairing mode: %s", name)
+	}
+	return mode, nil
+}
+
+// processPagePairs walks the selected pages, given by page number, building two-page
+// cards. Pages that never find a partner are recorded in stats.UnpairedPages.
+func (p *Processor) processPagePairs(ctx context.Context, doc Document, pages []int, baseName string, stats *ProcessingStats) error {
+	// pendingQuestion is the index of the page waiting for its answer, and
+	// pendingDecision the index of its decision in stats.Decisions.
+	pendingQuestion, pendingDecision := -1, -1
+
+	flushPending := func() {
+		if pendingQuestion >= 0 {
+			p.config.Logger.Debug("Page %d has no matching answer page", pendingQuestion+1)
+			stats.UnpairedPages = append(stats.UnpairedPages, pendingQuestion+1)
+			stats.Decisions[pendingDecision].Outcome = PageUnpaired
+			stats.Decisions[pendingDecision].Reason = "no answer page followed"
+			pendingQuestion, pendingDecision = -1, -1
+		}
+	}
+
+	for _, pageNum := range pages {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		pageIndex := pageNum - 1
+		stats.Decisions = append(stats.Decisions, PageDecision{PageNumber: pageNum})
+		decision := &stats.Decisions[len(stats.Decisions)-1]
+
+		if err := p.measurePage(doc, pageIndex, decision); err != nil {
+			decision.fail(err)
+			continue
+		}
+		if p.config.CheckDimensions && decision.Preset == "" {
+			decision.skip(decision.dimensionMismatch())
+			continue
+		}
+
+		if p.config.PagePairing == PairingMarkers {
+			if err := p.checkMarkers(doc, pageIndex, decision); err != nil {
+				decision.fail(err)
+				continue
+			}
+
+			switch {
+			case decision.HasQuestion && decision.HasAnswer:
+				recordCards(stats, decision, func() error {
+					return p.processPage(doc, pageIndex, baseName, stats)
+				})
+				continue
+			case decision.HasQuestion:
+				flushPending()
+				pendingQuestion, pendingDecision = pageIndex, len(stats.Decisions)-1
+				continue
+			case !decision.HasAnswer:
+				decision.skip("QUESTION/ANSWER markers %s", decision.Markers())
+				continue
+			case pendingQuestion < 0:
+				p.config.Logger.Debug("Answer page %d has no preceding question page", pageNum)
+				stats.UnpairedPages = append(stats.UnpairedPages, pageNum)
+				decision.Outcome = PageUnpaired
+				decision.Reason = "no question page before this answer page"
+				continue
+			}
+		} else {
+			if p.config.CheckMarkers {
+				if err := p.checkMarkers(doc, pageIndex, decision); err != nil {
+					decision.fail(err)
+					continue
+				}
+				if !decision.HasQuestion && !decision.HasAnswer {
+					decision.skip("QUESTION/ANSWER markers %s", decision.Markers())
+					continue
+				}
+			}
+			if pendingQuestion < 0 {
+				pendingQuestion, pendingDecision = pageIndex, len(stats.Decisions)-1
+				continue
+			}
+		}
+
+		p.config.Logger.Debug("Processing pages %d and %d as flashcard", pendingQuestion+1, pageNum)
+		question := &stats.Decisions[pendingDecision]
+		questionIndex := pendingQuestion
+		recordCards(stats, question, func() error {
+			return p.processPagePair(doc, questionIndex, pageIndex, baseName, stats)
+		})
+
+		decision.Outcome = question.Outcome
+		decision.Reason = question.Reason
+		if question.Outcome == PageCard {
+			question.Reason = fmt.Sprintf("answer on page %d", pageNum)
+			decision.Reason = fmt.Sprintf("answer for page %d", questionIndex+1)
+		}
+		pendingQuestion, pendingDecision = -1, -1
+	}
+
+	flushPending()
+	return nil
+}
+
+// processPagePair builds a card from a question page and an answer page. The raw
+// pages are hashed unless post-processing is configured to run before hashing.
+func (p *Processor) processPagePair(doc Document, questionIndex, answerIndex int, baseName string, stats *ProcessingStats) error {
+	rawQuestion, err := doc.ImageDPI(questionIndex, RenderDPI)
+	if err != nil {
+		return fmt.Errorf("failed to extract question image: %w", err)
+	}
+
+	rawAnswer, err := doc.ImageDPI(answerIndex, RenderDPI)
+	if err != nil {
+		return fmt.Errorf("failed to extract answer image: %w", err)
+	}
+
+	questionText, questionMarkup := p.pageText(doc, questionIndex, rawQuestion)
+	answerText, answerMarkup := p.pageText(doc, answerIndex, rawAnswer)
+	questionImg := p.cleanPairedPage(doc, questionIndex, rawQuestion, questionMarkup)
+	answerImg := p.cleanPairedPage(doc, answerIndex, rawAnswer, answerMarkup)
+
+	fullHash, err := p.pairHash(rawQuestion, rawAnswer, questionImg, answerImg)
+	if err != nil {
+		return fmt.Errorf("failed to generate hash: %w", err)
+	}
+
+	pair, err := p.splitter.SaveImagePair(questionImg, answerImg, baseName, fullHash)
+	if err != nil {
+		return fmt.Errorf("failed to save card images: %w", err)
+	}
+
+	questionPage := questionIndex + 1
+	if preset, ok := p.presetForPage(doc, questionIndex); ok {
+		stats.PagePresets[questionPage] = preset.DisplayName()
+	}
+	if p.config.DebugOverlayDir != "" {
+		p.savePairOverlays(doc, questionIndex, answerIndex, rawQuestion, rawAnswer, baseName)
+	}
+	pair.Source = CardSource{PageNumber: questionPage, AnswerPageNumber: answerIndex + 1}
+	pair.QuestionText, pair.AnswerText = questionText, answerText
+	pair.Directives = questionMarkup.directives.merge(answerMarkup.directives)
+	if p.config.BlankAnswers.Enabled && answerText == "" {
+		pair.BlankAnswer = p.answerPageIsBlank(doc, answerIndex, answerMarkup.blank(rawAnswer))
+	}
+	if p.config.OCR != nil {
+		var recognized Directives
+		if pair.QuestionText == "" {
+			pair.QuestionText, recognized = p.recognizeCardText(questionImg)
+		}
+		if pair.AnswerText == "" {
+			var answerDirectives Directives
+			pair.AnswerText, answerDirectives = p.recognizeCardText(answerImg)
+			recognized = recognized.merge(answerDirectives)
+		}
+		pair.Directives = recognized.merge(pair.Directives)
+	}
+
+	stats.ImagePairs = append(stats.ImagePairs, *pair)
+	stats.PageNumbers = append(stats.PageNumbers, questionPage)
+	stats.FlashcardCount++
+
+	p.config.Logger.Debug("Successfully processed pages %d and %d (Hash:%s)", questionPage, answerIndex+1, fullHash)
+	return nil
+}
+
+// savePairOverlays writes the debug overlays of a question page and its answer page.
+func (p *Processor) savePairOverlays(doc Document, questionIndex, answerIndex int, questionImg, answerImg *image.RGBA, baseName string) {
+	presetName := func(pageIndex int) string {
+		preset, ok := p.presetForPage(doc, pageIndex)
+		if !ok {
+			return ""
+		}
+		return preset.DisplayName()
+	}
+
+	questionOverlay := p.newOverlay(doc, questionIndex, questionImg, baseName, presetName(questionIndex))
+	questionOverlay.label("question page, answer on page %d", answerIndex+1)
+	questionOverlay.outline(questionImg.Bounds(), overlayQuestionColor)
+	p.saveOverlay(questionOverlay, baseName, questionIndex+1)
+
+	answerOverlay := p.newOverlay(doc, answerIndex, answerImg, baseName, presetName(answerIndex))
+	answerOverlay.label("answer page for page %d", questionIndex+1)
+	answerOverlay.outline(answerImg.Bounds(), overlayAnswerColor)
+	p.saveOverlay(answerOverlay, baseName, answerIndex+1)
+}
+
+// pageText returns the typed text of a whole page with the markers removed, and
+// the page's directives when they are enabled. img is the rendered page, on which
+// the directive lines are masked when configured.
+func (p *Processor) pageText(doc Document, pageIndex int, img *image.RGBA) (string, cardMarkup) {
+	var markup cardMarkup
+	bounds, err := doc.Bound(pageIndex)
+	if err != nil {
+		p.config.Logger.Debug("Page %d: failed to get bounds: %v", pageIndex+1, err)
+		return "", markup
+	}
+	blocks, err := doc.TextBlocks(pageIndex)
+	if err != nil {
+		p.config.Logger.Debug("Page %d: failed to extract text blocks: %v", pageIndex+1, err)
+		return "", markup
+	}
+
+	if p.config.Directives.Enabled {
+		directives, lines := findDirectives(blocks)
+		markup.directives = directives
+		markup.addDirectiveLines(lines, models.Rect{}, img.Bounds().Sub(img.Bounds().Min), PointsToPixels, p.config.Directives.Mask)
+		blocks = markup.cardText(blocks)
+	}
+	return RegionText(blocks, models.Rect{Width: float64(bounds.Dx()), Height: float64(bounds.Dy())}), markup
+}
+
+// cleanPairedPage applies the post-processing steps and directive masks of a single
+// card to one page of a page pair.
+func (p *Processor) cleanPairedPage(doc Document, pageIndex int, img *image.RGBA, markup cardMarkup) *image.RGBA {
+	cleaned := img
+	if p.config.PostProcessing.cleansPage() {
+		cleaned = p.cleanPage(doc, pageIndex, img, nil)
+	}
+	cleaned = markup.blank(cleaned)
+	if p.config.PostProcessing.TrimWhitespace {
+		cleaned = trimUniformBorder(cleaned, p.config.PostProcessing.TrimPadding)
+	}
+	return cleaned
+}
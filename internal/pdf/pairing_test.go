@@ -0,0 +1,111 @@
+package pdf_test
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/config"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+var _ = Describe("Page pairing", func() {
+	renderer := syntheticRenderer{
+		pages: []syntheticPage{
+			{width: 455, height: 588, lines: []pdf.TextBlock{
+				{Text: "QUESTION", Rect: models.Rect{X: 40, Y: 40, Width: 80, Height: 14}},
+				{Text: "What is ATP?", Rect: models.Rect{X: 40, Y: 80, Width: 120, Height: 14}},
+				{Text: "#tag biology", Rect: models.Rect{X: 40, Y: 540, Width: 110, Height: 14}},
+			}},
+			{width: 455, height: 588, lines: []pdf.TextBlock{
+				{Text: "Cover page", Rect: models.Rect{X: 40, Y: 40, Width: 100, Height: 14}},
+			}},
+			{width: 455, height: 588, lines: []pdf.TextBlock{
+				{Text: "ANSWER", Rect: models.Rect{X: 40, Y: 40, Width: 70, Height: 14}},
+				{Text: "Energy currency", Rect: models.Rect{X: 40, Y: 80, Width: 150, Height: 14}},
+			}},
+		},
+	}
+
+	process := func(options pdf.ProcessingOptions, postProcessing pdf.PostProcessingOptions) pdf.ProcessingStats {
+		tempDir, err := os.MkdirTemp("", "notesankify-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tempDir)
+
+		processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+			TempDir:           tempDir,
+			OutputDir:         tempDir,
+			ProcessingOptions: options,
+			PostProcessing:    postProcessing,
+			Renderer:          renderer,
+			Logger:            processorTestLogger(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+		Expect(err).NotTo(HaveOccurred())
+		return stats
+	}
+
+	It("should pair pages in folders whose override file asks for it", func() {
+		tempDir, err := os.MkdirTemp("", "notesankify-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tempDir)
+
+		processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+			TempDir:   tempDir,
+			OutputDir: tempDir,
+			Renderer:  renderer,
+			Logger:    processorTestLogger(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		var overrides config.Overrides
+		overrides.Processing.PagePairing = "markers"
+		stats, err := processor.WithOverrides(overrides).ProcessPDF(context.Background(), "synthetic.pdf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.ImagePairs).To(HaveLen(1))
+		Expect(stats.ImagePairs[0].Source.AnswerPageNumber).To(Equal(3))
+
+		stats, err = processor.ProcessPDF(context.Background(), "synthetic.pdf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.ImagePairs).To(HaveLen(3))
+	})
+
+	It("should skip unmarked pages in consecutive mode when checking markers", func() {
+		stats := process(pdf.ProcessingOptions{PagePairing: pdf.PairingConsecutive, CheckMarkers: true}, pdf.PostProcessingOptions{})
+
+		Expect(stats.ImagePairs).To(HaveLen(1))
+		Expect(stats.ImagePairs[0].Source.PageNumber).To(Equal(1))
+		Expect(stats.ImagePairs[0].Source.AnswerPageNumber).To(Equal(3))
+		Expect(stats.Decisions[1].Outcome).To(Equal(pdf.PageSkipped))
+
+		unchecked := process(pdf.ProcessingOptions{PagePairing: pdf.PairingConsecutive}, pdf.PostProcessingOptions{})
+		Expect(unchecked.ImagePairs).To(HaveLen(1))
+		Expect(unchecked.ImagePairs[0].Source.AnswerPageNumber).To(Equal(2))
+		Expect(unchecked.UnpairedPages).To(Equal([]int{3}))
+	})
+
+	It("should post-process, mask and hash both pages like single-page cards", func() {
+		options := pdf.ProcessingOptions{PagePairing: pdf.PairingMarkers}
+		plain := process(options, pdf.PostProcessingOptions{}).ImagePairs[0]
+		plainQuestionInk := pdf.InkCoverage(readImage(plain.Question))
+		plainAnswerInk := pdf.InkCoverage(readImage(plain.Answer))
+
+		options.Directives = pdf.DirectiveOptions{Enabled: true, Mask: true}
+		cleaned := process(options, pdf.PostProcessingOptions{EraseMarkers: true}).ImagePairs[0]
+		Expect(cleaned.Hash).To(Equal(plain.Hash))
+		Expect(cleaned.Directives.Tags).To(Equal([]string{"biology"}))
+		Expect(pdf.InkCoverage(readImage(cleaned.Question))).To(BeNumerically("<", plainQuestionInk))
+		Expect(pdf.InkCoverage(readImage(cleaned.Answer))).To(BeNumerically("<", plainAnswerInk))
+
+		trimmed := process(options, pdf.PostProcessingOptions{TrimWhitespace: true}).ImagePairs[0]
+		Expect(readImage(trimmed.Question).Bounds().Dx()).To(BeNumerically("<", readImage(plain.Question).Bounds().Dx()))
+
+		hashedAfter := process(options, pdf.PostProcessingOptions{EraseMarkers: true, BeforeHash: true}).ImagePairs[0]
+		Expect(hashedAfter.Hash).NotTo(Equal(plain.Hash))
+	})
+})
@@ -0,0 +1,179 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// PasswordEnvVar names the environment variable holding a password tried on every
+// encrypted PDF.
+const PasswordEnvVar = "NOTESANKIFY_PDF_PASSWORD"
+
+var (
+	// ErrPasswordRequired is returned for encrypted PDFs no password was supplied for.
+	ErrPasswordRequired = errors.New("PDF is encrypted and no password was supplied")
+	// ErrWrongPassword is returned for encrypted PDFs none of the supplied passwords open.
+	ErrWrongPassword = errors.New("PDF is encrypted and the supplied passwords do not open it")
+	// ErrEncryptionUnsupported is returned by renderers that cannot open encrypted
+	// PDFs without writing a decrypted copy to disk.
+	ErrEncryptionUnsupported = errors.New("PDF is encrypted, which the poppler renderer does not support; use the fitz renderer")
+)
+
+// IsEncryptedError reports whether err means a PDF was skipped for lack of a
+// working password.
+func IsEncryptedError(err error) bool {
+	return errors.Is(err, ErrPasswordRequired) || errors.Is(err, ErrWrongPassword) ||
+		errors.Is(err, ErrEncryptionUnsupported)
+}
+
+// PasswordProvider supplies the password to try for an encrypted PDF, reporting
+// false when it has none for that file.
+type PasswordProvider interface {
+	Password(pdfPath string) (string, bool)
+}
+
+// PasswordFunc adapts a function, such as an interactive prompt, to a PasswordProvider.
+type PasswordFunc func(pdfPath string) (string, bool)
+
+func (f PasswordFunc) Password(pdfPath string) (string, bool) {
+	return f(pdfPath)
+}
+
+// EnvPassword supplies the password held in the named environment variable for every PDF.
+type EnvPassword string
+
+func (e EnvPassword) Password(pdfPath string) (string, bool) {
+	password, ok := os.LookupEnv(string(e))
+	return password, ok && password != ""
+}
+
+// PasswordRule assigns a password to the PDFs matching Pattern, a filepath.Match
+// pattern compared with the end of the PDF's path: "exam.pdf" matches that file in
+// any folder and "biology/*.pdf" every PDF directly inside a biology folder.
+type PasswordRule struct {
+	Pattern  string
+	Password string
+}
+
+// PasswordRules supplies the password of the first matching rule.
+type PasswordRules []PasswordRule
+
+func (r PasswordRules) Password(pdfPath string) (string, bool) {
+	for _, rule := range r {
+		if matchPathSuffix(rule.Pattern, pdfPath) {
+			return rule.Password, true
+		}
+	}
+	return "", false
+}
+
+func matchPathSuffix(pattern, path string) bool {
+	pattern = filepath.Clean(filepath.FromSlash(pattern))
+	parts := strings.Split(filepath.Clean(path), string(filepath.Separator))
+	for i := range parts {
+		if matched, _ := filepath.Match(pattern, filepath.Join(parts[i:]...)); matched {
+			return true
+		}
+	}
+	if filepath.IsAbs(pattern) {
+		matched, _ := filepath.Match(pattern, filepath.Clean(path))
+		return matched
+	}
+	return false
+}
+
+// OpenDocument opens a PDF with fitz. Encrypted PDFs are decrypted in memory with
+// the first supplied password that works, so no decrypted copy is written to disk.
+func OpenDocument(path string, passwords []PasswordProvider) (*fitz.Document, error) {
+	doc, err := fitz.New(path)
+	if err == nil {
+		return doc, nil
+	}
+	if !errors.Is(err, fitz.ErrNeedsPassword) {
+		return nil, err
+	}
+	doc.Close()
+
+	decrypted, err := unlockPDF(path, passwords)
+	if err != nil {
+		return nil, err
+	}
+	doc, err = fitz.NewFromMemory(decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open decrypted PDF: %w", err)
+	}
+	return doc, nil
+}
+
+// unlockPDF returns the contents of an encrypted PDF decrypted with the first
+// supplied password that works.
+func unlockPDF(path string, passwords []PasswordProvider) ([]byte, error) {
+	tried := 0
+	for _, provider := range passwords {
+		password, ok := provider.Password(path)
+		if !ok {
+			continue
+		}
+		tried++
+
+		// Decryption errors are not passed on, only whether a password worked.
+		if decrypted, err := decryptPDF(path, password); err == nil {
+			return decrypted, nil
+		}
+	}
+
+	if tried == 0 {
+		return nil, ErrPasswordRequired
+	}
+	return nil, ErrWrongPassword
+}
+
+// OpenDocument opens a PDF with the processor's renderer and passwords, in the
+// render worker when one is configured.
+func (p *Processor) OpenDocument(path string) (Document, error) {
+	return p.openDocument(context.Background(), path)
+}
+
+// openDocument opens a PDF, giving worker calls until the context's deadline.
+func (p *Processor) openDocument(ctx context.Context, path string) (Document, error) {
+	if p.worker == nil {
+		return p.config.Renderer.Open(path, p.config.Passwords)
+	}
+
+	// Return nil documents as nil interfaces.
+	deadline, _ := ctx.Deadline()
+	doc, err := p.worker.open(path, p.config.Passwords, deadline)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decryptPDF returns the PDF without its encryption, trying the password as both
+// the user and the owner password.
+func decryptPDF(path, password string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+
+	var decrypted bytes.Buffer
+	if err := api.Decrypt(f, &decrypted, conf); err != nil {
+		return nil, err
+	}
+	return decrypted.Bytes(), nil
+}
@@ -0,0 +1,46 @@
+package pdf_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("Passwords", func() {
+	rules := pdf.PasswordRules{
+		{Pattern: "exam.pdf", Password: "exam-password"},
+		{Pattern: "biology/*.pdf", Password: "biology-password"},
+		{Pattern: "/archive/*/*.pdf", Password: "archive-password"},
+	}
+
+	DescribeTable("matching rules against PDF paths",
+		func(path, expected string) {
+			password, ok := rules.Password(path)
+			Expect(ok).To(Equal(expected != ""))
+			Expect(password).To(Equal(expected))
+		},
+		Entry("file name in any folder", "/notes/chemistry/exam.pdf", "exam-password"),
+		Entry("file in a matching folder", "/notes/biology/cells.pdf", "biology-password"),
+		Entry("file below a matching folder", "/notes/biology/extra/cells.pdf", ""),
+		Entry("absolute pattern", "/archive/2023/old.pdf", "archive-password"),
+		Entry("first matching rule wins", "/notes/biology/exam.pdf", "exam-password"),
+		Entry("no matching rule", "/notes/physics/waves.pdf", ""),
+	)
+
+	It("should read the password from the environment", func() {
+		const name = "NOTESANKIFY_TEST_PDF_PASSWORD"
+		provider := pdf.EnvPassword(name)
+
+		_, ok := provider.Password("any.pdf")
+		Expect(ok).To(BeFalse())
+
+		Expect(os.Setenv(name, "secret")).To(Succeed())
+		defer os.Unsetenv(name)
+		password, ok := provider.Password("any.pdf")
+		Expect(ok).To(BeTrue())
+		Expect(password).To(Equal("secret"))
+	})
+})
@@ -0,0 +1,303 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// PopplerRenderer drives a locally installed poppler (pdfinfo, pdftotext and
+// pdftoppm), for systems where MuPDF cannot be used. Every call runs a command, so
+// it is slower than FitzRenderer. Bookmarks are read with pdfcpu. Encrypted PDFs
+// are refused, as the commands could only read them from a decrypted copy on disk.
+type PopplerRenderer struct {
+	Dir string // directory holding the poppler executables, searched on PATH when empty
+}
+
+var _ Renderer = (*PopplerRenderer)(nil)
+
+// popplerCommands are the executables PopplerRenderer runs.
+var popplerCommands = []string{"pdfinfo", "pdftotext", "pdftoppm"}
+
+// errPopplerPassword is returned by poppler commands run on an encrypted PDF.
+var errPopplerPassword = errors.New("incorrect password")
+
+// Check reports the first poppler executable that cannot be found.
+func (r *PopplerRenderer) Check() error {
+	for _, name := range popplerCommands {
+		if _, err := exec.LookPath(r.command(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PopplerRenderer) command(name string) string {
+	if r.Dir == "" {
+		return name
+	}
+	return filepath.Join(r.Dir, name)
+}
+
+func (r *PopplerRenderer) run(name string, args ...string) ([]byte, error) {
+	command := r.command(name)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if strings.Contains(message, "Incorrect password") {
+			return nil, errPopplerPassword
+		}
+		return nil, fmt.Errorf("failed to run %s: %w: %s", command, err, message)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (r *PopplerRenderer) Open(path string, _ []PasswordProvider) (Document, error) {
+	doc := &popplerDocument{renderer: r, path: path}
+
+	output, err := r.run("pdfinfo", doc.infoArgs()...)
+	if errors.Is(err, errPopplerPassword) {
+		return nil, ErrEncryptionUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.info = ParsePopplerInfo(string(output))
+	return doc, nil
+}
+
+// popplerDocument is a PDF read through the poppler commands.
+type popplerDocument struct {
+	renderer *PopplerRenderer
+	path     string
+	info     PopplerInfo
+}
+
+var _ Document = (*popplerDocument)(nil)
+
+// infoArgs lists every page, as pdfinfo stops at the last one.
+func (d *popplerDocument) infoArgs() []string {
+	return []string{"-enc", "UTF-8", "-f", "1", "-l", strconv.Itoa(math.MaxInt32), d.path}
+}
+
+func (d *popplerDocument) pageArgs(pageNumber int) ([]string, error) {
+	if pageNumber < 0 || pageNumber >= d.info.NumPage {
+		return nil, fmt.Errorf("page %d does not exist", pageNumber+1)
+	}
+	page := strconv.Itoa(pageNumber + 1)
+	return []string{"-f", page, "-l", page}, nil
+}
+
+func (d *popplerDocument) NumPage() int {
+	return d.info.NumPage
+}
+
+func (d *popplerDocument) Bound(pageNumber int) (image.Rectangle, error) {
+	if _, err := d.pageArgs(pageNumber); err != nil {
+		return image.Rectangle{}, err
+	}
+	if pageNumber >= len(d.info.Bounds) {
+		return image.Rectangle{}, fmt.Errorf("pdfinfo did not report the size of page %d", pageNumber+1)
+	}
+	return d.info.Bounds[pageNumber], nil
+}
+
+func (d *popplerDocument) Text(pageNumber int) (string, error) {
+	args, err := d.pageArgs(pageNumber)
+	if err != nil {
+		return "", err
+	}
+	output, err := d.renderer.run("pdftotext", append(args, "-enc", "UTF-8", "-nopgbrk", d.path, "-")...)
+	return string(output), err
+}
+
+func (d *popplerDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	args, err := d.pageArgs(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	output, err := d.renderer.run("pdftotext", append(args, "-enc", "UTF-8", "-bbox-layout", d.path, "-")...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePopplerTextBlocks(string(output)), nil
+}
+
+func (d *popplerDocument) ImageDPI(pageNumber int, dpi float64) (*image.RGBA, error) {
+	args, err := d.pageArgs(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "notesankify-poppler-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create render directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// -singlefile names the image after the root without a page number suffix.
+	root := filepath.Join(dir, "page")
+	args = append(args, "-r", strconv.FormatFloat(dpi, 'f', -1, 64), "-cropbox", "-png", "-singlefile", d.path, root)
+	if _, err := d.renderer.run("pdftoppm", args...); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(root + ".png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rendered page: %w", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered page: %w", err)
+	}
+	return cropImage(img, img.Bounds()), nil
+}
+
+func (d *popplerDocument) ToC() ([]OutlineEntry, error) {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bookmarks, err := api.Bookmarks(f, model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	return flattenBookmarks(bookmarks, 1, nil), nil
+}
+
+// flattenBookmarks lists the bookmark tree as flattened outline entries.
+func flattenBookmarks(bookmarks []pdfcpu.Bookmark, level int, items []OutlineEntry) []OutlineEntry {
+	for _, bookmark := range bookmarks {
+		items = append(items, OutlineEntry{Level: level, Title: bookmark.Title, Page: bookmark.PageFrom - 1})
+		items = flattenBookmarks(bookmark.Kids, level+1, items)
+	}
+	return items
+}
+
+func (d *popplerDocument) Metadata() map[string]string {
+	return d.info.Metadata
+}
+
+func (d *popplerDocument) Close() error {
+	return nil
+}
+
+// PopplerInfo is what PopplerRenderer reads from pdfinfo's output.
+type PopplerInfo struct {
+	NumPage  int
+	Metadata map[string]string // keyed like fitz's metadata, e.g. "title"
+	Bounds   []image.Rectangle // page sizes in points, in page order
+}
+
+// popplerMetadataKeys maps pdfinfo's fields to fitz's metadata keys.
+var popplerMetadataKeys = map[string]string{
+	"Title":        "title",
+	"Author":       "author",
+	"Subject":      "subject",
+	"Keywords":     "keywords",
+	"Creator":      "creator",
+	"Producer":     "producer",
+	"CreationDate": "creationDate",
+	"ModDate":      "modDate",
+	"Encrypted":    "encryption",
+}
+
+var (
+	popplerPageSizePattern     = regexp.MustCompile(`(?m)^Page\s+(\d+) size:\s+([\d.]+) x ([\d.]+) pts`)
+	popplerPageRotationPattern = regexp.MustCompile(`(?m)^Page\s+(\d+) rot:\s+(\d+)`)
+)
+
+// ParsePopplerInfo reads the page count, metadata and page sizes from the output of
+// pdfinfo run with -f and -l. Pages rotated by 90 or 270 degrees have their width
+// and height swapped, as fitz reports them.
+func ParsePopplerInfo(output string) PopplerInfo {
+	info := PopplerInfo{Metadata: make(map[string]string)}
+	for _, line := range strings.Split(output, "\n") {
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case field == "Pages":
+			info.NumPage, _ = strconv.Atoi(value)
+		case field == "PDF version":
+			info.Metadata["format"] = "PDF " + value
+		case popplerMetadataKeys[field] != "":
+			info.Metadata[popplerMetadataKeys[field]] = value
+		}
+	}
+
+	rotations := make(map[int]int)
+	for _, match := range popplerPageRotationPattern.FindAllStringSubmatch(output, -1) {
+		page, _ := strconv.Atoi(match[1])
+		rotations[page], _ = strconv.Atoi(match[2])
+	}
+	for _, match := range popplerPageSizePattern.FindAllStringSubmatch(output, -1) {
+		page, _ := strconv.Atoi(match[1])
+		if page != len(info.Bounds)+1 {
+			break
+		}
+		width, _ := strconv.ParseFloat(match[2], 64)
+		height, _ := strconv.ParseFloat(match[3], 64)
+		if rotations[page]%180 == 90 {
+			width, height = height, width
+		}
+		info.Bounds = append(info.Bounds, image.Rect(0, 0, int(width), int(height)))
+	}
+	return info
+}
+
+var (
+	popplerLinePattern = regexp.MustCompile(`(?s)<line xMin="(-?[\d.]+)" yMin="(-?[\d.]+)" xMax="(-?[\d.]+)" yMax="(-?[\d.]+)">(.*?)</line>`)
+	popplerWordPattern = regexp.MustCompile(`(?s)<word[^>]*>(.*?)</word>`)
+)
+
+// ParsePopplerTextBlocks extracts positioned lines from the XHTML pdftotext writes
+// with -bbox-layout. Poppler has no font sizes, so the line height stands in.
+func ParsePopplerTextBlocks(markup string) []TextBlock {
+	var blocks []TextBlock
+	for _, line := range popplerLinePattern.FindAllStringSubmatch(markup, -1) {
+		var words []string
+		for _, word := range popplerWordPattern.FindAllStringSubmatch(line[5], -1) {
+			words = append(words, html.UnescapeString(word[1]))
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		xMin, _ := strconv.ParseFloat(line[1], 64)
+		yMin, _ := strconv.ParseFloat(line[2], 64)
+		xMax, _ := strconv.ParseFloat(line[3], 64)
+		yMax, _ := strconv.ParseFloat(line[4], 64)
+		blocks = append(blocks, TextBlock{
+			Text:     strings.Join(words, " "),
+			Rect:     models.Rect{X: xMin, Y: yMin, Width: xMax - xMin, Height: yMax - yMin},
+			FontSize: yMax - yMin,
+		})
+	}
+	return blocks
+}
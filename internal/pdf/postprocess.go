@@ -0,0 +1,173 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+const (
+	// markerEraseMargin pads erased marker boxes, in PDF points, to cover glyph overhang.
+	markerEraseMargin = 3.0
+	// borderTolerance is how far a pixel may drift from the border color and still be trimmed.
+	borderTolerance            = 16
+	defaultBackgroundTolerance = 24
+)
+
+// PostProcessingOptions cleans up the extracted card images. Every step is off by default.
+type PostProcessingOptions struct {
+	EraseMarkers        bool         // paint over the printed QUESTION/ANSWER labels
+	TrimWhitespace      bool         // crop uniform borders from the question and answer images
+	TrimPadding         int          // pixels of border kept around trimmed content
+	BackgroundColors    []color.RGBA // template ruling/grid colors replaced with the paper color
+	BackgroundTolerance int          // per-channel distance still matching a background color
+	// BeforeHash derives the card hash from the cleaned images instead of the raw page,
+	// so changing these options produces new cards rather than keeping the old hashes.
+	BeforeHash bool
+}
+
+func (o PostProcessingOptions) cleansPage() bool {
+	return o.EraseMarkers || len(o.BackgroundColors) > 0
+}
+
+// ParseHexColor parses colors written as #rrggbb or rrggbb.
+func ParseHexColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: expected #rrggbb", value)
+	}
+
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: %w", value, err)
+	}
+
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+func cloneImage(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
+
+// paperColor estimates the page's background as the most common color in a sparse sample.
+func paperColor(img *image.RGBA) color.RGBA {
+	const sampleStep = 8
+
+	counts := make(map[color.RGBA]int)
+	paper := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += sampleStep {
+		for x := bounds.Min.X; x < bounds.Max.X; x += sampleStep {
+			pixel := img.RGBAAt(x, y)
+			counts[pixel]++
+			if counts[pixel] > counts[paper] {
+				paper = pixel
+			}
+		}
+	}
+	return paper
+}
+
+// eraseRects paints the given page-space rectangles with the paper color and
+// returns the pixel areas it painted.
+func eraseRects(img *image.RGBA, rects []models.Rect, scale float64) []image.Rectangle {
+	var erased []image.Rectangle
+	fill := image.NewUniform(paperColor(img))
+	for _, rect := range rects {
+		padded := models.Rect{
+			X:      rect.X - markerEraseMargin,
+			Y:      rect.Y - markerEraseMargin,
+			Width:  rect.Width + 2*markerEraseMargin,
+			Height: rect.Height + 2*markerEraseMargin,
+		}
+		area := scaleRect(padded, img.Bounds().Min, scale).Intersect(img.Bounds())
+		draw.Draw(img, area, fill, image.Point{}, draw.Src)
+		erased = append(erased, area)
+	}
+	return erased
+}
+
+// removeBackgroundColors paints every pixel close to one of the template colors
+// with the paper color.
+func removeBackgroundColors(img *image.RGBA, colors []color.RGBA, tolerance int) {
+	if tolerance <= 0 {
+		tolerance = defaultBackgroundTolerance
+	}
+
+	paper := paperColor(img)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := img.RGBAAt(x, y)
+			for _, background := range colors {
+				if colorDistance(pixel, background) <= tolerance {
+					img.SetRGBA(x, y, paper)
+					break
+				}
+			}
+		}
+	}
+}
+
+// trimUniformBorder crops the border whose color matches the image's top-left
+// corner, keeping padding pixels around the remaining content.
+func trimUniformBorder(img *image.RGBA, padding int) *image.RGBA {
+	content := trimmedBounds(img, padding)
+	if content == img.Bounds() {
+		return img
+	}
+	return cropImage(img, content)
+}
+
+// trimmedBounds returns the region trimUniformBorder keeps, or the whole image
+// when there is nothing to trim.
+func trimmedBounds(img *image.RGBA, padding int) image.Rectangle {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return bounds
+	}
+
+	border := img.RGBAAt(bounds.Min.X, bounds.Min.Y)
+	content := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if colorDistance(img.RGBAAt(x, y), border) > borderTolerance {
+				content = content.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	if content.Empty() {
+		return bounds
+	}
+
+	return image.Rect(
+		content.Min.X-padding, content.Min.Y-padding,
+		content.Max.X+padding, content.Max.Y+padding,
+	).Intersect(bounds)
+}
+
+func colorDistance(a, b color.RGBA) int {
+	distance := absInt(int(a.R) - int(b.R))
+	if d := absInt(int(a.G) - int(b.G)); d > distance {
+		distance = d
+	}
+	if d := absInt(int(a.B) - int(b.B)); d > distance {
+		distance = d
+	}
+	return distance
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,71 @@
+package pdf_test
+
+import (
+	"image/color"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+const sampleTextHTML = `<div id="page0" style="width:455.0pt;height:587.5pt">
+<p style="top:275.9pt;left:8.0pt;line-height:12.0pt"><span style="font-family:Capriola,serif;font-size:12.0pt">QUESTION</span></p>
+<p style="top:60.9pt;left:45.9pt;line-height:51.2pt"><span style="font-family:.SFUI,serif;font-size:10.0pt">What &amp; why</span><sup><span style="font-family:.SFUI,serif;font-size:10.0pt"> ANSWER</span></sup></p>
+</div>`
+
+var _ = Describe("Post-processing", func() {
+	Context("text layout", func() {
+		It("should parse positioned lines from MuPDF HTML", func() {
+			blocks := pdf.ParseTextBlocks(sampleTextHTML)
+			Expect(blocks).To(HaveLen(2))
+
+			Expect(blocks[0].Text).To(Equal("QUESTION"))
+			Expect(blocks[0].Rect.X).To(Equal(8.0))
+			Expect(blocks[0].Rect.Y).To(Equal(275.9))
+			Expect(blocks[0].Rect.Height).To(Equal(12.0))
+			Expect(blocks[0].Rect.Width).To(BeNumerically("~", 8*12.0*0.7, 0.001))
+
+			Expect(blocks[1].Text).To(Equal("What & why ANSWER"))
+		})
+
+		It("should locate keywords within lines", func() {
+			blocks := pdf.ParseTextBlocks(sampleTextHTML)
+			rects := pdf.KeywordRects(blocks, "QUESTION", "ANSWER")
+			Expect(rects).To(HaveLen(2))
+
+			Expect(rects[0]).To(Equal(blocks[0].Rect))
+
+			charWidth := blocks[1].Rect.Width / 17
+			Expect(rects[1].X).To(BeNumerically("~", blocks[1].Rect.X+11*charWidth, 0.001))
+			Expect(rects[1].Width).To(BeNumerically("~", 6*charWidth, 0.001))
+		})
+
+		It("should collect the text of a region without markers", func() {
+			blocks := pdf.ParseTextBlocks(sampleTextHTML)
+
+			top := pdf.RegionText(blocks, models.Rect{Width: 455, Height: 200})
+			Expect(top).To(Equal("What & why"))
+
+			bottom := pdf.RegionText(blocks, models.Rect{Y: 200, Width: 455, Height: 387.5})
+			Expect(bottom).To(BeEmpty())
+		})
+	})
+
+	DescribeTable("ParseHexColor",
+		func(value string, expected color.RGBA, shouldSucceed bool) {
+			parsed, err := pdf.ParseHexColor(value)
+			if !shouldSucceed {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(expected))
+		},
+		Entry("with hash", "#c8d8f0", color.RGBA{R: 0xc8, G: 0xd8, B: 0xf0, A: 255}, true),
+		Entry("without hash", "000000", color.RGBA{A: 255}, true),
+		Entry("short form", "#fff", color.RGBA{}, false),
+		Entry("not hex", "#gggggg", color.RGBA{}, false),
+	)
+})
@@ -2,6 +2,7 @@ package pdf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/kpauljoseph/notesankify/pkg/logger"
 	"github.com/kpauljoseph/notesankify/pkg/utils"
@@ -11,33 +12,71 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/gen2brain/go-fitz"
+	"github.com/kpauljoseph/notesankify/internal/config"
 	"github.com/kpauljoseph/notesankify/pkg/models"
 )
 
+// PointsToPixels converts PDF points into pixels of pages rendered at RenderDPI.
+const PointsToPixels = RenderDPI / 72.0
+
 type ProcessingStats struct {
 	PDFPath        string
+	PageCount      int    // pages in the PDF
+	SelectedPages  string // ranges of the pages selected for processing, empty when all were
 	FlashcardCount int
 	ImagePairs     []ImagePair
 	PageNumbers    []int
+	UnpairedPages  []int          // pages left without a partner in two-page mode
+	PagePresets    map[int]string // name of the dimension preset each processed page matched
+	Decisions      []PageDecision // why each page did or did not become a card, in page order
+	Metadata       Metadata       // the document's title, subject and keywords
+
+	// RenderFailures are the crashes and timeouts of the render worker.
+	RenderFailures []RenderFailure
 }
 
 type ProcessorConfig struct {
 	TempDir    string
 	OutputDir  string
 	Dimensions models.PageDimensions
+	Presets    []models.PageDimensions // further page sizes accepted alongside Dimensions
+	Layout     models.CardLayout       // zero value means one card per page
 	ProcessingOptions
+	PostProcessing PostProcessingOptions
+	Scan           ScanOptions
+	// DebugOverlayDir, when set, receives an annotated PNG of every processed page.
+	DebugOverlayDir string
+	// OCR, when set, reads handwritten markers and card text from pages without a text layer.
+	OCR OCREngine
+	// Passwords are tried in order on encrypted PDFs.
+	Passwords []PasswordProvider
+	// Renderer opens the PDFs, FitzRenderer when nil.
+	Renderer Renderer
+	// Worker, when enabled, renders PDFs in a child process. It only applies to
+	// FitzRenderer, as other renderers already run outside this process.
+	Worker WorkerOptions
 	Logger *logger.Logger
 }
 
 type ProcessingOptions struct {
-	CheckDimensions bool // if true, only process pages matching dimensions
-	CheckMarkers    bool // if true, only process pages with QUESTION/ANSWER markers
+	CheckDimensions bool        // if true, only process pages matching dimensions
+	CheckMarkers    bool        // if true, only process pages with QUESTION/ANSWER markers
+	PagePairing     PairingMode // if set, build each card from a question page and an answer page
+	OutlineDecks    bool        // if true, record the bookmarks enclosing each card's page
+	OutlineDepth    int         // deepest bookmark level recorded, 0 for all levels
+	Pages           PageRules   // pages processed in each PDF, every page when no rule matches
+	// ExtraRegions are the hint and notes boxes cut from cards that carry their markers.
+	ExtraRegions []ExtraRegion
+	// Directives reads tags, deck, suspend and flag instructions written on the cards.
+	Directives DirectiveOptions
+	// BlankAnswers marks the cards whose answer is still empty.
+	BlankAnswers BlankAnswerOptions
 }
 
 type Processor struct {
 	config   ProcessorConfig
 	splitter *Splitter
+	worker   *renderWorker // nil when rendering in process
 }
 
 var _ PDFProcessor = (*Processor)(nil)
@@ -51,85 +90,180 @@ func NewProcessor(config ProcessorConfig) (*Processor, error) {
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	if config.DebugOverlayDir != "" {
+		if err := os.MkdirAll(config.DebugOverlayDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create debug overlay directory: %w", err)
+		}
+	}
+
 	splitter, err := NewSplitter(config.OutputDir, config.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create splitter: %w", err)
 	}
 
-	return &Processor{
+	if config.Renderer == nil {
+		config.Renderer = FitzRenderer{}
+	}
+
+	processor := &Processor{
 		config:   config,
 		splitter: splitter,
-	}, nil
+	}
+	if _, inProcess := config.Renderer.(FitzRenderer); config.Worker.Enabled && inProcess {
+		processor.worker = newRenderWorker(config.Worker)
+	}
+	return processor, nil
+}
+
+// WithConfig returns a processor for settings that vary between PDFs, such as the
+// page size or layout, changed by update. It shares this processor's directories,
+// splitter and render worker, so only this processor needs to be cleaned up.
+func (p *Processor) WithConfig(update func(config *ProcessorConfig)) *Processor {
+	processor := *p
+	update(&processor.config)
+	return &processor
+}
+
+// WithOverrides returns a processor for a PDF with its folders' .notesankify.yaml
+// settings applied, as WithConfig does.
+func (p *Processor) WithOverrides(overrides config.Overrides) *Processor {
+	return p.WithConfig(func(settings *ProcessorConfig) {
+		overrides.Apply(&settings.CheckMarkers, &settings.CheckDimensions, &settings.Dimensions, &settings.Layout)
+		if overrides.Processing.PagePairing != "" {
+			// The mode was checked when the override file was loaded.
+			if mode, err := ParsePairingMode(overrides.Processing.PagePairing); err == nil {
+				settings.PagePairing = mode
+			}
+		}
+	})
 }
 
 func (p *Processor) ProcessPDF(ctx context.Context, pdfPath string) (ProcessingStats, error) {
 	p.config.Logger.Info("Processing PDF: %s", pdfPath)
-	stats := ProcessingStats{PDFPath: pdfPath}
+	stats := ProcessingStats{PDFPath: pdfPath, PagePresets: make(map[int]string)}
+
+	if p.worker != nil && p.config.Worker.FileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Worker.FileTimeout)
+		defer cancel()
+	}
 
-	doc, err := fitz.New(pdfPath)
+	doc, err := p.openDocument(ctx, pdfPath)
 	if err != nil {
+		if IsWorkerFailure(err) {
+			stats.RenderFailures = append(stats.RenderFailures, RenderFailure{Reason: err.Error()})
+		}
 		return stats, fmt.Errorf("failed to open PDF: %w", err)
 	}
 	defer doc.Close()
 
 	baseName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
+	stats.Metadata = readMetadata(doc)
+
+	stats.PageCount = doc.NumPage()
+	selection := p.config.Pages.SelectionFor(pdfPath)
+	pages := selection.Pages(doc.NumPage())
+	if !selection.All() {
+		stats.SelectedPages = FormatPageRanges(pages)
+		p.config.Logger.Info("Selected pages %s of %d (%s)", stats.SelectedPages, stats.PageCount, selection)
+	}
 
+	if p.config.PagePairing != PairingNone {
+		err = p.processPagePairs(ctx, doc, pages, baseName, &stats)
+	} else {
+		err = p.processPages(ctx, doc, pages, baseName, &stats)
+	}
+
+	if p.config.OutlineDecks {
+		outline := p.loadOutline(doc)
+		for i := range stats.ImagePairs {
+			source := &stats.ImagePairs[i].Source
+			source.Outline = outline.PathForPage(source.PageNumber, p.config.OutlineDepth)
+		}
+	}
+
+	if workerDoc, ok := doc.(*workerDocument); ok {
+		stats.RenderFailures = append(stats.RenderFailures, workerDoc.failures...)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			stats.RenderFailures = append(stats.RenderFailures, RenderFailure{
+				Reason: fmt.Sprintf("processing stopped after the file time limit of %v", p.config.Worker.FileTimeout),
+			})
+		}
+	}
+
+	return stats, err
+}
+
+// processPages processes the selected pages, given by page number.
+func (p *Processor) processPages(ctx context.Context, doc Document, pages []int, baseName string, stats *ProcessingStats) error {
 	// Page numbers are zero indexed in the fitz package.
 	// pageIndex -> index, and pageNum -> actual page number in pdf file
-	for pageIndex := 0; pageIndex < doc.NumPage(); pageIndex++ {
+	for _, pageNum := range pages {
 		select {
 		case <-ctx.Done():
-			return stats, ctx.Err()
+			return ctx.Err()
 		default:
-			pageNum := pageIndex + 1 // Convert to one-based page number for user-facing content
-			if shouldProcessPage, err := p.shouldProcessPage(doc, pageIndex); err != nil {
-				p.config.Logger.Debug("Error checking page %d: %v", pageNum, err)
-				continue
-			} else if !shouldProcessPage {
-				continue
-			}
-
-			// Process the page as a flashcard
-			p.config.Logger.Debug("Processing page %d as flashcard", pageNum)
-			if err := p.processPage(doc, pageIndex, baseName, &stats); err != nil {
-				p.config.Logger.Debug("Error processing page %d: %v", pageNum, err)
-				continue
+			pageIndex := pageNum - 1
+			decision := PageDecision{PageNumber: pageNum}
+			p.processSinglePage(doc, pageIndex, baseName, stats, &decision)
+			if decision.Outcome != PageCard {
+				p.config.Logger.Debug("Page %d %s", pageNum, decision.Summary())
 			}
+			stats.Decisions = append(stats.Decisions, decision)
 		}
 	}
 
-	return stats, nil
+	return nil
 }
 
-func (p *Processor) shouldProcessPage(doc *fitz.Document, pageIndex int) (bool, error) {
-	pageNum := pageIndex + 1
-
-	// Check dimensions if required
-	if p.config.CheckDimensions {
-		bounds, err := doc.Bound(pageIndex)
+// processSinglePage runs the checks for one page and processes it when they pass.
+func (p *Processor) processSinglePage(doc Document, pageIndex int, baseName string, stats *ProcessingStats, decision *PageDecision) {
+	if p.config.Scan.Enabled {
+		scanned, err := p.processScannedPage(doc, pageIndex, baseName, stats, decision)
 		if err != nil {
-			return false, fmt.Errorf("failed to get bounds: %w", err)
+			decision.fail(err)
+			return
+		}
+		if scanned {
+			return
 		}
+	}
 
-		width := float64(bounds.Dx())
-		height := float64(bounds.Dy())
+	if shouldProcessPage, err := p.shouldProcessPage(doc, pageIndex, decision); err != nil {
+		decision.fail(err)
+		return
+	} else if !shouldProcessPage {
+		return
+	}
 
-		p.config.Logger.Debug("Page %d dimensions: %.2f x %.2f", pageNum, width, height)
-		if !p.MatchesDimensions(width, height) {
-			p.config.Logger.Debug("Page %d does not match required dimensions", pageNum)
-			return false, nil
-		}
+	// Process the page as a flashcard
+	p.config.Logger.Debug("Processing page %d as flashcard", decision.PageNumber)
+	recordCards(stats, decision, func() error {
+		return p.processPage(doc, pageIndex, baseName, stats)
+	})
+}
+
+// shouldProcessPage runs the dimension and marker checks, recording a skip reason
+// on the decision when the page fails one.
+func (p *Processor) shouldProcessPage(doc Document, pageIndex int, decision *PageDecision) (bool, error) {
+	if err := p.measurePage(doc, pageIndex, decision); err != nil {
+		return false, err
+	}
+
+	// Check dimensions if required
+	if p.config.CheckDimensions && decision.Preset == "" {
+		decision.skip(decision.dimensionMismatch())
+		return false, nil
 	}
 
 	// Check markers if required
 	if p.config.CheckMarkers {
-		text, err := doc.Text(pageIndex)
-		if err != nil {
-			return false, fmt.Errorf("failed to extract text: %w", err)
+		if err := p.checkMarkers(doc, pageIndex, decision); err != nil {
+			return false, err
 		}
 
-		if !ContainsFlashcardMarkers(text) {
-			p.config.Logger.Debug("Page %d does not contain required markers", pageNum)
+		if !decision.HasQuestion || !decision.HasAnswer {
+			decision.skip("QUESTION/ANSWER markers %s", decision.Markers())
 			return false, nil
 		}
 	}
@@ -137,67 +271,279 @@ func (p *Processor) shouldProcessPage(doc *fitz.Document, pageIndex int) (bool,
 	return true, nil
 }
 
-func (p *Processor) processPage(doc *fitz.Document, pageIndex int, baseName string, stats *ProcessingStats) error {
-	pageNum := pageIndex + 1
-
-	img, err := doc.Image(pageIndex)
+func (p *Processor) processPage(doc Document, pageIndex int, baseName string, stats *ProcessingStats) error {
+	img, err := doc.ImageDPI(pageIndex, RenderDPI)
 	if err != nil {
 		return fmt.Errorf("failed to extract image: %w", err)
 	}
 
-	// Generate content hash
-	fullHash, err := utils.GenerateImageHash(img)
+	layout := p.config.Layout
+	if preset, ok := p.presetForPage(doc, pageIndex); ok {
+		stats.PagePresets[pageIndex+1] = preset.DisplayName()
+		if preset.Layout != nil {
+			layout = *preset.Layout
+		}
+	}
+
+	return p.processPageImage(doc, pageIndex, img, layout, PointsToPixels, baseName, stats)
+}
+
+// processPageImage cuts a rendered page into cards. scale converts the layout's
+// point measurements into pixels of img.
+func (p *Processor) processPageImage(doc Document, pageIndex int, img *image.RGBA, layout models.CardLayout, scale float64, baseName string, stats *ProcessingStats) error {
+	pageNum := pageIndex + 1
+
+	var overlay *pageOverlay
+	if p.config.DebugOverlayDir != "" {
+		overlay = p.newOverlay(doc, pageIndex, img, baseName, stats.PagePresets[pageNum])
+		overlay.label("layout: %s (%d cells)", layout.Name, layout.CellCount())
+		defer p.saveOverlay(overlay, baseName, pageNum)
+	}
+
+	// cleaned is what ends up in the card images; img stays untouched for hashing.
+	cleaned := img
+	if p.config.PostProcessing.cleansPage() {
+		cleaned = p.cleanPage(doc, pageIndex, img, overlay)
+	}
+
+	landscape := img.Bounds().Dx() > img.Bounds().Dy()
+
+	// Typed text on the page makes the cards searchable in Anki.
+	blocks, err := doc.TextBlocks(pageIndex)
 	if err != nil {
-		return fmt.Errorf("failed to generate hash: %w", err)
+		p.config.Logger.Debug("Page %d: failed to extract text blocks: %v", pageNum, err)
 	}
 
-	// Save temporary image
-	tempImagePath := filepath.Join(p.config.TempDir, fmt.Sprintf("%s_%s.png", baseName, fullHash[:8]))
-	if err := saveImage(img, tempImagePath); err != nil {
-		return fmt.Errorf("failed to save temp image: %w", err)
+	cellCount := layout.CellCount()
+	if cellCount == 1 {
+		split := layout.SplitForCell(0).ForOrientation(landscape)
+		if overlay != nil {
+			p.annotateCard(overlay, cleaned, image.Point{}, split, scale)
+		}
+		card := img.Bounds().Sub(img.Bounds().Min)
+		markup := p.findCardMarkup(blocks, card, image.Point{}, split, scale)
+		if err := p.processCard(img, cleaned, pageNum, 0, split, scale, markup, baseName, stats); err != nil {
+			return err
+		}
+		setCardText(stats, markup.cardText(blocks), card, image.Point{}, split, scale)
+		p.markBlankAnswer(stats, cleaned, blocks, card, image.Point{}, split, scale, markup)
+		return nil
 	}
 
+	p.config.Logger.Debug("Page %d uses layout %q with %d cells", pageNum, layout.Name, cellCount)
+	var failed cellFailures
+	for cellIndex := 0; cellIndex < cellCount; cellIndex++ {
+		cellRect := CellRect(img.Bounds(), layout, cellIndex)
+		if overlay != nil {
+			overlay.outline(cellRect, overlayCellColor)
+		}
+		cellImg := cropImage(img, cellRect)
+		if isBlank(cellImg) {
+			p.config.Logger.Debug("Page %d cell %d is empty, skipping", pageNum, cellIndex)
+			continue
+		}
+
+		cleanedCell := cellImg
+		if cleaned != img {
+			cleanedCell = cropImage(cleaned, cellRect)
+		}
+
+		split := layout.SplitForCell(cellIndex).ForOrientation(landscape)
+		if overlay != nil {
+			p.annotateCard(overlay, cleanedCell, cellRect.Min.Sub(img.Bounds().Min), split, scale)
+		}
+		offset := cellRect.Min.Sub(img.Bounds().Min)
+		markup := p.findCardMarkup(blocks, cellImg.Bounds(), offset, split, scale)
+		if err := p.processCard(cellImg, cleanedCell, pageNum, cellIndex, split, scale, markup, baseName, stats); err != nil {
+			// One bad cell should not cost the cards in the cells after it.
+			p.config.Logger.Debug("Page %d cell %d failed: %v", pageNum, cellIndex, err)
+			failed = append(failed, CellFailure{CellIndex: cellIndex, Reason: err.Error()})
+			continue
+		}
+		setCardText(stats, markup.cardText(blocks), cellImg.Bounds(), offset, split, scale)
+		p.markBlankAnswer(stats, cleanedCell, blocks, cellImg.Bounds(), offset, split, scale, markup)
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// cleanPage applies the page-level post-processing steps to a copy of the page image.
+// Erased areas are shaded on the overlay when one is given.
+func (p *Processor) cleanPage(doc Document, pageIndex int, img *image.RGBA, overlay *pageOverlay) *image.RGBA {
+	cleaned := cloneImage(img)
+
+	if len(p.config.PostProcessing.BackgroundColors) > 0 {
+		removeBackgroundColors(cleaned, p.config.PostProcessing.BackgroundColors, p.config.PostProcessing.BackgroundTolerance)
+	}
+
+	if p.config.PostProcessing.EraseMarkers {
+		blocks, err := doc.TextBlocks(pageIndex)
+		if err != nil {
+			p.config.Logger.Debug("Page %d: failed to locate markers: %v", pageIndex+1, err)
+		} else {
+			erased := eraseRects(cleaned, KeywordRects(blocks, utils.QuestionKeyword, utils.AnswerKeyword), PointsToPixels)
+			if overlay != nil {
+				for _, area := range erased {
+					overlay.fill(area, overlayMaskColor)
+				}
+			}
+		}
+	}
+
+	return cleaned
+}
+
+// presetForPage returns the dimension preset matching the page's size, if any.
+func (p *Processor) presetForPage(doc Document, pageIndex int) (models.PageDimensions, bool) {
+	bounds, err := doc.Bound(pageIndex)
+	if err != nil {
+		p.config.Logger.Debug("Page %d: failed to get bounds for preset matching: %v", pageIndex+1, err)
+		return models.PageDimensions{}, false
+	}
+	return p.MatchPreset(float64(bounds.Dx()), float64(bounds.Dy()))
+}
+
+// processCard splits and hashes a single card, which is either a whole page or one
+// cell of a multi-card layout. raw is the untouched card used for hashing unless
+// post-processing is configured to run before hashing; img is the cleaned card.
+// markup's extra regions are cut out of the question and answer and saved on their own.
+func (p *Processor) processCard(raw, img *image.RGBA, pageNum, cellIndex int, split models.SplitSpec, scale float64, markup cardMarkup, baseName string, stats *ProcessingStats) error {
 	// Split into question and answer
-	pair, err := p.splitter.SplitImageWithHash(tempImagePath, baseName, fullHash)
+	questionImg, answerImg, err := p.splitter.SplitImage(markup.blank(img), split, scale)
 	if err != nil {
 		return fmt.Errorf("failed to split image: %w", err)
 	}
 
+	if p.config.PostProcessing.TrimWhitespace {
+		questionImg = trimUniformBorder(questionImg, p.config.PostProcessing.TrimPadding)
+		answerImg = trimUniformBorder(answerImg, p.config.PostProcessing.TrimPadding)
+	}
+
+	// Generate content hash
+	fullHash, err := p.cardHash(raw, questionImg, answerImg)
+	if err != nil {
+		return fmt.Errorf("failed to generate hash: %w", err)
+	}
+
+	pair, err := p.splitter.SaveImagePair(questionImg, answerImg, baseName, fullHash)
+	if err != nil {
+		return fmt.Errorf("failed to save card images: %w", err)
+	}
+	pair.Source = CardSource{PageNumber: pageNum, CellIndex: cellIndex}
+	pair.Directives = markup.directives
+	if p.config.OCR != nil {
+		var questionDirectives, answerDirectives Directives
+		pair.QuestionText, questionDirectives = p.recognizeCardText(questionImg)
+		pair.AnswerText, answerDirectives = p.recognizeCardText(answerImg)
+		// Directives from the text layer are exact, so they win over recognized ones.
+		pair.Directives = questionDirectives.merge(answerDirectives).merge(markup.directives)
+	}
+	// Masked directives are hidden from the extra regions too.
+	masked := cardMarkup{masks: markup.masks}.blank(img)
+	if pair.Extras, err = p.saveExtraImages(masked, markup.regions, baseName, fullHash); err != nil {
+		return err
+	}
+
 	stats.ImagePairs = append(stats.ImagePairs, *pair)
 	stats.PageNumbers = append(stats.PageNumbers, pageNum) // Store actual page number
 	stats.FlashcardCount++
 
-	p.config.Logger.Debug("Successfully processed page %d (Hash:%s)", pageNum, fullHash)
+	p.config.Logger.Debug("Successfully processed page %d cell %d (Hash:%s)", pageNum, cellIndex, fullHash)
 	return nil
 }
 
+// setCardText fills in the question and answer text of the card processCard just
+// added from the text lines inside its regions. card is the card's pixel bounds
+// anchored at the origin and offset its position on the page image.
+func setCardText(stats *ProcessingStats, blocks []TextBlock, card image.Rectangle, offset image.Point, split models.SplitSpec, scale float64) {
+	if len(blocks) == 0 || len(stats.ImagePairs) == 0 {
+		return
+	}
+
+	toPoints := func(region image.Rectangle) models.Rect {
+		region = region.Add(offset)
+		return models.Rect{
+			X:      float64(region.Min.X) / scale,
+			Y:      float64(region.Min.Y) / scale,
+			Width:  float64(region.Dx()) / scale,
+			Height: float64(region.Dy()) / scale,
+		}
+	}
+
+	// Text from the text layer is exact, so it replaces any OCR result.
+	question, answer := SplitRects(card, split.ForOrientation(card.Dx() > card.Dy()), scale)
+	pair := &stats.ImagePairs[len(stats.ImagePairs)-1]
+	if text := RegionText(blocks, toPoints(question)); text != "" {
+		pair.QuestionText = text
+	}
+	if text := RegionText(blocks, toPoints(answer)); text != "" {
+		pair.AnswerText = text
+	}
+}
+
+func (p *Processor) cardHash(raw, questionImg, answerImg *image.RGBA) (string, error) {
+	if !p.config.PostProcessing.BeforeHash {
+		return utils.GenerateImageHash(raw)
+	}
+	return combinedHash(questionImg, answerImg)
+}
+
+// pairHash is cardHash for a card built from two pages, whose raw images are
+// hashed separately.
+func (p *Processor) pairHash(rawQuestion, rawAnswer, questionImg, answerImg *image.RGBA) (string, error) {
+	if !p.config.PostProcessing.BeforeHash {
+		return combinedHash(rawQuestion, rawAnswer)
+	}
+	return combinedHash(questionImg, answerImg)
+}
+
+func combinedHash(questionImg, answerImg *image.RGBA) (string, error) {
+	questionHash, err := utils.GenerateImageHash(questionImg)
+	if err != nil {
+		return "", err
+	}
+	answerHash, err := utils.GenerateImageHash(answerImg)
+	if err != nil {
+		return "", err
+	}
+	return utils.CombineHashes(questionHash, answerHash), nil
+}
+
 func (p *Processor) MatchesDimensions(width, height float64) bool {
-	targetWidth := p.config.Dimensions.Width
-	targetHeight := p.config.Dimensions.Height
+	_, ok := p.MatchPreset(width, height)
+	return ok
+}
+
+// presets returns every page size accepted in this run, Dimensions first.
+func (p *Processor) presets() []models.PageDimensions {
+	var presets []models.PageDimensions
+	if p.config.Dimensions.Width > 0 && p.config.Dimensions.Height > 0 {
+		presets = append(presets, p.config.Dimensions)
+	}
+	return append(presets, p.config.Presets...)
+}
 
+// MatchPreset returns the first accepted preset matching the size in either orientation.
+func (p *Processor) MatchPreset(width, height float64) (models.PageDimensions, bool) {
 	p.config.Logger.Debug("Comparing dimensions:")
 	p.config.Logger.Debug("  Current: %.2f x %.2f", width, height)
-	p.config.Logger.Debug("  Target:  %.2f x %.2f", targetWidth, targetHeight)
-	p.config.Logger.Debug("  Tolerance: %.1f", utils.DIMENSION_TOLERANCE)
 
-	// Check both normal and rotated orientations
-	return (abs(width-targetWidth) <= utils.DIMENSION_TOLERANCE &&
-		abs(height-targetHeight) <= utils.DIMENSION_TOLERANCE) ||
-		(abs(width-targetHeight) <= utils.DIMENSION_TOLERANCE &&
-			abs(height-targetWidth) <= utils.DIMENSION_TOLERANCE)
+	for _, preset := range p.presets() {
+		p.config.Logger.Debug("  Target:  %.2f x %.2f (%s)", preset.Width, preset.Height, preset.DisplayName())
+		if preset.Matches(width, height) {
+			return preset, true
+		}
+	}
+	return models.PageDimensions{}, false
 }
 
 func ContainsFlashcardMarkers(text string) bool {
 	return strings.Contains(text, utils.QuestionKeyword) && strings.Contains(text, utils.AnswerKeyword)
 }
 
-func abs(x float64) float64 {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 func saveImage(img *image.RGBA, path string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -217,5 +563,8 @@ func (p *Processor) ShouldCheckDimensions() bool {
 }
 
 func (p *Processor) Cleanup() error {
+	if p.worker != nil {
+		p.worker.stop()
+	}
 	return os.RemoveAll(p.config.TempDir)
 }
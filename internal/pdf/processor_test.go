@@ -116,6 +116,48 @@ var _ = Describe("PDF Processor", func() {
 		)
 	})
 
+	Context("Dimension presets", func() {
+		var presetProcessor *pdf.Processor
+
+		BeforeEach(func() {
+			indexCard := models.IndexCard3x5Preset
+			indexCard.Tolerance = 10
+
+			var err error
+			presetProcessor, err = pdf.NewProcessor(pdf.ProcessorConfig{
+				TempDir:    tempDir,
+				OutputDir:  outputDir,
+				Dimensions: models.GoodNotesStandardPreset,
+				Presets:    []models.PageDimensions{indexCard, models.A5Preset},
+				Logger:     testLogger,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		DescribeTable("MatchPreset",
+			func(width, height float64, expected string) {
+				preset, ok := presetProcessor.MatchPreset(width, height)
+				if expected == "" {
+					Expect(ok).To(BeFalse())
+					return
+				}
+				Expect(ok).To(BeTrue())
+				Expect(preset.Name).To(Equal(expected))
+			},
+			Entry("primary dimensions", 455.04, 588.45, "goodnotes-standard"),
+			Entry("per-preset tolerance", 368.0, 210.0, "index-3x5"),
+			Entry("rotated additional preset", 595.28, 419.53, "a5"),
+			Entry("outside the default tolerance", 423.0, 595.28, ""),
+			Entry("unlisted preset", 612.0, 792.0, ""),
+		)
+
+		It("should match scanned cards by aspect ratio", func() {
+			preset, ok := presetProcessor.MatchAspectRatio(1500, 900)
+			Expect(ok).To(BeTrue())
+			Expect(preset.Name).To(Equal("index-3x5"))
+		})
+	})
+
 	Context("Flashcard marker detection", func() {
 		DescribeTable("containsFlashcardMarkers",
 			func(text string, shouldMatch bool) {
@@ -214,4 +256,22 @@ var _ = Describe("PDF Processor", func() {
 			Entry("check neither", false, false),
 		)
 	})
+
+	Context("Page pairing modes", func() {
+		DescribeTable("ParsePairingMode",
+			func(name string, expected pdf.PairingMode, shouldSucceed bool) {
+				mode, err := pdf.ParsePairingMode(name)
+				if !shouldSucceed {
+					Expect(err).To(HaveOccurred())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mode).To(Equal(expected))
+			},
+			Entry("empty disables pairing", "", pdf.PairingNone, true),
+			Entry("consecutive pages", "consecutive", pdf.PairingConsecutive, true),
+			Entry("marker pages", "Markers", pdf.PairingMarkers, true),
+			Entry("unknown mode", "odd-even", pdf.PairingNone, false),
+		)
+	})
 })
@@ -0,0 +1,324 @@
+package pdf_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+// syntheticPage is a page of a syntheticRenderer document: lines of text drawn as
+// dark bars on white paper.
+type syntheticPage struct {
+	width, height int
+	lines         []pdf.TextBlock
+}
+
+// syntheticRenderer serves the same in-memory document for every path.
+type syntheticRenderer struct {
+	pages    []syntheticPage
+	metadata map[string]string
+}
+
+func (r syntheticRenderer) Open(string, []pdf.PasswordProvider) (pdf.Document, error) {
+	return &syntheticDocument{renderer: r}, nil
+}
+
+type syntheticDocument struct {
+	renderer syntheticRenderer
+}
+
+func (d *syntheticDocument) NumPage() int {
+	return len(d.renderer.pages)
+}
+
+func (d *syntheticDocument) Bound(pageNumber int) (image.Rectangle, error) {
+	page := d.renderer.pages[pageNumber]
+	return image.Rect(0, 0, page.width, page.height), nil
+}
+
+func (d *syntheticDocument) Text(pageNumber int) (string, error) {
+	var lines []string
+	for _, line := range d.renderer.pages[pageNumber].lines {
+		lines = append(lines, line.Text)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (d *syntheticDocument) TextBlocks(pageNumber int) ([]pdf.TextBlock, error) {
+	return d.renderer.pages[pageNumber].lines, nil
+}
+
+func (d *syntheticDocument) ImageDPI(pageNumber int, dpi float64) (*image.RGBA, error) {
+	page := d.renderer.pages[pageNumber]
+	scale := dpi / 72
+	img := image.NewRGBA(image.Rect(0, 0, int(float64(page.width)*scale), int(float64(page.height)*scale)))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, line := range page.lines {
+		bar := image.Rect(int(line.Rect.X*scale), int(line.Rect.Y*scale),
+			int((line.Rect.X+line.Rect.Width)*scale), int((line.Rect.Y+line.Rect.Height)*scale))
+		draw.Draw(img, bar, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	return img, nil
+}
+
+func (d *syntheticDocument) ToC() ([]pdf.OutlineEntry, error) {
+	return nil, nil
+}
+
+func (d *syntheticDocument) Metadata() map[string]string {
+	return d.renderer.metadata
+}
+
+func (d *syntheticDocument) Close() error {
+	return nil
+}
+
+var _ = Describe("Renderer", func() {
+	Context("with a synthetic renderer", func() {
+		var tempDir, outputDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = os.MkdirTemp("", "notesankify-test-*")
+			Expect(err).NotTo(HaveOccurred())
+			outputDir, err = os.MkdirTemp("", "notesankify-output-*")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(os.RemoveAll, tempDir)
+			DeferCleanup(os.RemoveAll, outputDir)
+		})
+
+		It("should process the pages it serves", func() {
+			width, height := 455, 588 // the standard flashcard size, truncated like fitz page bounds
+			renderer := syntheticRenderer{
+				pages: []syntheticPage{
+					{width: width, height: height, lines: []pdf.TextBlock{
+						{Text: "QUESTION", Rect: models.Rect{X: 40, Y: 40, Width: 80, Height: 14}},
+						{Text: "What is ATP?", Rect: models.Rect{X: 40, Y: 80, Width: 120, Height: 14}},
+						{Text: "ANSWER", Rect: models.Rect{X: 40, Y: 340, Width: 70, Height: 14}},
+						{Text: "Energy currency", Rect: models.Rect{X: 40, Y: 380, Width: 150, Height: 14}},
+					}},
+					{width: width, height: height, lines: []pdf.TextBlock{
+						{Text: "Just notes", Rect: models.Rect{X: 40, Y: 40, Width: 100, Height: 14}},
+					}},
+					{width: 612, height: 792, lines: []pdf.TextBlock{
+						{Text: "QUESTION ANSWER", Rect: models.Rect{X: 40, Y: 40, Width: 160, Height: 14}},
+					}},
+				},
+				metadata: map[string]string{"title": "Biology", "keywords": "cells, exam"},
+			}
+
+			processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+				},
+				Renderer: renderer,
+				Logger:   processorTestLogger(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.FlashcardCount).To(Equal(1))
+			Expect(stats.PageNumbers).To(Equal([]int{1}))
+			Expect(stats.ImagePairs[0].QuestionText).To(Equal("What is ATP?"))
+			Expect(stats.ImagePairs[0].AnswerText).To(Equal("Energy currency"))
+			Expect(stats.Metadata.Title).To(Equal("Biology"))
+			Expect(stats.Metadata.Keywords).To(Equal([]string{"cells", "exam"}))
+
+			Expect(stats.Decisions).To(HaveLen(3))
+			Expect(stats.Decisions[1].Reason).To(ContainSubstring("markers"))
+			Expect(stats.Decisions[2].Preset).To(BeEmpty())
+		})
+
+		It("should keep the other cells of a page when one cell fails", func() {
+			// Two cards side by side; the first cell's regions lie outside the card.
+			renderer := syntheticRenderer{
+				pages: []syntheticPage{
+					{width: 600, height: 400, lines: []pdf.TextBlock{
+						{Text: "Left card", Rect: models.Rect{X: 40, Y: 40, Width: 100, Height: 14}},
+						{Text: "Right card", Rect: models.Rect{X: 340, Y: 40, Width: 100, Height: 14}},
+					}},
+				},
+			}
+			offCard := models.Rect{X: 1000, Y: 1000, Width: 10, Height: 10}
+
+			processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Layout: models.CardLayout{
+					Rows:    1,
+					Columns: 2,
+					Cells: []models.SplitSpec{
+						{Regions: map[string]models.Rect{models.QuestionRegion: offCard, models.AnswerRegion: offCard}},
+						{},
+					},
+				},
+				Renderer: renderer,
+				Logger:   processorTestLogger(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.ImagePairs).To(HaveLen(1))
+			Expect(stats.ImagePairs[0].Source.CellIndex).To(Equal(1))
+
+			Expect(stats.Decisions).To(HaveLen(1))
+			decision := stats.Decisions[0]
+			Expect(decision.Outcome).To(Equal(pdf.PageCard))
+			Expect(decision.FailedCells).To(HaveLen(1))
+			Expect(decision.FailedCells[0].CellIndex).To(Equal(0))
+			Expect(decision.Summary()).To(ContainSubstring("cell 0: "))
+		})
+
+		It("should cut marked hint and notes boxes out of the card", func() {
+			width, height := 455, 588
+			renderer := syntheticRenderer{
+				pages: []syntheticPage{
+					{width: width, height: height, lines: []pdf.TextBlock{
+						{Text: "QUESTION", Rect: models.Rect{X: 40, Y: 40, Width: 80, Height: 14}},
+						{Text: "What is ATP?", Rect: models.Rect{X: 40, Y: 80, Width: 120, Height: 14}},
+						{Text: "HINT", Rect: models.Rect{X: 40, Y: 200, Width: 40, Height: 14}},
+						{Text: "Think of money", Rect: models.Rect{X: 40, Y: 220, Width: 140, Height: 14}},
+						{Text: "ANSWER", Rect: models.Rect{X: 40, Y: 340, Width: 70, Height: 14}},
+						{Text: "Energy currency", Rect: models.Rect{X: 40, Y: 380, Width: 150, Height: 14}},
+						{Text: "NOTES: made in mitochondria", Rect: models.Rect{X: 40, Y: 500, Width: 250, Height: 14}},
+					}},
+				},
+			}
+
+			process := func(regions ...pdf.ExtraRegion) pdf.ImagePair {
+				processor, err := pdf.NewProcessor(pdf.ProcessorConfig{
+					TempDir:   tempDir,
+					OutputDir: outputDir,
+					Dimensions: models.PageDimensions{
+						Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+						Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+					},
+					ProcessingOptions: pdf.ProcessingOptions{
+						CheckDimensions: true,
+						CheckMarkers:    true,
+						ExtraRegions:    regions,
+					},
+					Renderer: renderer,
+					Logger:   processorTestLogger(),
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				stats, err := processor.ProcessPDF(context.Background(), "synthetic.pdf")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(stats.ImagePairs).To(HaveLen(1))
+				return stats.ImagePairs[0]
+			}
+
+			plain := process()
+			Expect(plain.Extras).To(BeEmpty())
+			Expect(plain.QuestionText).To(ContainSubstring("Think of money"))
+			plainQuestionInk := pdf.InkCoverage(readImage(plain.Question))
+			plainAnswerInk := pdf.InkCoverage(readImage(plain.Answer))
+
+			pair := process(
+				pdf.ExtraRegion{Name: models.HintRegion, Marker: "HINT"},
+				pdf.ExtraRegion{Name: models.ExtraRegion, Marker: "NOTES"},
+				pdf.ExtraRegion{Name: "missing", Marker: "SOURCES"},
+			)
+			Expect(pair.Hash).To(Equal(plain.Hash))
+			Expect(pair.QuestionText).To(Equal("What is ATP?"))
+			Expect(pair.AnswerText).To(Equal("Energy currency"))
+			Expect(pair.Extras).To(HaveLen(2))
+			Expect(pair.Extras[0].Name).To(Equal(models.HintRegion))
+			Expect(pair.Extras[0].Text).To(Equal("Think of money"))
+			Expect(pair.Extras[1].Name).To(Equal(models.ExtraRegion))
+			Expect(pair.Extras[1].Text).To(Equal("made in mitochondria"))
+			for _, extra := range pair.Extras {
+				Expect(extra.Path).To(BeAnExistingFile())
+			}
+
+			Expect(pdf.InkCoverage(readImage(pair.Question))).To(BeNumerically("<", plainQuestionInk))
+			Expect(pdf.InkCoverage(readImage(pair.Answer))).To(BeNumerically("<", plainAnswerInk))
+		})
+	})
+
+	Context("poppler output", func() {
+		It("should read the page count, metadata and page sizes from pdfinfo", func() {
+			info := pdf.ParsePopplerInfo(strings.Join([]string{
+				"Title:           Biology: Cells",
+				"Author:          Jane",
+				"Keywords:        cells, exam",
+				"Producer:        GoodNotes",
+				"Encrypted:       no",
+				"Pages:           2",
+				"Page    1 size:  455.04 x 588.45 pts",
+				"Page    1 rot:   0",
+				"Page    2 size:  612 x 792 pts (letter)",
+				"Page    2 rot:   90",
+				"PDF version:     1.7",
+			}, "\n"))
+
+			Expect(info.NumPage).To(Equal(2))
+			Expect(info.Metadata).To(HaveKeyWithValue("title", "Biology: Cells"))
+			Expect(info.Metadata).To(HaveKeyWithValue("keywords", "cells, exam"))
+			Expect(info.Metadata).To(HaveKeyWithValue("format", "PDF 1.7"))
+			Expect(info.Bounds).To(Equal([]image.Rectangle{
+				image.Rect(0, 0, 455, 588),
+				image.Rect(0, 0, 792, 612),
+			}))
+		})
+
+		It("should read positioned lines from pdftotext's bounding box layout", func() {
+			blocks := pdf.ParsePopplerTextBlocks(`<doc>
+  <page width="455.040000" height="588.450000">
+    <flow>
+      <block xMin="40.000000" yMin="40.000000" xMax="180.500000" yMax="54.000000">
+        <line xMin="40.000000" yMin="40.000000" xMax="180.500000" yMax="54.000000">
+          <word xMin="40.000000" yMin="40.000000" xMax="110.000000" yMax="54.000000">QUESTION</word>
+          <word xMin="114.000000" yMin="40.000000" xMax="180.500000" yMax="54.000000">A&amp;B</word>
+        </line>
+        <line xMin="40.000000" yMin="60.000000" xMax="50.000000" yMax="72.000000">
+        </line>
+      </block>
+    </flow>
+  </page>
+</doc>`)
+
+			Expect(blocks).To(Equal([]pdf.TextBlock{{
+				Text:     "QUESTION A&B",
+				Rect:     models.Rect{X: 40, Y: 40, Width: 140.5, Height: 14},
+				FontSize: 14,
+			}}))
+		})
+
+		It("should refuse encrypted PDFs instead of decrypting them to disk", func() {
+			if runtime.GOOS == "windows" {
+				Skip("the fake pdfinfo is a shell script")
+			}
+			dir := GinkgoT().TempDir()
+			script := "#!/bin/sh\necho 'Command Line Error: Incorrect password' >&2\nexit 1\n"
+			Expect(os.WriteFile(filepath.Join(dir, "pdfinfo"), []byte(script), 0755)).To(Succeed())
+
+			renderer := &pdf.PopplerRenderer{Dir: dir}
+			_, err := renderer.Open("locked.pdf", []pdf.PasswordProvider{pdf.PasswordRules{{Pattern: "*.pdf", Password: "secret"}}})
+			Expect(err).To(MatchError(pdf.ErrEncryptionUnsupported))
+			Expect(pdf.IsEncryptedError(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,439 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+const (
+	defaultMaxSkewDegrees = 10.0
+	skewStepDegrees       = 0.25
+	// analysisScale downsamples scans before skew and boundary detection.
+	analysisScale = 4
+	// scanAspectTolerance is the relative aspect-ratio error accepted for scanned cards.
+	scanAspectTolerance = 0.05
+	// minCardArea is the smallest fraction of the sheet a detected card may cover.
+	minCardArea = 0.2
+	// thresholdSensitivity darkens the local mean to decide what counts as ink.
+	thresholdSensitivity = 0.15
+)
+
+// ScanOptions configures cleanup of image-only pages, such as phone scans of paper
+// cards. Pages with a text layer are never treated as scans.
+type ScanOptions struct {
+	Enabled           bool
+	Deskew            bool
+	NormalizeContrast bool
+	Threshold         bool    // adaptive thresholding to clean black-on-white
+	DetectCard        bool    // crop to the card found on the scanned sheet
+	MaxSkewDegrees    float64 // largest rotation searched when deskewing
+}
+
+// AllScanSteps enables every scan cleanup step.
+func AllScanSteps() ScanOptions {
+	return ScanOptions{
+		Enabled:           true,
+		Deskew:            true,
+		NormalizeContrast: true,
+		Threshold:         true,
+		DetectCard:        true,
+	}
+}
+
+// CleanScannedPage runs the enabled cleanup steps and returns the card image.
+func CleanScannedPage(img *image.RGBA, options ScanOptions) *image.RGBA {
+	if options.Deskew {
+		maxSkew := options.MaxSkewDegrees
+		if maxSkew <= 0 {
+			maxSkew = defaultMaxSkewDegrees
+		}
+		if angle := EstimateSkew(img, maxSkew); angle != 0 {
+			img = rotateImage(img, -angle)
+		}
+	}
+
+	if options.DetectCard {
+		img = cropImage(img, DetectCardBounds(img))
+	}
+
+	if options.NormalizeContrast {
+		normalizeContrast(img)
+	}
+
+	if options.Threshold {
+		img = adaptiveThreshold(img)
+	}
+
+	return img
+}
+
+// EstimateSkew returns the rotation in degrees, clockwise, that best aligns ink
+// into horizontal rows. Lines of handwriting or ruling give sharp row profiles.
+func EstimateSkew(img *image.RGBA, maxDegrees float64) float64 {
+	small := downsampleGray(img, analysisScale)
+	level := otsuLevel(small)
+	bounds := small.Bounds()
+
+	var inkX, inkY []float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if small.GrayAt(x, y).Y < level {
+				inkX = append(inkX, float64(x))
+				inkY = append(inkY, float64(y))
+			}
+		}
+	}
+	if len(inkX) == 0 {
+		return 0
+	}
+
+	diagonal := int(math.Hypot(float64(bounds.Dx()), float64(bounds.Dy()))) + 1
+	bestAngle, bestScore := 0.0, -1.0
+	for angle := -maxDegrees; angle <= maxDegrees+1e-9; angle += skewStepDegrees {
+		radians := angle * math.Pi / 180
+		sin, cos := math.Sin(radians), math.Cos(radians)
+
+		rows := make([]float64, 2*diagonal+1)
+		for i := range inkX {
+			row := int(-inkX[i]*sin+inkY[i]*cos) + diagonal
+			if row >= 0 && row < len(rows) {
+				rows[row]++
+			}
+		}
+
+		var score float64
+		for _, count := range rows {
+			score += count * count
+		}
+		if score > bestScore || (score == bestScore && math.Abs(angle) < math.Abs(bestAngle)) {
+			bestAngle, bestScore = angle, score
+		}
+	}
+
+	return bestAngle
+}
+
+// DetectCardBounds finds the bright card on a darker scanned background. It returns
+// the whole image when no plausible card stands out.
+func DetectCardBounds(img *image.RGBA) image.Rectangle {
+	small := downsampleGray(img, analysisScale)
+	level := otsuLevel(small)
+	bounds := small.Bounds()
+
+	brightFraction := func(count, total int) bool {
+		return total > 0 && float64(count)/float64(total) > 0.5
+	}
+
+	rowBright := make([]bool, bounds.Dy())
+	columnCounts := make([]int, bounds.Dx())
+	for y := 0; y < bounds.Dy(); y++ {
+		var count int
+		for x := 0; x < bounds.Dx(); x++ {
+			if small.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y >= level {
+				count++
+				columnCounts[x]++
+			}
+		}
+		rowBright[y] = brightFraction(count, bounds.Dx())
+	}
+
+	top, bottom := firstAndLast(rowBright)
+	columnBright := make([]bool, bounds.Dx())
+	for x, count := range columnCounts {
+		columnBright[x] = brightFraction(count, bounds.Dy())
+	}
+	left, right := firstAndLast(columnBright)
+
+	if top < 0 || left < 0 {
+		return img.Bounds()
+	}
+
+	card := image.Rect(left*analysisScale, top*analysisScale, (right+1)*analysisScale, (bottom+1)*analysisScale).
+		Add(img.Bounds().Min).
+		Intersect(img.Bounds())
+
+	area := float64(card.Dx()*card.Dy()) / float64(img.Bounds().Dx()*img.Bounds().Dy())
+	if area < minCardArea {
+		return img.Bounds()
+	}
+	return card
+}
+
+// processScannedPage handles pages without a text layer. It reports false for pages
+// that carry text so they go through the regular checks instead. Marker checks do
+// not apply to scans, and the dimension check compares the detected card's aspect
+// ratio because the page box of a photo says nothing about the card.
+func (p *Processor) processScannedPage(doc Document, pageIndex int, baseName string, stats *ProcessingStats, decision *PageDecision) (bool, error) {
+	pageNum := pageIndex + 1
+
+	text, err := doc.Text(pageIndex)
+	if err != nil {
+		return false, fmt.Errorf("failed to extract text: %w", err)
+	}
+	if strings.TrimSpace(text) != "" {
+		return false, nil
+	}
+	decision.Scanned = true
+
+	if err := p.measurePage(doc, pageIndex, decision); err != nil {
+		return true, err
+	}
+
+	img, err := doc.ImageDPI(pageIndex, RenderDPI)
+	if err != nil {
+		return true, fmt.Errorf("failed to extract image: %w", err)
+	}
+
+	card := CleanScannedPage(img, p.config.Scan)
+	width, height := float64(card.Bounds().Dx()), float64(card.Bounds().Dy())
+	p.config.Logger.Debug("Page %d is a scan, card size %.0f x %.0f px", pageNum, width, height)
+
+	preset, matches := p.MatchAspectRatio(width, height)
+	decision.Preset = ""
+	if matches {
+		decision.Preset = preset.DisplayName()
+	}
+	if p.config.CheckDimensions && !matches {
+		decision.skip("detected card aspect ratio %.3f matched none of %s", width/height, strings.Join(decision.PresetsTried, ", "))
+		return true, nil
+	}
+
+	layout := p.config.Layout
+	scale := PointsToPixels
+	if matches {
+		stats.PagePresets[pageNum] = preset.DisplayName()
+		if preset.Layout != nil {
+			layout = *preset.Layout
+		}
+		// Map the preset onto the card image so margins and regions written in
+		// points still land in the right place.
+		scale = math.Max(width, height) / math.Max(preset.Width, preset.Height)
+	}
+
+	p.config.Logger.Debug("Processing scanned page %d as flashcard", pageNum)
+	recordCards(stats, decision, func() error {
+		return p.processPageImage(doc, pageIndex, card, layout, scale, baseName, stats)
+	})
+	return true, nil
+}
+
+// MatchAspectRatio returns the first accepted preset whose proportions match a
+// detected card's, in either orientation. Scans have no meaningful point size.
+func (p *Processor) MatchAspectRatio(width, height float64) (models.PageDimensions, bool) {
+	if width <= 0 || height <= 0 {
+		return models.PageDimensions{}, false
+	}
+
+	ratio := width / height
+	for _, preset := range p.presets() {
+		target := preset.Width / preset.Height
+		p.config.Logger.Debug("Comparing aspect ratios: current %.3f, target %.3f (%s)", ratio, target, preset.DisplayName())
+
+		if math.Abs(ratio-target)/target <= scanAspectTolerance ||
+			math.Abs(ratio-1/target)*target <= scanAspectTolerance {
+			return preset, true
+		}
+	}
+	return models.PageDimensions{}, false
+}
+
+func firstAndLast(flags []bool) (int, int) {
+	first, last := -1, -1
+	for i, flag := range flags {
+		if flag {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last
+}
+
+func downsampleGray(img *image.RGBA, factor int) *image.Gray {
+	bounds := img.Bounds()
+	small := image.NewGray(image.Rect(0, 0, bounds.Dx()/factor, bounds.Dy()/factor))
+	for y := 0; y < small.Bounds().Dy(); y++ {
+		for x := 0; x < small.Bounds().Dx(); x++ {
+			var sum int
+			for dy := 0; dy < factor; dy++ {
+				for dx := 0; dx < factor; dx++ {
+					sum += int(luminance(img.RGBAAt(bounds.Min.X+x*factor+dx, bounds.Min.Y+y*factor+dy)))
+				}
+			}
+			small.SetGray(x, y, color.Gray{Y: uint8(sum / (factor * factor))})
+		}
+	}
+	return small
+}
+
+func luminance(c color.RGBA) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
+
+// otsuLevel picks the grey level that best separates the histogram into two classes.
+func otsuLevel(img *image.Gray) uint8 {
+	var histogram [256]int
+	for _, value := range img.Pix {
+		histogram[value]++
+	}
+
+	total := len(img.Pix)
+	var sumAll float64
+	for level, count := range histogram {
+		sumAll += float64(level * count)
+	}
+
+	var sumBackground float64
+	var weightBackground int
+	bestLevel, bestVariance := 0, -1.0
+	for level, count := range histogram {
+		weightBackground += count
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+
+		sumBackground += float64(level * count)
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sumAll - sumBackground) / float64(weightForeground)
+		variance := float64(weightBackground) * float64(weightForeground) *
+			(meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if variance > bestVariance {
+			bestLevel, bestVariance = level, variance
+		}
+	}
+
+	return uint8(bestLevel + 1)
+}
+
+// rotateImage rotates img clockwise by degrees around its centre, filling exposed
+// corners with the color along the image's edge so they blend into the background.
+func rotateImage(img *image.RGBA, degrees float64) *image.RGBA {
+	bounds := img.Bounds()
+	rotated := image.NewRGBA(bounds)
+	fill := edgeColor(img)
+
+	radians := degrees * math.Pi / 180
+	sin, cos := math.Sin(radians), math.Cos(radians)
+	centerX := float64(bounds.Min.X) + float64(bounds.Dx())/2
+	centerY := float64(bounds.Min.Y) + float64(bounds.Dy())/2
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Map each destination pixel back into the source image.
+			dx, dy := float64(x)-centerX, float64(y)-centerY
+			sourceX := int(math.Round(dx*cos + dy*sin + centerX))
+			sourceY := int(math.Round(-dx*sin + dy*cos + centerY))
+			if image.Pt(sourceX, sourceY).In(bounds) {
+				rotated.SetRGBA(x, y, img.RGBAAt(sourceX, sourceY))
+			} else {
+				rotated.SetRGBA(x, y, fill)
+			}
+		}
+	}
+	return rotated
+}
+
+// edgeColor returns the most common color along the image's border. On a photo of a
+// card this is the surface it lies on.
+func edgeColor(img *image.RGBA) color.RGBA {
+	bounds := img.Bounds()
+	counts := make(map[color.RGBA]int)
+	var edge color.RGBA
+	count := func(x, y int) {
+		pixel := img.RGBAAt(x, y)
+		counts[pixel]++
+		if counts[pixel] > counts[edge] {
+			edge = pixel
+		}
+	}
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		count(x, bounds.Min.Y)
+		count(x, bounds.Max.Y-1)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		count(bounds.Min.X, y)
+		count(bounds.Max.X-1, y)
+	}
+	return edge
+}
+
+// normalizeContrast stretches the 1st to 99th luminance percentiles to the full range.
+func normalizeContrast(img *image.RGBA) {
+	bounds := img.Bounds()
+	var histogram [256]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			histogram[luminance(img.RGBAAt(x, y))]++
+		}
+	}
+
+	clip := bounds.Dx() * bounds.Dy() / 100
+	low, high := 0, 255
+	for seen := histogram[low]; seen <= clip && low < 255; seen += histogram[low] {
+		low++
+	}
+	for seen := histogram[high]; seen <= clip && high > 0; seen += histogram[high] {
+		high--
+	}
+	if high <= low {
+		return
+	}
+
+	stretch := func(value uint8) uint8 {
+		scaled := (int(value) - low) * 255 / (high - low)
+		return uint8(min(max(scaled, 0), 255))
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := img.RGBAAt(x, y)
+			img.SetRGBA(x, y, color.RGBA{R: stretch(pixel.R), G: stretch(pixel.G), B: stretch(pixel.B), A: pixel.A})
+		}
+	}
+}
+
+// adaptiveThreshold turns the image black-on-white, comparing each pixel to the mean
+// of its neighbourhood so uneven lighting across a photo does not swallow the ink.
+func adaptiveThreshold(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	radius := max(min(width, height)/32, 1)
+
+	// integral[y][x] holds the luminance sum of the rectangle above and left of (x, y).
+	integral := make([]int64, (width+1)*(height+1))
+	for y := 0; y < height; y++ {
+		var rowSum int64
+		for x := 0; x < width; x++ {
+			rowSum += int64(luminance(img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)))
+			integral[(y+1)*(width+1)+x+1] = integral[y*(width+1)+x+1] + rowSum
+		}
+	}
+
+	thresholded := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, width)
+			area := int64((x1 - x0) * (y1 - y0))
+			sum := integral[y1*(width+1)+x1] - integral[y0*(width+1)+x1] -
+				integral[y1*(width+1)+x0] + integral[y0*(width+1)+x0]
+
+			value := float64(luminance(img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)))
+			if value*float64(area) < float64(sum)*(1-thresholdSensitivity) {
+				thresholded.SetRGBA(x, y, color.RGBA{A: 255})
+			} else {
+				thresholded.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+	}
+	return thresholded
+}
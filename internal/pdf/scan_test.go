@@ -0,0 +1,89 @@
+package pdf_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var (
+	tableColor = color.RGBA{R: 60, G: 50, B: 40, A: 255}
+	cardColor  = color.RGBA{R: 235, G: 232, B: 225, A: 255}
+	inkColor   = color.RGBA{R: 20, G: 20, B: 30, A: 255}
+)
+
+// scannedSheet draws a light card with ruled lines on a dark table.
+func scannedSheet(card image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 500))
+	draw.Draw(img, img.Bounds(), image.NewUniform(tableColor), image.Point{}, draw.Src)
+	draw.Draw(img, card, image.NewUniform(cardColor), image.Point{}, draw.Src)
+	for y := card.Min.Y + 20; y < card.Max.Y-10; y += 20 {
+		line := image.Rect(card.Min.X+10, y, card.Max.X-10, y+3)
+		draw.Draw(img, line, image.NewUniform(inkColor), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// tiltedLines draws ruled lines sloping by the given angle in degrees.
+func tiltedLines(degrees float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	slope := math.Tan(degrees * math.Pi / 180)
+	for row := 40; row < 380; row += 30 {
+		for x := 20; x < 380; x++ {
+			y := row + int(float64(x)*slope)
+			for thickness := 0; thickness < 4; thickness++ {
+				img.SetRGBA(x, y+thickness, inkColor)
+			}
+		}
+	}
+	return img
+}
+
+var _ = Describe("Scanned Page Cleanup", func() {
+	It("should detect the card on a darker background", func() {
+		card := image.Rect(48, 60, 352, 440)
+		bounds := pdf.DetectCardBounds(scannedSheet(card))
+
+		Expect(bounds.Min.X).To(BeNumerically("~", card.Min.X, 4))
+		Expect(bounds.Min.Y).To(BeNumerically("~", card.Min.Y, 4))
+		Expect(bounds.Max.X).To(BeNumerically("~", card.Max.X, 4))
+		Expect(bounds.Max.Y).To(BeNumerically("~", card.Max.Y, 4))
+	})
+
+	It("should keep the whole image when there is no card", func() {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		Expect(pdf.DetectCardBounds(img)).To(Equal(img.Bounds()))
+	})
+
+	DescribeTable("EstimateSkew",
+		func(degrees float64) {
+			Expect(pdf.EstimateSkew(tiltedLines(degrees), 10)).To(BeNumerically("~", degrees, 0.5))
+		},
+		Entry("straight lines", 0.0),
+		Entry("clockwise tilt", 3.0),
+		Entry("counter-clockwise tilt", -4.0),
+	)
+
+	It("should straighten tilted lines", func() {
+		straightened := pdf.CleanScannedPage(tiltedLines(4), pdf.ScanOptions{Enabled: true, Deskew: true})
+		Expect(pdf.EstimateSkew(straightened, 10)).To(BeNumerically("~", 0, 0.5))
+	})
+
+	It("should produce a black and white card", func() {
+		card := pdf.CleanScannedPage(scannedSheet(image.Rect(48, 60, 352, 440)), pdf.AllScanSteps())
+
+		Expect(card.Bounds().Dx()).To(BeNumerically("~", 304, 8))
+		Expect(card.Bounds().Dy()).To(BeNumerically("~", 380, 8))
+		for _, value := range card.Pix {
+			Expect(value).To(Or(Equal(uint8(0)), Equal(uint8(255))))
+		}
+	})
+})
@@ -3,6 +3,7 @@ package pdf
 import (
 	"fmt"
 	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"github.com/kpauljoseph/notesankify/pkg/models"
 	"image"
 	"image/png"
 	"os"
@@ -10,9 +11,23 @@ import (
 )
 
 type ImagePair struct {
-	Question string
-	Answer   string
-	Hash     string
+	Question     string
+	Answer       string
+	Hash         string
+	Source       CardSource
+	QuestionText string // typed text found in the question region, markers removed
+	AnswerText   string
+	Extras       []ExtraImage // hint and notes regions of the card, if any
+	Directives   Directives   // instructions written on the card
+	BlankAnswer  bool         // the answer is empty, see BlankAnswerOptions
+}
+
+// CardSource records where in the PDF a card was found.
+type CardSource struct {
+	PageNumber       int
+	CellIndex        int      // zero-based cell within the page layout
+	AnswerPageNumber int      // set when the answer comes from a separate page
+	Outline          []string // titles of the enclosing bookmarks, outermost first
 }
 
 type Splitter struct {
@@ -32,6 +47,12 @@ func NewSplitter(outputDir string, logger *logger.Logger) (*Splitter, error) {
 }
 
 func (s *Splitter) SplitImageWithHash(imagePath, baseName, fullHash string) (*ImagePair, error) {
+	return s.SplitImageWithSpec(imagePath, baseName, fullHash, models.SplitSpec{Direction: models.SplitHorizontal}, 1)
+}
+
+// SplitImageWithSpec splits the image according to spec. Scale converts the spec's
+// PDF points into image pixels. An automatic direction follows the image's orientation.
+func (s *Splitter) SplitImageWithSpec(imagePath, baseName, fullHash string, spec models.SplitSpec, scale float64) (*ImagePair, error) {
 	s.logger.Debug("Splitting image: %s", imagePath)
 
 	srcFile, err := os.Open(imagePath)
@@ -45,27 +66,31 @@ func (s *Splitter) SplitImageWithHash(imagePath, baseName, fullHash string) (*Im
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	bounds := src.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-	midPoint := height / 2
+	questionImg, answerImg, err := s.SplitImage(src, spec, scale)
+	if err != nil {
+		return nil, err
+	}
 
-	questionPath := filepath.Join(s.outputDir, fmt.Sprintf("%s_%s_question.png", baseName, fullHash[:8]))
-	answerPath := filepath.Join(s.outputDir, fmt.Sprintf("%s_%s_answer.png", baseName, fullHash[:8]))
+	return s.SaveImagePair(questionImg, answerImg, baseName, fullHash)
+}
 
-	questionImg := image.NewRGBA(image.Rect(0, 0, width, midPoint))
-	for y := 0; y < midPoint; y++ {
-		for x := 0; x < width; x++ {
-			questionImg.Set(x, y, src.At(x, y))
-		}
+// SplitImage divides an in-memory card image into its question and answer parts.
+func (s *Splitter) SplitImage(src image.Image, spec models.SplitSpec, scale float64) (*image.RGBA, *image.RGBA, error) {
+	bounds := src.Bounds()
+	spec = spec.ForOrientation(bounds.Dx() > bounds.Dy())
+	questionRect, answerRect := SplitRects(bounds, spec, scale)
+	if questionRect.Empty() || answerRect.Empty() {
+		return nil, nil, fmt.Errorf("split layout leaves an empty question or answer region")
 	}
+	s.logger.Debug("Split %s: question %v, answer %v", spec.Direction, questionRect, answerRect)
 
-	answerImg := image.NewRGBA(image.Rect(0, 0, width, height-midPoint))
-	for y := midPoint; y < height; y++ {
-		for x := 0; x < width; x++ {
-			answerImg.Set(x, y-midPoint, src.At(x, y))
-		}
-	}
+	return cropImage(src, questionRect), cropImage(src, answerRect), nil
+}
+
+// SaveImagePair writes already separated question and answer images to the output directory.
+func (s *Splitter) SaveImagePair(questionImg, answerImg *image.RGBA, baseName, fullHash string) (*ImagePair, error) {
+	questionPath := filepath.Join(s.outputDir, fmt.Sprintf("%s_%s_question.png", baseName, fullHash[:8]))
+	answerPath := filepath.Join(s.outputDir, fmt.Sprintf("%s_%s_answer.png", baseName, fullHash[:8]))
 
 	if err := s.saveImage(questionImg, questionPath); err != nil {
 		return nil, fmt.Errorf("failed to save question image: %w", err)
@@ -85,6 +110,51 @@ func (s *Splitter) SplitImageWithHash(imagePath, baseName, fullHash string) (*Im
 	}, nil
 }
 
+// SplitRects computes the question and answer regions of a card image. The spec's
+// direction must already be resolved; scale converts PDF points into image pixels.
+func SplitRects(bounds image.Rectangle, spec models.SplitSpec, scale float64) (question, answer image.Rectangle) {
+	if questionRegion, answerRegion, ok := spec.QuestionAnswerRegions(); ok {
+		return scaleRect(questionRegion, bounds.Min, scale).Intersect(bounds),
+			scaleRect(answerRegion, bounds.Min, scale).Intersect(bounds)
+	}
+
+	content := image.Rect(
+		bounds.Min.X+int(spec.Margins.Left*scale),
+		bounds.Min.Y+int(spec.Margins.Top*scale),
+		bounds.Max.X-int(spec.Margins.Right*scale),
+		bounds.Max.Y-int(spec.Margins.Bottom*scale),
+	)
+	if content.Empty() {
+		content = bounds
+	}
+
+	if spec.Direction == models.SplitVertical {
+		midPoint := content.Min.X + splitPoint(content.Dx(), spec.Ratio)
+		return image.Rect(content.Min.X, content.Min.Y, midPoint, content.Max.Y),
+			image.Rect(midPoint, content.Min.Y, content.Max.X, content.Max.Y)
+	}
+
+	midPoint := content.Min.Y + splitPoint(content.Dy(), spec.Ratio)
+	return image.Rect(content.Min.X, content.Min.Y, content.Max.X, midPoint),
+		image.Rect(content.Min.X, midPoint, content.Max.X, content.Max.Y)
+}
+
+func splitPoint(length int, ratio float64) int {
+	if ratio <= 0 || ratio >= 1 {
+		return length / 2
+	}
+	return int(float64(length) * ratio)
+}
+
+func scaleRect(rect models.Rect, origin image.Point, scale float64) image.Rectangle {
+	return image.Rect(
+		origin.X+int(rect.X*scale),
+		origin.Y+int(rect.Y*scale),
+		origin.X+int((rect.X+rect.Width)*scale),
+		origin.Y+int((rect.Y+rect.Height)*scale),
+	)
+}
+
 func (s *Splitter) saveImage(img *image.RGBA, path string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/kpauljoseph/notesankify/internal/pdf"
 	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"github.com/kpauljoseph/notesankify/pkg/models"
 	"github.com/kpauljoseph/notesankify/pkg/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -141,5 +142,48 @@ var _ = Describe("Flashcard Splitter", func() {
 			Expect(answerImg.Bounds().Dx()).To(Equal(200))
 			Expect(answerImg.Bounds().Dy()).To(Equal(200))
 		})
+
+		It("should honor a custom split ratio", func() {
+			pair, err := splitter.SplitImageWithSpec(testImagePath, baseName, fullHash, models.SplitSpec{Ratio: 0.25}, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			questionImg := readImage(pair.Question)
+			answerImg := readImage(pair.Answer)
+
+			Expect(questionImg.Bounds().Dy()).To(Equal(100))
+			Expect(answerImg.Bounds().Dy()).To(Equal(300))
+		})
+	})
+
+	Context("when computing split regions", func() {
+		bounds := image.Rect(0, 0, 400, 200)
+
+		It("should split left and right for a vertical spec", func() {
+			question, answer := pdf.SplitRects(bounds, models.SplitSpec{Direction: models.SplitVertical}, 1)
+			Expect(question).To(Equal(image.Rect(0, 0, 200, 200)))
+			Expect(answer).To(Equal(image.Rect(200, 0, 400, 200)))
+		})
+
+		It("should crop margins before splitting", func() {
+			spec := models.SplitSpec{
+				Direction: models.SplitHorizontal,
+				Margins:   models.Margins{Top: 10},
+			}
+			question, answer := pdf.SplitRects(bounds, spec, 2)
+			Expect(question).To(Equal(image.Rect(0, 20, 400, 110)))
+			Expect(answer).To(Equal(image.Rect(0, 110, 400, 200)))
+		})
+
+		It("should use named question and answer regions", func() {
+			spec := models.SplitSpec{
+				Regions: map[string]models.Rect{
+					models.QuestionRegion: {X: 0, Y: 0, Width: 50, Height: 100},
+					models.AnswerRegion:   {X: 50, Y: 0, Width: 150, Height: 100},
+				},
+			}
+			question, answer := pdf.SplitRects(bounds, spec, 2)
+			Expect(question).To(Equal(image.Rect(0, 0, 100, 200)))
+			Expect(answer).To(Equal(image.Rect(100, 0, 400, 200)))
+		})
 	})
 })
@@ -0,0 +1,145 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/kpauljoseph/notesankify/internal/scanner"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// PageSizeCluster groups pages whose sizes agree within the dimension tolerance,
+// regardless of orientation.
+type PageSizeCluster struct {
+	Width       float64 // mean width in points, in the orientation first seen
+	Height      float64
+	PageCount   int
+	MarkerPages int      // pages carrying both QUESTION and ANSWER markers
+	Files       []string // relative paths of the PDFs with pages of this size
+}
+
+func (c PageSizeCluster) HasMarkers() bool {
+	return c.MarkerPages > 0
+}
+
+// Preset turns the cluster into named dimensions, rounded to two decimals.
+func (c PageSizeCluster) Preset(name string) models.PageDimensions {
+	round := func(v float64) float64 { return float64(int(v*100+0.5)) / 100 }
+	return models.PageDimensions{Name: name, Width: round(c.Width), Height: round(c.Height)}
+}
+
+// KnownPreset returns the built-in or custom preset this cluster already matches.
+func (c PageSizeCluster) KnownPreset(custom []models.PageDimensions) (models.PageDimensions, bool) {
+	for _, presets := range [][]models.PageDimensions{custom, models.DimensionPresets} {
+		for _, preset := range presets {
+			if preset.Matches(c.Width, c.Height) {
+				return preset, true
+			}
+		}
+	}
+	return models.PageDimensions{}, false
+}
+
+// SizeSurvey accumulates page sizes across PDFs.
+type SizeSurvey struct {
+	clusters []*PageSizeCluster
+}
+
+func NewSizeSurvey() *SizeSurvey {
+	return &SizeSurvey{}
+}
+
+// AddPage records one page. Sizes are merged into the first cluster they match.
+func (s *SizeSurvey) AddPage(file string, width, height float64, hasMarkers bool) {
+	var cluster *PageSizeCluster
+	for _, candidate := range s.clusters {
+		if (models.PageDimensions{Width: candidate.Width, Height: candidate.Height}).Matches(width, height) {
+			cluster = candidate
+			break
+		}
+	}
+
+	if cluster == nil {
+		cluster = &PageSizeCluster{Width: width, Height: height}
+		s.clusters = append(s.clusters, cluster)
+	} else {
+		// Keep the running mean in the cluster's own orientation.
+		if (width > height) != (cluster.Width > cluster.Height) {
+			width, height = height, width
+		}
+		count := float64(cluster.PageCount)
+		cluster.Width = (cluster.Width*count + width) / (count + 1)
+		cluster.Height = (cluster.Height*count + height) / (count + 1)
+	}
+
+	cluster.PageCount++
+	if hasMarkers {
+		cluster.MarkerPages++
+	}
+	if len(cluster.Files) == 0 || cluster.Files[len(cluster.Files)-1] != file {
+		cluster.Files = append(cluster.Files, file)
+	}
+}
+
+// AddPDF records every page of a PDF. Sizes come from pdfcpu because fitz rounds
+// page bounds to whole points.
+func (s *SizeSurvey) AddPDF(ctx context.Context, path, file string) error {
+	doc, err := fitz.New(path)
+	if err != nil {
+		return fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer doc.Close()
+
+	dims, err := api.PageDimsFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read page dimensions: %w", err)
+	}
+	if len(dims) != doc.NumPage() {
+		return fmt.Errorf("page count mismatch: %d sizes for %d pages", len(dims), doc.NumPage())
+	}
+
+	for pageIndex, dim := range dims {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		text, err := doc.Text(pageIndex)
+		if err != nil {
+			return fmt.Errorf("failed to extract text from page %d: %w", pageIndex+1, err)
+		}
+
+		s.AddPage(file, dim.Width, dim.Height, ContainsFlashcardMarkers(text))
+	}
+	return nil
+}
+
+// Clusters returns the clusters with the most pages first.
+func (s *SizeSurvey) Clusters() []PageSizeCluster {
+	clusters := make([]PageSizeCluster, len(s.clusters))
+	for i, cluster := range s.clusters {
+		clusters[i] = *cluster
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].PageCount > clusters[j].PageCount
+	})
+	return clusters
+}
+
+// SurveyPDFs clusters the page sizes of all given PDFs. Files that fail to open
+// are logged and skipped.
+func SurveyPDFs(ctx context.Context, pdfs []scanner.PDFFile, log *logger.Logger) ([]PageSizeCluster, error) {
+	survey := NewSizeSurvey()
+	for _, file := range pdfs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := survey.AddPDF(ctx, file.AbsolutePath, file.RelativePath); err != nil {
+			log.Info("Skipping %s: %v", file.RelativePath, err)
+		}
+	}
+	return survey.Clusters(), nil
+}
@@ -0,0 +1,47 @@
+package pdf_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+)
+
+var _ = Describe("Page Size Survey", func() {
+	It("should cluster sizes within tolerance regardless of orientation", func() {
+		survey := pdf.NewSizeSurvey()
+		survey.AddPage("a.pdf", 455.04, 588.45, true)
+		survey.AddPage("a.pdf", 455.04, 587.52, true)
+		survey.AddPage("b.pdf", 588.45, 455.04, false)
+		survey.AddPage("b.pdf", 595.28, 841.89, false)
+
+		clusters := survey.Clusters()
+		Expect(clusters).To(HaveLen(2))
+
+		flashcards := clusters[0]
+		Expect(flashcards.PageCount).To(Equal(3))
+		Expect(flashcards.MarkerPages).To(Equal(2))
+		Expect(flashcards.HasMarkers()).To(BeTrue())
+		Expect(flashcards.Files).To(Equal([]string{"a.pdf", "b.pdf"}))
+		Expect(flashcards.Width).To(BeNumerically("~", 455.04, 0.01))
+		Expect(flashcards.Height).To(BeNumerically("~", 588.14, 0.01))
+
+		known, ok := flashcards.KnownPreset(nil)
+		Expect(ok).To(BeTrue())
+		Expect(known.Name).To(Equal("goodnotes-standard"))
+
+		Expect(clusters[1].HasMarkers()).To(BeFalse())
+		_, ok = clusters[1].KnownPreset(nil)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should round saved presets to two decimals", func() {
+		survey := pdf.NewSizeSurvey()
+		survey.AddPage("a.pdf", 396.004, 611.996, false)
+
+		preset := survey.Clusters()[0].Preset("planner")
+		Expect(preset.Name).To(Equal("planner"))
+		Expect(preset.Width).To(Equal(396.0))
+		Expect(preset.Height).To(Equal(612.0))
+	})
+})
@@ -0,0 +1,129 @@
+package pdf
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+// averageGlyphWidth approximates a glyph's advance as a fraction of its font size.
+// MuPDF's HTML output has no line widths, so text boxes are estimated from this.
+const averageGlyphWidth = 0.7
+
+// TextBlock is a line of text on a page with its approximate bounding box in PDF points.
+type TextBlock struct {
+	Text     string
+	Rect     models.Rect
+	FontSize float64
+}
+
+var (
+	paragraphPattern = regexp.MustCompile(`(?s)<p style="top:([\d.]+)pt;left:([\d.]+)pt;line-height:([\d.]+)pt">(.*?)</p>`)
+	spanPattern      = regexp.MustCompile(`(?s)<span style="[^"]*font-size:([\d.]+)pt[^"]*">(.*?)</span>`)
+	tagPattern       = regexp.MustCompile(`<[^>]+>`)
+)
+
+// ParseTextBlocks extracts positioned lines from MuPDF's structured-text HTML.
+func ParseTextBlocks(markup string) []TextBlock {
+	var blocks []TextBlock
+	for _, paragraph := range paragraphPattern.FindAllStringSubmatch(markup, -1) {
+		top, _ := strconv.ParseFloat(paragraph[1], 64)
+		left, _ := strconv.ParseFloat(paragraph[2], 64)
+		lineHeight, _ := strconv.ParseFloat(paragraph[3], 64)
+
+		var text strings.Builder
+		var width, fontSize float64
+		for _, span := range spanPattern.FindAllStringSubmatch(paragraph[4], -1) {
+			size, _ := strconv.ParseFloat(span[1], 64)
+			content := html.UnescapeString(tagPattern.ReplaceAllString(span[2], ""))
+			text.WriteString(content)
+			width += float64(len([]rune(content))) * size * averageGlyphWidth
+			if size > fontSize {
+				fontSize = size
+			}
+		}
+
+		if text.Len() == 0 {
+			continue
+		}
+
+		blocks = append(blocks, TextBlock{
+			Text:     text.String(),
+			Rect:     models.Rect{X: left, Y: top, Width: width, Height: lineHeight},
+			FontSize: fontSize,
+		})
+	}
+	return blocks
+}
+
+// KeywordRects returns the approximate boxes of every occurrence of the keywords.
+// Boxes inside a longer line are interpolated from the keyword's character offset.
+func KeywordRects(blocks []TextBlock, keywords ...string) []models.Rect {
+	var rects []models.Rect
+	for _, block := range blocks {
+		runes := []rune(block.Text)
+		if len(runes) == 0 {
+			continue
+		}
+		charWidth := block.Rect.Width / float64(len(runes))
+
+		for _, keyword := range keywords {
+			searchFrom := 0
+			for {
+				offset := strings.Index(block.Text[searchFrom:], keyword)
+				if offset < 0 {
+					break
+				}
+				start := len([]rune(block.Text[:searchFrom+offset]))
+				rects = append(rects, models.Rect{
+					X:      block.Rect.X + float64(start)*charWidth,
+					Y:      block.Rect.Y,
+					Width:  float64(len([]rune(keyword))) * charWidth,
+					Height: block.Rect.Height,
+				})
+				searchFrom += offset + len(keyword)
+			}
+		}
+	}
+	return rects
+}
+
+// RegionText joins the lines whose centers fall inside region, in reading order,
+// dropping the QUESTION/ANSWER markers.
+func RegionText(blocks []TextBlock, region models.Rect) string {
+	var lines []TextBlock
+	for _, block := range blocks {
+		centerX := block.Rect.X + block.Rect.Width/2
+		centerY := block.Rect.Y + block.Rect.Height/2
+		if centerX >= region.X && centerX < region.X+region.Width &&
+			centerY >= region.Y && centerY < region.Y+region.Height {
+			lines = append(lines, block)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].Rect.Y != lines[j].Rect.Y {
+			return lines[i].Rect.Y < lines[j].Rect.Y
+		}
+		return lines[i].Rect.X < lines[j].Rect.X
+	})
+
+	var text []string
+	for _, line := range lines {
+		if content := stripMarkers(line.Text); content != "" {
+			text = append(text, content)
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+func stripMarkers(text string) string {
+	for _, keyword := range []string{utils.QuestionKeyword, utils.AnswerKeyword} {
+		text = strings.ReplaceAll(text, keyword, "")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
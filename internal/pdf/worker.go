@@ -0,0 +1,408 @@
+package pdf
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// WorkerSubcommand is the command line argument that makes the notesankify
+// executables serve as a render worker instead of starting normally.
+const WorkerSubcommand = "render-worker"
+
+// Defaults for the render worker's timeouts.
+const (
+	DefaultPageTimeout = time.Minute
+	DefaultFileTimeout = 10 * time.Minute
+)
+
+// workerMemoryEnvVar passes the memory limit, in bytes, to the worker process.
+const workerMemoryEnvVar = "NOTESANKIFY_WORKER_MEMORY_LIMIT"
+
+var (
+	// ErrWorkerCrashed is returned when the render worker exits during a call.
+	ErrWorkerCrashed = errors.New("render worker crashed")
+	// ErrWorkerTimeout is returned when the render worker does not answer in time.
+	ErrWorkerTimeout = errors.New("render worker timed out")
+)
+
+// IsWorkerFailure reports whether err is a crash or timeout of the render worker.
+func IsWorkerFailure(err error) bool {
+	return errors.Is(err, ErrWorkerCrashed) || errors.Is(err, ErrWorkerTimeout)
+}
+
+// WorkerOptions render PDFs in a child process, so a malformed page that crashes or
+// hangs MuPDF only takes down the worker, which is restarted for the next call.
+type WorkerOptions struct {
+	Enabled     bool
+	Command     []string      // worker executable and arguments, see WorkerSubcommand
+	PageTimeout time.Duration // limit for each call into the worker, 0 for none
+	FileTimeout time.Duration // limit for processing a whole PDF, 0 for none
+	MemoryLimit uint64        // worker data memory limit in bytes, 0 for none
+}
+
+// RenderFailure is a crash or timeout of the render worker. PageNumber is 0 for
+// failures that did not happen on a particular page.
+type RenderFailure struct {
+	PageNumber int
+	Reason     string
+}
+
+// Render worker operations.
+const (
+	workerOpen       = "open"
+	workerClose      = "close"
+	workerBound      = "bound"
+	workerText       = "text"
+	workerTextBlocks = "text-blocks"
+	workerImage      = "image"
+	workerToC        = "toc"
+	workerMetadata   = "metadata"
+)
+
+// workerRequest and workerResponse are gob encoded, one after the other, on the
+// worker's stdin and stdout.
+type workerRequest struct {
+	Op   string
+	Path string
+	Data []byte // decrypted contents of an encrypted PDF, sent instead of Path
+	Page int
+	DPI  float64
+}
+
+type workerResponse struct {
+	Err           string
+	NeedsPassword bool
+	NumPage       int
+	Bounds        image.Rectangle
+	Text          string
+	TextBlocks    []TextBlock
+	Image         *image.RGBA
+	Outline       []OutlineEntry
+	Metadata      map[string]string
+}
+
+// RunWorker serves render requests read from in, writing the responses to out,
+// until in is closed. It is what the WorkerSubcommand runs.
+func RunWorker(in io.Reader, out io.Writer) error {
+	if err := applyWorkerMemoryLimit(os.Getenv(workerMemoryEnvVar)); err != nil {
+		return fmt.Errorf("failed to apply memory limit: %w", err)
+	}
+
+	server := &workerServer{}
+	defer server.close()
+
+	decoder := gob.NewDecoder(in)
+	encoder := gob.NewEncoder(out)
+	for {
+		var request workerRequest
+		if err := decoder.Decode(&request); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+		if err := encoder.Encode(server.serve(request)); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+}
+
+// workerServer holds the document open in the worker.
+type workerServer struct {
+	doc *fitz.Document
+}
+
+func (s *workerServer) serve(request workerRequest) workerResponse {
+	var response workerResponse
+	var err error
+
+	switch request.Op {
+	case workerOpen:
+		s.close()
+		if request.Data != nil {
+			s.doc, err = fitz.NewFromMemory(request.Data)
+		} else {
+			s.doc, err = fitz.New(request.Path)
+		}
+		if errors.Is(err, fitz.ErrNeedsPassword) {
+			s.close()
+			return workerResponse{NeedsPassword: true}
+		}
+		if err != nil {
+			s.doc = nil
+			return workerResponse{Err: err.Error()}
+		}
+		return workerResponse{NumPage: s.doc.NumPage()}
+	case workerClose:
+		s.close()
+		return response
+	}
+
+	if s.doc == nil {
+		return workerResponse{Err: "no document open"}
+	}
+	doc := fitzDocument{s.doc}
+	switch request.Op {
+	case workerBound:
+		response.Bounds, err = doc.Bound(request.Page)
+	case workerText:
+		response.Text, err = doc.Text(request.Page)
+	case workerTextBlocks:
+		response.TextBlocks, err = doc.TextBlocks(request.Page)
+	case workerImage:
+		response.Image, err = doc.ImageDPI(request.Page, request.DPI)
+	case workerToC:
+		response.Outline, err = doc.ToC()
+	case workerMetadata:
+		response.Metadata = doc.Metadata()
+	default:
+		err = fmt.Errorf("unknown request %q", request.Op)
+	}
+	if err != nil {
+		response.Err = err.Error()
+	}
+	return response
+}
+
+func (s *workerServer) close() {
+	if s.doc != nil {
+		s.doc.Close()
+		s.doc = nil
+	}
+}
+
+// renderWorker is the worker process. It is started on first use and again after
+// a crash or timeout.
+type renderWorker struct {
+	options WorkerOptions
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+}
+
+func newRenderWorker(options WorkerOptions) *renderWorker {
+	return &renderWorker{options: options}
+}
+
+func (w *renderWorker) start() error {
+	if len(w.options.Command) == 0 {
+		return errors.New("no render worker command configured")
+	}
+
+	cmd := exec.Command(w.options.Command[0], w.options.Command[1:]...)
+	cmd.Env = os.Environ()
+	if w.options.MemoryLimit > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", workerMemoryEnvVar, w.options.MemoryLimit))
+	}
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to render worker: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to render worker: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start render worker: %w", err)
+	}
+
+	w.cmd, w.stdin = cmd, stdin
+	w.encoder = gob.NewEncoder(stdin)
+	w.decoder = gob.NewDecoder(stdout)
+	return nil
+}
+
+// stop ends the worker process and returns how it exited.
+func (w *renderWorker) stop() error {
+	if w.cmd == nil {
+		return nil
+	}
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	err := w.cmd.Wait()
+	w.cmd = nil
+	return err
+}
+
+type workerResult struct {
+	response workerResponse
+	err      error
+}
+
+// call sends one request and waits up to timeout for the response. A worker that
+// exits or times out is stopped, and the next call starts a new one.
+func (w *renderWorker) call(request workerRequest, timeout time.Duration) (workerResponse, error) {
+	if w.cmd == nil {
+		if err := w.start(); err != nil {
+			return workerResponse{}, err
+		}
+	}
+
+	results := make(chan workerResult, 1)
+	go func() {
+		var response workerResponse
+		err := w.encoder.Encode(request)
+		if err == nil {
+			err = w.decoder.Decode(&response)
+		}
+		results <- workerResult{response: response, err: err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			reason := result.err
+			if exit := w.stop(); exit != nil {
+				reason = exit
+			}
+			return workerResponse{}, fmt.Errorf("%w: %v", ErrWorkerCrashed, reason)
+		}
+		if result.response.Err != "" {
+			return result.response, errors.New(result.response.Err)
+		}
+		return result.response, nil
+	case <-expired:
+		w.cmd.Process.Kill()
+		<-results
+		w.stop()
+		return workerResponse{}, fmt.Errorf("%w after %v", ErrWorkerTimeout, timeout)
+	}
+}
+
+// open opens a PDF in the worker. Encrypted PDFs are decrypted in this process and
+// sent to the worker in memory. Calls on the document give up at deadline, if set.
+func (w *renderWorker) open(path string, passwords []PasswordProvider, deadline time.Time) (*workerDocument, error) {
+	doc := &workerDocument{
+		worker:      w,
+		openRequest: workerRequest{Op: workerOpen, Path: path},
+		deadline:    deadline,
+	}
+
+	response, err := doc.send(doc.openRequest)
+	if err != nil {
+		return nil, err
+	}
+	if response.NeedsPassword {
+		if doc.openRequest.Data, err = unlockPDF(path, passwords); err != nil {
+			return nil, err
+		}
+		if response, err = doc.send(doc.openRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	doc.numPage = response.NumPage
+	return doc, nil
+}
+
+// workerDocument is a PDF open in the render worker. Crashes and timeouts are
+// recorded as failures, and the document is reopened in a new worker on the next call.
+type workerDocument struct {
+	worker      *renderWorker
+	openRequest workerRequest
+	numPage     int
+	deadline    time.Time
+	reopen      bool
+	failures    []RenderFailure
+}
+
+var _ Document = (*workerDocument)(nil)
+
+func (d *workerDocument) call(request workerRequest) (workerResponse, error) {
+	if d.reopen {
+		if _, err := d.send(d.openRequest); err != nil {
+			return workerResponse{}, fmt.Errorf("failed to reopen PDF after worker restart: %w", err)
+		}
+		d.reopen = false
+	}
+
+	response, err := d.send(request)
+	if IsWorkerFailure(err) {
+		failure := RenderFailure{Reason: err.Error()}
+		if request.Op != workerToC && request.Op != workerMetadata {
+			failure.PageNumber = request.Page + 1
+		}
+		d.failures = append(d.failures, failure)
+		d.reopen = true
+	}
+	return response, err
+}
+
+// send makes one call within the page timeout and what is left of the deadline.
+func (d *workerDocument) send(request workerRequest) (workerResponse, error) {
+	timeout := d.worker.options.PageTimeout
+	if !d.deadline.IsZero() {
+		remaining := time.Until(d.deadline)
+		if remaining <= 0 {
+			return workerResponse{}, fmt.Errorf("%w: file time limit reached", ErrWorkerTimeout)
+		}
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return d.worker.call(request, timeout)
+}
+
+func (d *workerDocument) NumPage() int {
+	return d.numPage
+}
+
+func (d *workerDocument) Bound(pageNumber int) (image.Rectangle, error) {
+	response, err := d.call(workerRequest{Op: workerBound, Page: pageNumber})
+	return response.Bounds, err
+}
+
+func (d *workerDocument) Text(pageNumber int) (string, error) {
+	response, err := d.call(workerRequest{Op: workerText, Page: pageNumber})
+	return response.Text, err
+}
+
+func (d *workerDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	response, err := d.call(workerRequest{Op: workerTextBlocks, Page: pageNumber})
+	return response.TextBlocks, err
+}
+
+func (d *workerDocument) ImageDPI(pageNumber int, dpi float64) (*image.RGBA, error) {
+	response, err := d.call(workerRequest{Op: workerImage, Page: pageNumber, DPI: dpi})
+	return response.Image, err
+}
+
+func (d *workerDocument) ToC() ([]OutlineEntry, error) {
+	response, err := d.call(workerRequest{Op: workerToC})
+	return response.Outline, err
+}
+
+func (d *workerDocument) Metadata() map[string]string {
+	response, err := d.call(workerRequest{Op: workerMetadata})
+	if err != nil {
+		return map[string]string{}
+	}
+	return response.Metadata
+}
+
+// Close releases the document in the worker, which keeps running for the next PDF.
+func (d *workerDocument) Close() error {
+	if d.reopen || d.worker.cmd == nil {
+		return nil
+	}
+	_, err := d.worker.call(workerRequest{Op: workerClose}, d.worker.options.PageTimeout)
+	return err
+}
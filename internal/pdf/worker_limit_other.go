@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package pdf
+
+// applyWorkerMemoryLimit does nothing where data memory limits are unavailable;
+// the worker then runs without a memory limit.
+func applyWorkerMemoryLimit(value string) error {
+	return nil
+}
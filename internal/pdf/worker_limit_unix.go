@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package pdf
+
+import (
+	"strconv"
+	"syscall"
+)
+
+// applyWorkerMemoryLimit caps the worker's data memory, so a page that makes MuPDF
+// allocate without bound fails in the worker instead of exhausting memory. The
+// address space limit is not used because the runtime reserves far more than it uses.
+func applyWorkerMemoryLimit(value string) error {
+	if value == "" {
+		return nil
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit})
+}
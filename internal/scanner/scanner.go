@@ -3,6 +3,7 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"github.com/kpauljoseph/notesankify/internal/config"
 	"github.com/kpauljoseph/notesankify/pkg/logger"
 	"os"
 	"path/filepath"
@@ -11,6 +12,10 @@ import (
 type PDFFile struct {
 	AbsolutePath string // Full path to the file
 	RelativePath string // Path relative to root directory
+	// DeckPath is RelativePath with folders renamed by their deck_names.name setting.
+	DeckPath string
+	// Overrides are the merged .notesankify.yaml settings of the file's folders.
+	Overrides config.Overrides
 }
 
 type DirectoryScanner struct {
@@ -26,6 +31,12 @@ func New(logger *logger.Logger) *DirectoryScanner {
 func (s *DirectoryScanner) FindPDFs(ctx context.Context, rootDir string) ([]PDFFile, error) {
 	var pdfs []PDFFile
 
+	// Settings and deck paths of the directories walked so far, keyed by clean path.
+	// The walk visits every directory before its contents.
+	rootDir = filepath.Clean(rootDir)
+	settings := make(map[string]config.Overrides)
+	deckDirs := make(map[string]string)
+
 	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		select {
 		case <-ctx.Done():
@@ -37,8 +48,10 @@ func (s *DirectoryScanner) FindPDFs(ctx context.Context, rootDir string) ([]PDFF
 			return fmt.Errorf("error accessing path %s: %w", path, err)
 		}
 
+		path = filepath.Clean(path)
 		if info.IsDir() {
 			s.logger.Printf("Scanning directory: %s", path)
+			s.resolveDirectory(path, rootDir, settings, deckDirs)
 			return nil
 		}
 
@@ -52,9 +65,12 @@ func (s *DirectoryScanner) FindPDFs(ctx context.Context, rootDir string) ([]PDFF
 			relPath = filepath.Base(path)
 		}
 
+		dir := filepath.Dir(path)
 		pdfs = append(pdfs, PDFFile{
 			AbsolutePath: path,
 			RelativePath: relPath,
+			DeckPath:     filepath.Join(deckDirs[dir], filepath.Base(path)),
+			Overrides:    settings[dir],
 		})
 
 		return nil
@@ -70,3 +86,34 @@ func (s *DirectoryScanner) FindPDFs(ctx context.Context, rootDir string) ([]PDFF
 
 	return pdfs, nil
 }
+
+// resolveDirectory merges the directory's override file, if any, into the settings
+// of its parent and names its part of the deck path. An override file that cannot be
+// loaded is logged and ignored, so its folder keeps the settings of its parent.
+func (s *DirectoryScanner) resolveDirectory(dir, rootDir string, settings map[string]config.Overrides, deckDirs map[string]string) {
+	var own config.Overrides
+	overridePath := filepath.Join(dir, config.OverrideFileName)
+	if _, err := os.Stat(overridePath); err == nil {
+		loaded, err := config.LoadOverrides(overridePath)
+		if err != nil {
+			s.logger.Printf("Warning: ignoring folder settings in %s: %v", overridePath, err)
+		} else {
+			own = loaded
+			s.logger.Printf("Using folder settings from %s", overridePath)
+		}
+	}
+
+	if dir == rootDir {
+		settings[dir] = config.Overrides{}.Merge(own)
+		deckDirs[dir] = ""
+		return
+	}
+
+	parent := filepath.Dir(dir)
+	settings[dir] = settings[parent].Merge(own)
+	name := filepath.Base(dir)
+	if own.DeckNames.Name != "" {
+		name = own.DeckNames.Name
+	}
+	deckDirs[dir] = filepath.Join(deckDirs[parent], name)
+}
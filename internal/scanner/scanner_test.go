@@ -113,6 +113,75 @@ var _ = Describe("Scanner", func() {
 		})
 	})
 
+	When("when folders have override files", func() {
+		BeforeEach(func() {
+			files := map[string]string{
+				".notesankify.yaml":              "tags: [school]\nnote_model: Cards\n",
+				"cs101/.notesankify.yaml":        "deck_names:\n  name: Algorithms\nprocessing:\n  check_markers: false\nflashcard_size:\n  width: 595\n  height: 842\ntags: [cs101]\n",
+				"cs101/graphs/.notesankify.yaml": "note_model: Graph Cards\n",
+				"cs101/graphs/week1.pdf":         "dummy pdf content",
+				"cs101/intro.pdf":                "dummy pdf content",
+				"biology/cells.pdf":              "dummy pdf content",
+			}
+			for name, content := range files {
+				path := filepath.Join(testDir, name)
+				Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+				Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+			}
+		})
+
+		It("should merge the settings of each PDF's folders", func() {
+			s := scanner.New(testLogger)
+			pdfs, err := s.FindPDFs(ctx, testDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			byPath := make(map[string]scanner.PDFFile)
+			for _, pdf := range pdfs {
+				byPath[filepath.ToSlash(pdf.RelativePath)] = pdf
+			}
+			Expect(byPath).To(HaveLen(3))
+
+			week1 := byPath["cs101/graphs/week1.pdf"]
+			Expect(filepath.ToSlash(week1.DeckPath)).To(Equal("Algorithms/graphs/week1.pdf"))
+			Expect(week1.Overrides.Tags).To(Equal([]string{"school", "cs101"}))
+			Expect(week1.Overrides.NoteModel).To(Equal("Graph Cards"))
+			Expect(*week1.Overrides.Processing.CheckMarkers).To(BeFalse())
+			Expect(week1.Overrides.FlashcardSize.Width).To(Equal(595.0))
+
+			intro := byPath["cs101/intro.pdf"]
+			Expect(filepath.ToSlash(intro.DeckPath)).To(Equal("Algorithms/intro.pdf"))
+			Expect(intro.Overrides.NoteModel).To(Equal("Cards"))
+
+			cells := byPath["biology/cells.pdf"]
+			Expect(filepath.ToSlash(cells.DeckPath)).To(Equal("biology/cells.pdf"))
+			Expect(cells.Overrides.Tags).To(Equal([]string{"school"}))
+			Expect(cells.Overrides.Processing.CheckMarkers).To(BeNil())
+			Expect(cells.Overrides.FlashcardSize.Width).To(BeZero())
+		})
+
+		It("should ignore only the settings of an invalid override file", func() {
+			Expect(os.WriteFile(filepath.Join(testDir, "biology", ".notesankify.yaml"), []byte("flashcard_size:\n  width: 300\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(testDir, "cs101", "graphs", ".notesankify.yaml"), []byte("tags: [unclosed\n"), 0644)).To(Succeed())
+
+			s := scanner.New(testLogger)
+			pdfs, err := s.FindPDFs(ctx, testDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pdfs).To(HaveLen(3))
+
+			for _, pdf := range pdfs {
+				switch filepath.ToSlash(pdf.RelativePath) {
+				case "biology/cells.pdf":
+					Expect(pdf.Overrides.Tags).To(Equal([]string{"school"}))
+					Expect(pdf.Overrides.FlashcardSize.Width).To(BeZero())
+				case "cs101/graphs/week1.pdf":
+					Expect(pdf.Overrides.Tags).To(Equal([]string{"school", "cs101"}))
+					Expect(pdf.Overrides.NoteModel).To(Equal("Cards"))
+					Expect(filepath.ToSlash(pdf.DeckPath)).To(Equal("Algorithms/graphs/week1.pdf"))
+				}
+			}
+		})
+	})
+
 	When("when context is cancelled", func() {
 		It("should stop scanning", func() {
 			deepDir := filepath.Join(testDir, "deep", "deeper", "deepest")
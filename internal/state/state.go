@@ -0,0 +1,185 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Card is what notesankify remembers about a card it put into Anki.
+type Card struct {
+	Hash         string `json:"hash"`
+	NoteID       int    `json:"note_id"`
+	DeckName     string `json:"deck"`
+	SourcePath   string `json:"source_path"`   // absolute path of the PDF
+	RelativePath string `json:"relative_path"` // path below the scanned directory
+	PageNumber   int    `json:"page"`
+	CellIndex    int    `json:"cell"` // zero-based cell within the page layout
+	// Tags are the tags notesankify gave the note, told apart from tags added in Anki
+	// when the note moves to another deck.
+	Tags []string `json:"tags,omitempty"`
+	// Unfinished marks a note added while the card's answer was still blank.
+	Unfinished bool      `json:"unfinished,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Store keeps the cards of past runs in a JSON file, keyed by content hash. It is
+// safe for concurrent use.
+type Store struct {
+	path  string
+	mu    sync.Mutex
+	cards map[string]Card
+	dirty bool
+}
+
+// DefaultPath returns the state file inside the user's configuration directory.
+func DefaultPath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = os.TempDir()
+	}
+	return filepath.Join(configDir, "notesankify", "state.json")
+}
+
+// Open reads the state file at path. A missing file is an empty store, created on
+// the first Save.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path, cards: make(map[string]Card)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	var cards []Card
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	for _, card := range cards {
+		store.cards[card.Hash] = card
+	}
+	return store, nil
+}
+
+// Path returns the file the store is saved to.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Record remembers a card, replacing what was known about its hash.
+func (s *Store) Record(card Card) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card.UpdatedAt.IsZero() {
+		card.UpdatedAt = time.Now()
+	}
+	s.cards[card.Hash] = card
+	s.dirty = true
+}
+
+// Lookup finds a card by content hash or by Anki note ID.
+func (s *Store) Lookup(hashOrNoteID string) (Card, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card, ok := s.cards[hashOrNoteID]; ok {
+		return card, true
+	}
+	if noteID, err := strconv.Atoi(hashOrNoteID); err == nil {
+		for _, card := range s.cards {
+			if card.NoteID == noteID {
+				return card, true
+			}
+		}
+	}
+	return Card{}, false
+}
+
+// LookupLocation finds the card last seen at a page and cell of a PDF.
+func (s *Store) LookupLocation(sourcePath string, pageNumber, cellIndex int) (Card, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found Card
+	for _, card := range s.cards {
+		if card.SourcePath == sourcePath && card.PageNumber == pageNumber && card.CellIndex == cellIndex &&
+			card.UpdatedAt.After(found.UpdatedAt) {
+			found = card
+		}
+	}
+	return found, found.Hash != ""
+}
+
+// Forget drops what was known about a hash, after its note was updated to another.
+func (s *Store) Forget(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cards[hash]; ok {
+		delete(s.cards, hash)
+		s.dirty = true
+	}
+}
+
+// Save writes the store if it changed since it was opened. The file is replaced
+// atomically, so an interrupted run keeps the previous state.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	cards := make([]Card, 0, len(s.cards))
+	for _, card := range s.cards {
+		cards = append(cards, card)
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		if cards[i].RelativePath != cards[j].RelativePath {
+			return cards[i].RelativePath < cards[j].RelativePath
+		}
+		if cards[i].PageNumber != cards[j].PageNumber {
+			return cards[i].PageNumber < cards[j].PageNumber
+		}
+		if cards[i].CellIndex != cards[j].CellIndex {
+			return cards[i].CellIndex < cards[j].CellIndex
+		}
+		return cards[i].Hash < cards[j].Hash
+	})
+
+	data, err := json.MarshalIndent(cards, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(s.path), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(file.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace state: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,81 @@
+package state_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/internal/state"
+)
+
+var _ = Describe("Store", func() {
+	var statePath string
+
+	BeforeEach(func() {
+		statePath = filepath.Join(GinkgoT().TempDir(), "notesankify", "state.json")
+	})
+
+	It("should start empty without a state file", func() {
+		store, err := state.Open(statePath)
+		Expect(err).NotTo(HaveOccurred())
+		_, found := store.Lookup("abc")
+		Expect(found).To(BeFalse())
+
+		Expect(store.Save()).To(Succeed())
+		Expect(statePath).NotTo(BeAnExistingFile())
+	})
+
+	It("should find saved cards by hash and note ID", func() {
+		store, err := state.Open(statePath)
+		Expect(err).NotTo(HaveOccurred())
+		store.Record(state.Card{Hash: "abc", NoteID: 1700000000001, SourcePath: "/notes/biology/cells.pdf", RelativePath: "biology/cells.pdf", PageNumber: 3})
+		store.Record(state.Card{Hash: "def", NoteID: 1700000000002, RelativePath: "biology/cells.pdf", PageNumber: 1})
+		Expect(store.Save()).To(Succeed())
+
+		reopened, err := state.Open(statePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		card, found := reopened.Lookup("abc")
+		Expect(found).To(BeTrue())
+		Expect(card.SourcePath).To(Equal("/notes/biology/cells.pdf"))
+		Expect(card.PageNumber).To(Equal(3))
+		Expect(card.UpdatedAt).NotTo(BeZero())
+
+		card, found = reopened.Lookup("1700000000002")
+		Expect(found).To(BeTrue())
+		Expect(card.Hash).To(Equal("def"))
+	})
+
+	It("should find the latest card at a location and forget replaced ones", func() {
+		store, err := state.Open(statePath)
+		Expect(err).NotTo(HaveOccurred())
+		store.Record(state.Card{Hash: "blank", NoteID: 1, SourcePath: "/notes/cells.pdf", PageNumber: 2, Unfinished: true, UpdatedAt: time.Now().Add(-time.Hour)})
+		store.Record(state.Card{Hash: "other", NoteID: 2, SourcePath: "/notes/cells.pdf", PageNumber: 2, CellIndex: 1})
+
+		card, found := store.LookupLocation("/notes/cells.pdf", 2, 0)
+		Expect(found).To(BeTrue())
+		Expect(card.Hash).To(Equal("blank"))
+		Expect(card.Unfinished).To(BeTrue())
+
+		store.Record(state.Card{Hash: "filled", NoteID: 1, SourcePath: "/notes/cells.pdf", PageNumber: 2})
+		store.Forget("blank")
+		card, found = store.LookupLocation("/notes/cells.pdf", 2, 0)
+		Expect(found).To(BeTrue())
+		Expect(card.Hash).To(Equal("filled"))
+		_, found = store.Lookup("blank")
+		Expect(found).To(BeFalse())
+
+		_, found = store.LookupLocation("/notes/cells.pdf", 3, 0)
+		Expect(found).To(BeFalse())
+	})
+
+	It("should reject a corrupt state file", func() {
+		Expect(os.MkdirAll(filepath.Dir(statePath), 0755)).To(Succeed())
+		Expect(os.WriteFile(statePath, []byte("{"), 0644)).To(Succeed())
+		_, err := state.Open(statePath)
+		Expect(err).To(HaveOccurred())
+	})
+})
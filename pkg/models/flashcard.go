@@ -1,6 +1,39 @@
 package models
 
+import (
+	"fmt"
+	"math"
+
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+// PageDimensions is a page size in PDF points that identifies flashcard pages.
 type PageDimensions struct {
-	Width  float64
-	Height float64
+	Name   string  `yaml:"name"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+	// Tolerance is the allowed deviation in points. Zero uses the default tolerance.
+	Tolerance float64 `yaml:"tolerance"`
+	// Layout optionally overrides the processor's layout for pages matching these dimensions.
+	Layout *CardLayout `yaml:"layout"`
+}
+
+// DisplayName returns the preset name, or the size for unnamed dimensions.
+func (d PageDimensions) DisplayName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%.2f x %.2f", d.Width, d.Height)
+}
+
+// Matches reports whether a page size fits these dimensions in either orientation.
+func (d PageDimensions) Matches(width, height float64) bool {
+	tolerance := d.Tolerance
+	if tolerance <= 0 {
+		tolerance = utils.DIMENSION_TOLERANCE
+	}
+	within := func(a, b float64) bool { return math.Abs(a-b) <= tolerance }
+
+	return (within(width, d.Width) && within(height, d.Height)) ||
+		(within(width, d.Height) && within(height, d.Width))
 }
@@ -0,0 +1,133 @@
+package models
+
+// CardLayout describes a flashcard page as a grid of card cells.
+// The zero value is a single card covering the whole page.
+type CardLayout struct {
+	Name    string `yaml:"name"`
+	Rows    int    `yaml:"rows"`
+	Columns int    `yaml:"columns"`
+	// Split is the question/answer split used by every cell without an override.
+	Split SplitSpec `yaml:"split"`
+	// Cells optionally overrides the question/answer split of each cell, in row-major order.
+	Cells []SplitSpec `yaml:"cells"`
+}
+
+type SplitDirection string
+
+const (
+	SplitAuto       SplitDirection = ""           // horizontal for portrait pages, vertical for landscape
+	SplitHorizontal SplitDirection = "horizontal" // question on top, answer at the bottom
+	SplitVertical   SplitDirection = "vertical"   // question on the left, answer on the right
+)
+
+// SplitSpec describes how a single card is divided into question and answer.
+// Margins and regions are in PDF points, relative to the card's top-left corner.
+type SplitSpec struct {
+	Direction SplitDirection `yaml:"direction"`
+	// Ratio is the fraction of the card given to the question. Zero means an even split.
+	Ratio   float64 `yaml:"ratio"`
+	Margins Margins `yaml:"margins"`
+	// Regions names rectangles on the card. When both "question" and "answer" are
+	// present they are used instead of the direction and ratio.
+	Regions map[string]Rect `yaml:"regions"`
+}
+
+// Margins are cropped from each side of the card before it is split.
+type Margins struct {
+	Top    float64 `yaml:"top"`
+	Right  float64 `yaml:"right"`
+	Bottom float64 `yaml:"bottom"`
+	Left   float64 `yaml:"left"`
+}
+
+type Rect struct {
+	X      float64 `yaml:"x"`
+	Y      float64 `yaml:"y"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+}
+
+const (
+	QuestionRegion = "question"
+	AnswerRegion   = "answer"
+	HintRegion     = "hint"  // shown on the front behind a click
+	ExtraRegion    = "extra" // shown on the back below the answer
+)
+
+var (
+	SingleCardLayout = CardLayout{Name: "single", Rows: 1, Columns: 1}
+	TwoCardLayout    = CardLayout{Name: "grid-2", Rows: 2, Columns: 1}
+	FourCardLayout   = CardLayout{Name: "grid-4", Rows: 2, Columns: 2}
+	SixCardLayout    = CardLayout{Name: "grid-6", Rows: 3, Columns: 2}
+)
+
+// LayoutProfiles lists the built-in layouts, selectable by name.
+var LayoutProfiles = []CardLayout{
+	SingleCardLayout,
+	TwoCardLayout,
+	FourCardLayout,
+	SixCardLayout,
+}
+
+func LookupLayout(name string) (CardLayout, bool) {
+	for _, layout := range LayoutProfiles {
+		if layout.Name == name {
+			return layout, true
+		}
+	}
+	return CardLayout{}, false
+}
+
+// ResolveLayout fills in the grid of a layout that only names a built-in profile,
+// so configs can write `name: grid-4` alongside a custom split.
+func ResolveLayout(layout CardLayout) CardLayout {
+	if layout.Rows != 0 || layout.Columns != 0 {
+		return layout
+	}
+	if profile, ok := LookupLayout(layout.Name); ok {
+		layout.Rows = profile.Rows
+		layout.Columns = profile.Columns
+	}
+	return layout
+}
+
+func (l CardLayout) CellCount() int {
+	if l.Rows <= 0 || l.Columns <= 0 {
+		return 1
+	}
+	return l.Rows * l.Columns
+}
+
+func (l CardLayout) GridSize() (rows, columns int) {
+	if l.Rows <= 0 || l.Columns <= 0 {
+		return 1, 1
+	}
+	return l.Rows, l.Columns
+}
+
+func (l CardLayout) SplitForCell(cellIndex int) SplitSpec {
+	if cellIndex >= 0 && cellIndex < len(l.Cells) {
+		return l.Cells[cellIndex]
+	}
+	return l.Split
+}
+
+// ForOrientation resolves an automatic split direction for the given page orientation.
+func (s SplitSpec) ForOrientation(landscape bool) SplitSpec {
+	if s.Direction != SplitAuto {
+		return s
+	}
+	if landscape {
+		s.Direction = SplitVertical
+	} else {
+		s.Direction = SplitHorizontal
+	}
+	return s
+}
+
+// QuestionAnswerRegions returns the named question and answer rectangles, if both are set.
+func (s SplitSpec) QuestionAnswerRegions() (question, answer Rect, ok bool) {
+	question, hasQuestion := s.Regions[QuestionRegion]
+	answer, hasAnswer := s.Regions[AnswerRegion]
+	return question, answer, hasQuestion && hasAnswer
+}
@@ -0,0 +1,63 @@
+package models_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+var _ = Describe("Card Layouts", func() {
+	It("should treat the zero value as a single card", func() {
+		var layout models.CardLayout
+		Expect(layout.CellCount()).To(Equal(1))
+		rows, columns := layout.GridSize()
+		Expect(rows).To(Equal(1))
+		Expect(columns).To(Equal(1))
+	})
+
+	DescribeTable("built-in profiles",
+		func(name string, cells int) {
+			layout, ok := models.LookupLayout(name)
+			Expect(ok).To(BeTrue())
+			Expect(layout.CellCount()).To(Equal(cells))
+		},
+		Entry("single", "single", 1),
+		Entry("two cards", "grid-2", 2),
+		Entry("four cards", "grid-4", 4),
+		Entry("six cards", "grid-6", 6),
+	)
+
+	It("should not find unknown profiles", func() {
+		_, ok := models.LookupLayout("grid-9")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should fall back to an even split for cells without overrides", func() {
+		layout := models.CardLayout{
+			Rows:    1,
+			Columns: 2,
+			Cells:   []models.SplitSpec{{Ratio: 0.3}},
+		}
+		Expect(layout.SplitForCell(0).Ratio).To(Equal(0.3))
+		Expect(layout.SplitForCell(1).Ratio).To(BeZero())
+	})
+
+	It("should resolve automatic split direction from page orientation", func() {
+		var spec models.SplitSpec
+		Expect(spec.ForOrientation(false).Direction).To(Equal(models.SplitHorizontal))
+		Expect(spec.ForOrientation(true).Direction).To(Equal(models.SplitVertical))
+
+		spec.Direction = models.SplitHorizontal
+		Expect(spec.ForOrientation(true).Direction).To(Equal(models.SplitHorizontal))
+	})
+
+	It("should fill in the grid of a layout naming a built-in profile", func() {
+		layout := models.ResolveLayout(models.CardLayout{
+			Name:  "grid-4",
+			Split: models.SplitSpec{Direction: models.SplitVertical},
+		})
+		Expect(layout.CellCount()).To(Equal(4))
+		Expect(layout.Split.Direction).To(Equal(models.SplitVertical))
+	})
+})
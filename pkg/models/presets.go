@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+)
+
+var (
+	GoodNotesStandardPreset = PageDimensions{
+		Name:   "goodnotes-standard",
+		Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+		Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+	}
+	// GoodNotesLargePreset keeps the standard flashcard's proportions at A4 width.
+	GoodNotesLargePreset = PageDimensions{Name: "goodnotes-large", Width: 595.28, Height: 769.80}
+	NotabilityPreset     = PageDimensions{Name: "notability", Width: 574.00, Height: 768.00}
+	IndexCard3x5Preset   = PageDimensions{Name: "index-3x5", Width: 360.00, Height: 216.00}
+	IndexCard4x6Preset   = PageDimensions{Name: "index-4x6", Width: 432.00, Height: 288.00}
+	A5Preset             = PageDimensions{Name: "a5", Width: 419.53, Height: 595.28}
+	LetterPreset         = PageDimensions{Name: "letter", Width: 612.00, Height: 792.00}
+)
+
+// DimensionPresets lists the built-in page sizes, selectable by name.
+var DimensionPresets = []PageDimensions{
+	GoodNotesStandardPreset,
+	GoodNotesLargePreset,
+	NotabilityPreset,
+	IndexCard3x5Preset,
+	IndexCard4x6Preset,
+	A5Preset,
+	LetterPreset,
+}
+
+// LookupPreset finds a preset by name, checking the custom presets before the
+// built-in ones so configs can redefine a built-in size.
+func LookupPreset(name string, custom []PageDimensions) (PageDimensions, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, presets := range [][]PageDimensions{custom, DimensionPresets} {
+		for _, preset := range presets {
+			if strings.ToLower(preset.Name) == name {
+				return preset, true
+			}
+		}
+	}
+	return PageDimensions{}, false
+}
+
+// PresetNames returns the names of the custom and built-in presets.
+func PresetNames(custom []PageDimensions) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, presets := range [][]PageDimensions{custom, DimensionPresets} {
+		for _, preset := range presets {
+			if !seen[preset.Name] {
+				seen[preset.Name] = true
+				names = append(names, preset.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,38 @@
+package models_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kpauljoseph/notesankify/pkg/models"
+)
+
+var _ = Describe("Dimension Presets", func() {
+	It("should find built-in presets by name", func() {
+		preset, ok := models.LookupPreset(" Index-4x6 ", nil)
+		Expect(ok).To(BeTrue())
+		Expect(preset).To(Equal(models.IndexCard4x6Preset))
+
+		_, ok = models.LookupPreset("postcard", nil)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should prefer custom presets over built-in ones", func() {
+		custom := []models.PageDimensions{
+			{Name: "letter", Width: 600, Height: 800, Tolerance: 5},
+			{Name: "planner", Width: 396, Height: 612},
+		}
+
+		preset, ok := models.LookupPreset("letter", custom)
+		Expect(ok).To(BeTrue())
+		Expect(preset.Width).To(Equal(600.0))
+
+		names := models.PresetNames(custom)
+		Expect(names[:2]).To(Equal([]string{"letter", "planner"}))
+		Expect(names).To(HaveLen(len(models.DimensionPresets) + 1))
+	})
+
+	It("should describe unnamed dimensions by size", func() {
+		Expect(models.PageDimensions{Width: 100, Height: 200.5}.DisplayName()).To(Equal("100.00 x 200.50"))
+	})
+})
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"image"
+	"image/color"
+	"math/bits"
 )
 
 func GenerateImageHash(img image.Image) (string, error) {
@@ -19,3 +21,65 @@ func GenerateImageHash(img image.Image) (string, error) {
 
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
+
+// CombineHashes derives a single hash from several content hashes, in order.
+func CombineHashes(hashes ...string) string {
+	hasher := sha256.New()
+	for _, hash := range hashes {
+		fmt.Fprintf(hasher, "%s:", hash)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// PerceptualHash computes a 64-bit difference hash of the image: the image is
+// reduced to a 9x8 grid of average brightness and each bit records whether a cell
+// is brighter than its right neighbour. Similar images give hashes a few bits apart.
+func PerceptualHash(img image.Image) uint64 {
+	const columns, rows = 9, 8
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+
+	var grid [rows][columns]float64
+	for row := 0; row < rows; row++ {
+		minY := bounds.Min.Y + row*bounds.Dy()/rows
+		maxY := max(bounds.Min.Y+(row+1)*bounds.Dy()/rows, minY+1)
+		for column := 0; column < columns; column++ {
+			minX := bounds.Min.X + column*bounds.Dx()/columns
+			maxX := max(bounds.Min.X+(column+1)*bounds.Dx()/columns, minX+1)
+			grid[row][column] = averageBrightness(img, image.Rect(minX, minY, maxX, maxY).Intersect(bounds))
+		}
+	}
+
+	var hash uint64
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns-1; column++ {
+			hash <<= 1
+			if grid[row][column] > grid[row][column+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance returns the number of bits in which two perceptual hashes differ.
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func averageBrightness(img image.Image, rect image.Rectangle) float64 {
+	if rect.Empty() {
+		return 0
+	}
+
+	var sum float64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			sum += float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+		}
+	}
+	return sum / float64(rect.Dx()*rect.Dy())
+}
@@ -0,0 +1,629 @@
+package acceptance_test
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/kpauljoseph/notesankify/internal/anki"
+	"github.com/kpauljoseph/notesankify/internal/pdf"
+	"github.com/kpauljoseph/notesankify/pkg/logger"
+	"github.com/kpauljoseph/notesankify/pkg/models"
+	"github.com/kpauljoseph/notesankify/pkg/utils"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// fixedOCR is an OCR engine that recognizes the same text in every image.
+type fixedOCR string
+
+func (f fixedOCR) Recognize(img *image.RGBA) (string, error) {
+	return string(f), nil
+}
+
+// testWorkerEnvVar makes the test binary act as a render worker, so worker tests can
+// run without the notesankify executable. Its value picks how the worker behaves.
+const testWorkerEnvVar = "NOTESANKIFY_TEST_WORKER"
+
+func init() {
+	if mode := os.Getenv(testWorkerEnvVar); mode != "" {
+		os.Exit(runTestWorker(mode))
+	}
+}
+
+// testWorkerRequest mirrors the fields of the worker's requests.
+type testWorkerRequest struct {
+	Op   string
+	Path string
+	Data []byte
+	Page int
+	DPI  float64
+}
+
+// runTestWorker serves render requests. "hang" never answers, and "crash:<page>:<marker>"
+// exits on the first render of the page unless the marker file exists, creating it.
+func runTestWorker(mode string) int {
+	if mode == "hang" {
+		time.Sleep(time.Hour)
+		return 1
+	}
+
+	var crashPage int
+	var marker string
+	if _, err := fmt.Sscanf(mode, "crash:%d:%s", &crashPage, &marker); err != nil {
+		if err := pdf.RunWorker(os.Stdin, os.Stdout); err != nil {
+			return 1
+		}
+		return 0
+	}
+
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- pdf.RunWorker(reader, os.Stdout) }()
+
+	decoder := gob.NewDecoder(os.Stdin)
+	encoder := gob.NewEncoder(writer)
+	for {
+		var request testWorkerRequest
+		if err := decoder.Decode(&request); err != nil {
+			writer.Close()
+			<-done
+			return 0
+		}
+		if request.Op == "image" && request.Page+1 == crashPage {
+			if _, err := os.Stat(marker); os.IsNotExist(err) {
+				os.WriteFile(marker, nil, 0644)
+				return 2
+			}
+		}
+		if err := encoder.Encode(request); err != nil {
+			return 1
+		}
+	}
+}
+
+// Specs of the features added on top of the baseline cards. Unlike the Ordered end-to-end
+// specs, they do not depend on each other or on the recorded page hashes, so each runs
+// on its own.
+var _ = Describe("NotesAnkify Features", func() {
+	var (
+		processor   *pdf.Processor
+		tempDir     string
+		outputDir   string
+		ctx         context.Context
+		testDataDir string
+		testLogger  *logger.Logger
+	)
+
+	BeforeEach(func() {
+		var err error
+		ctx = context.Background()
+		testLogger = acceptanceTestLogger()
+		testDataDir = getTestDataPath()
+
+		tempDir, err = os.MkdirTemp("/tmp", "notesankify-acceptance-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tempDir)
+
+		outputDir, err = os.MkdirTemp("/tmp", "notesankify-output-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, outputDir)
+
+		processor, err = pdf.NewProcessor(pdf.ProcessorConfig{
+			TempDir:   tempDir,
+			OutputDir: outputDir,
+			Dimensions: models.PageDimensions{
+				Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+				Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+			},
+			ProcessingOptions: pdf.ProcessingOptions{
+				CheckDimensions: true,
+				CheckMarkers:    true,
+			},
+			Logger: testLogger,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("Two-page flashcards - question page followed by answer page", Label("happy-path"), func() {
+		It("should pair consecutive pages and report the trailing page", func() {
+			pdfPath := filepath.Join(testDataDir, "standard_flashcards.pdf")
+			testLogger.Info("Testing consecutive page pairing: %s", filepath.Base(pdfPath))
+
+			config := pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					PagePairing:     pdf.PairingConsecutive,
+				},
+				Logger: testLogger,
+			}
+
+			pairingProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			stats, err := pairingProcessor.ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			// standard_flashcards.pdf has 5 pages: (1,2) and (3,4) become cards, 5 is left over
+			Expect(stats.FlashcardCount).To(Equal(2))
+			Expect(stats.PageNumbers).To(Equal([]int{1, 3}))
+			Expect(stats.UnpairedPages).To(Equal([]int{5}))
+
+			for _, pair := range stats.ImagePairs {
+				Expect(pair.Source.AnswerPageNumber).To(Equal(pair.Source.PageNumber + 1))
+				Expect(pair.Question).To(BeAnExistingFile())
+				Expect(pair.Answer).To(BeAnExistingFile())
+			}
+			Expect(stats.ImagePairs[0].Hash).NotTo(Equal(stats.ImagePairs[1].Hash))
+		})
+	})
+
+	Context("Debug overlays", Label("happy-path"), func() {
+		It("should write one annotated image per processed page", func() {
+			pdfPath := filepath.Join(testDataDir, "mixed_content_sameSizeNormalPage_sameSizeFlashcardPage.pdf")
+			overlayDir := filepath.Join(tempDir, "overlays")
+
+			config := pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+				},
+				DebugOverlayDir: overlayDir,
+				Logger:          testLogger,
+			}
+
+			overlayProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			stats, err := overlayProcessor.ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.FlashcardCount).To(BeNumerically(">", 0))
+
+			doc, err := fitz.New(pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			defer doc.Close()
+
+			baseName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
+			for _, decision := range stats.Decisions {
+				overlayPath := filepath.Join(overlayDir, fmt.Sprintf("%s_page%03d.png", baseName, decision.PageNumber))
+				if decision.Outcome != pdf.PageCard {
+					Expect(overlayPath).NotTo(BeAnExistingFile())
+					continue
+				}
+
+				Expect(overlayPath).To(BeAnExistingFile())
+				overlayFile, err := os.Open(overlayPath)
+				Expect(err).NotTo(HaveOccurred())
+				overlay, err := png.DecodeConfig(overlayFile)
+				overlayFile.Close()
+				Expect(err).NotTo(HaveOccurred())
+
+				page, err := doc.Image(decision.PageNumber - 1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(image.Pt(overlay.Width, overlay.Height)).To(Equal(page.Bounds().Size()))
+			}
+		})
+	})
+
+	Context("OCR for handwritten pages", Label("happy-path"), func() {
+		newOCRProcessor := func(dimensions models.PageDimensions) *pdf.Processor {
+			config := pdf.ProcessorConfig{
+				TempDir:    tempDir,
+				OutputDir:  outputDir,
+				Dimensions: dimensions,
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+				},
+				OCR:    fixedOCR("QUESTION\nkrebs cycle\nANSWER"),
+				Logger: testLogger,
+			}
+
+			ocrProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+			return ocrProcessor
+		}
+
+		It("should find markers missing from the text layer through OCR", func() {
+			ocrProcessor := newOCRProcessor(models.PageDimensions{
+				Width:  utils.A4_PAGE_WIDTH,
+				Height: utils.A4_PAGE_HEIGHT,
+			})
+
+			stats, err := ocrProcessor.ProcessPDF(ctx, filepath.Join(testDataDir, "A4_size_normalPage_TopQnBottomAns_without_markers.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.FlashcardCount).To(Equal(5))
+
+			for _, decision := range stats.Decisions {
+				Expect(decision.MarkersByOCR).To(BeTrue())
+			}
+			// These pages have typed text, which is preferred over OCR.
+			for _, pair := range stats.ImagePairs {
+				Expect(pair.QuestionText).NotTo(BeEmpty())
+				Expect(pair.QuestionText).NotTo(Equal("krebs cycle"))
+			}
+		})
+
+		It("should fill the text of cards without a text layer from OCR", func() {
+			// Rebuild the first card as a page holding nothing but its rendered image.
+			doc, err := fitz.New(filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+			page, err := doc.Image(0)
+			doc.Close()
+			Expect(err).NotTo(HaveOccurred())
+
+			pagePath := filepath.Join(tempDir, "page.png")
+			pageFile, err := os.Create(pagePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(png.Encode(pageFile, page)).To(Succeed())
+			Expect(pageFile.Close()).To(Succeed())
+
+			imageOnlyPath := filepath.Join(tempDir, "image_only.pdf")
+			Expect(api.ImportImagesFile([]string{pagePath}, imageOnlyPath, pdfcpu.DefaultImportConfig(), nil)).To(Succeed())
+
+			// Imported pages are sized in pixels.
+			ocrProcessor := newOCRProcessor(models.PageDimensions{
+				Width:  float64(page.Bounds().Dx()),
+				Height: float64(page.Bounds().Dy()),
+			})
+
+			stats, err := ocrProcessor.ProcessPDF(ctx, imageOnlyPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.FlashcardCount).To(Equal(1))
+			Expect(stats.Decisions[0].MarkersByOCR).To(BeTrue())
+			Expect(stats.ImagePairs[0].QuestionText).To(Equal("krebs cycle"))
+			Expect(stats.ImagePairs[0].AnswerText).To(Equal("krebs cycle"))
+		})
+	})
+
+	Context("Outline sub-decks", Label("happy-path"), func() {
+		It("should file cards under the bookmark enclosing their page", func() {
+			pdfPath := filepath.Join(tempDir, "bookmarked.pdf")
+			bookmarks := []pdfcpu.Bookmark{
+				{Title: "Biology", PageFrom: 2, Kids: []pdfcpu.Bookmark{
+					{Title: "Cells", PageFrom: 2},
+					{Title: "Genetics", PageFrom: 4},
+				}},
+			}
+			Expect(api.AddBookmarksFile(filepath.Join(testDataDir, "standard_flashcards.pdf"), pdfPath, bookmarks, true, nil)).To(Succeed())
+
+			config := pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+					OutlineDecks:    true,
+				},
+				Logger: testLogger,
+			}
+			outlineProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			stats, err := outlineProcessor.ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.ImagePairs).To(HaveLen(5))
+
+			outlines := make(map[int][]string)
+			for _, pair := range stats.ImagePairs {
+				outlines[pair.Source.PageNumber] = pair.Source.Outline
+			}
+			Expect(outlines).To(Equal(map[int][]string{
+				1: nil,
+				2: {"Biology", "Cells"},
+				3: {"Biology", "Cells"},
+				4: {"Biology", "Genetics"},
+				5: {"Biology", "Genetics"},
+			}))
+
+			By("Grouping the cards into sub-decks of the file's deck")
+			var deckNames []string
+			for _, deck := range anki.GroupCardsByDeck("Root", "biology/bookmarked.pdf", stats.ImagePairs, stats.PageNumbers) {
+				deckNames = append(deckNames, fmt.Sprintf("%s (%d)", deck.DeckName, len(deck.Pairs)))
+			}
+			Expect(deckNames).To(Equal([]string{
+				"Root::biology::bookmarked (1)",
+				"Root::biology::bookmarked::Biology::Cells (2)",
+				"Root::biology::bookmarked::Biology::Genetics (2)",
+			}))
+		})
+	})
+
+	Context("Document metadata", Label("happy-path"), func() {
+		It("should name decks after the title and tag cards with the keywords", func() {
+			pdfPath := filepath.Join(tempDir, "Untitled 3.pdf")
+			properties := map[string]string{"Title": "Cell Biology", "Keywords": "biology, cell cycle; exam"}
+			Expect(api.AddPropertiesFile(filepath.Join(testDataDir, "standard_flashcards.pdf"), pdfPath, properties, nil)).To(Succeed())
+
+			stats, err := processor.ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.Metadata.Title).To(Equal("Cell Biology"))
+			Expect(stats.Metadata.Keywords).To(Equal([]string{"biology", "cell cycle", "exam"}))
+
+			By("Using the title in place of the file name")
+			deckPath := anki.DeckPath("notes/Untitled 3.pdf", stats.Metadata, true)
+			Expect(anki.GetDeckNameFromPath("Root", deckPath)).To(Equal("Root::notes::Cell Biology"))
+			Expect(anki.DeckPath("notes/Untitled 3.pdf", stats.Metadata, false)).To(Equal("notes/Untitled 3.pdf"))
+			Expect(anki.MetadataTags(stats.Metadata)).To(Equal([]string{"biology", "cell_cycle", "exam"}))
+		})
+
+		It("should keep the file name for PDFs without a title", func() {
+			stats, err := processor.ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(anki.DeckPath("standard_flashcards.pdf", stats.Metadata, true)).To(Equal("standard_flashcards.pdf"))
+		})
+	})
+
+	Context("Encrypted PDFs", Label("happy-path"), func() {
+		var encryptedPath string
+
+		BeforeEach(func() {
+			encryptedPath = filepath.Join(tempDir, "encrypted.pdf")
+			encryption := model.NewAESConfiguration("secret", "owner-secret", 256)
+			Expect(api.EncryptFile(filepath.Join(testDataDir, "standard_flashcards.pdf"), encryptedPath, encryption)).To(Succeed())
+		})
+
+		newPasswordProcessor := func(passwords ...pdf.PasswordProvider) *pdf.Processor {
+			config := pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+				},
+				Passwords: passwords,
+				Logger:    testLogger,
+			}
+			passwordProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+			return passwordProcessor
+		}
+
+		It("should process the PDF with a matching password", func() {
+			stats, err := newPasswordProcessor(
+				pdf.PasswordRules{{Pattern: "other.pdf", Password: "wrong"}},
+				pdf.PasswordRules{{Pattern: "encrypted.pdf", Password: "secret"}},
+			).ProcessPDF(ctx, encryptedPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.FlashcardCount).To(Equal(5))
+
+			plainStats, err := processor.ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+			for i, pair := range stats.ImagePairs {
+				Expect(pair.Hash).To(Equal(plainStats.ImagePairs[i].Hash))
+			}
+		})
+
+		It("should report a missing password", func() {
+			_, err := newPasswordProcessor().ProcessPDF(ctx, encryptedPath)
+			Expect(err).To(MatchError(pdf.ErrPasswordRequired))
+
+			report := &anki.ProcessingReport{}
+			Expect(report.RecordEncryptedPDF("encrypted.pdf", err)).To(BeTrue())
+			Expect(report.EncryptedPDFs).To(Equal([]anki.EncryptedPDFInfo{{FilePath: "encrypted.pdf", Reason: "no password supplied"}}))
+		})
+
+		It("should report a wrong password without revealing it", func() {
+			_, err := newPasswordProcessor(pdf.PasswordFunc(func(string) (string, bool) {
+				return "not-the-password", true
+			})).ProcessPDF(ctx, encryptedPath)
+			Expect(err).To(MatchError(pdf.ErrWrongPassword))
+			Expect(err.Error()).NotTo(ContainSubstring("not-the-password"))
+		})
+	})
+
+	Context("Render worker", Label("happy-path"), func() {
+		newWorkerProcessor := func(mode string, pageTimeout time.Duration) *pdf.Processor {
+			config := pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    true,
+				},
+				Worker: pdf.WorkerOptions{
+					Enabled:     true,
+					Command:     []string{"env", testWorkerEnvVar + "=" + mode, os.Args[0]},
+					PageTimeout: pageTimeout,
+				},
+				Logger: testLogger,
+			}
+			workerProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(workerProcessor.Cleanup)
+			return workerProcessor
+		}
+
+		It("should produce the same cards as rendering in process", func() {
+			pdfPath := filepath.Join(testDataDir, "standard_flashcards.pdf")
+			stats, err := newWorkerProcessor("serve", time.Minute).ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.RenderFailures).To(BeEmpty())
+
+			inProcessStats, err := processor.ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.PageNumbers).To(Equal(inProcessStats.PageNumbers))
+			for i, pair := range stats.ImagePairs {
+				Expect(pair.Hash).To(Equal(inProcessStats.ImagePairs[i].Hash))
+			}
+		})
+
+		It("should restart a crashed worker and record the failing page", func() {
+			mode := fmt.Sprintf("crash:2:%s", filepath.Join(tempDir, "crashed"))
+			stats, err := newWorkerProcessor(mode, time.Minute).ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stats.PageNumbers).To(Equal([]int{1, 3, 4, 5}))
+			Expect(stats.Decisions[1].Outcome).To(Equal(pdf.PageFailed))
+			Expect(stats.RenderFailures).To(HaveLen(1))
+			Expect(stats.RenderFailures[0].PageNumber).To(Equal(2))
+			Expect(stats.RenderFailures[0].Reason).To(ContainSubstring("render worker crashed"))
+
+			report := &anki.ProcessingReport{}
+			report.AddRenderFailures("standard_flashcards.pdf", stats.RenderFailures)
+			Expect(report.RenderFailures[0].Location()).To(Equal("standard_flashcards.pdf (Page 2)"))
+		})
+
+		It("should give up on a worker that stops answering", func() {
+			stats, err := newWorkerProcessor("hang", time.Second).ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).To(MatchError(pdf.ErrWorkerTimeout))
+			Expect(stats.RenderFailures).To(HaveLen(1))
+			Expect(stats.RenderFailures[0].PageNumber).To(Equal(0))
+		})
+	})
+
+	Context("Page selection", Label("happy-path"), func() {
+		newSelectionProcessor := func(expression string, pairing pdf.PairingMode) *pdf.Processor {
+			pages, err := pdf.ParsePageSelection(expression)
+			Expect(err).NotTo(HaveOccurred())
+
+			config := pdf.ProcessorConfig{
+				TempDir:   tempDir,
+				OutputDir: outputDir,
+				Dimensions: models.PageDimensions{
+					Width:  utils.GOODNOTES_STANDARD_FLASHCARD_WIDTH,
+					Height: utils.GOODNOTES_STANDARD_FLASHCARD_HEIGHT,
+				},
+				ProcessingOptions: pdf.ProcessingOptions{
+					CheckDimensions: true,
+					CheckMarkers:    pairing == pdf.PairingNone,
+					PagePairing:     pairing,
+					Pages:           pdf.PageRules{{Pattern: "standard_flashcards.pdf", Pages: pages}},
+				},
+				Logger: testLogger,
+			}
+			selectionProcessor, err := pdf.NewProcessor(config)
+			Expect(err).NotTo(HaveOccurred())
+			return selectionProcessor
+		}
+
+		It("should only process the selected pages", func() {
+			stats, err := newSelectionProcessor("2-3,-1", pdf.PairingNone).ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stats.PageCount).To(Equal(5))
+			Expect(stats.SelectedPages).To(Equal("2-3, 5"))
+			Expect(stats.PageNumbers).To(Equal([]int{2, 3, 5}))
+			Expect(stats.Decisions).To(HaveLen(3))
+			Expect(stats.Decisions[0].PageNumber).To(Equal(2))
+
+			report := &anki.ProcessingReport{}
+			report.AddPageSelection("standard_flashcards.pdf", stats.SelectedPages, stats.PageCount)
+			Expect(report.PageSelections).To(Equal([]anki.PageSelectionInfo{
+				{FilePath: "standard_flashcards.pdf", SelectedPages: "2-3, 5", PageCount: 5},
+			}))
+		})
+
+		It("should pair the selected pages", func() {
+			stats, err := newSelectionProcessor("2..", pdf.PairingConsecutive).ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stats.PageNumbers).To(Equal([]int{2, 4}))
+			Expect(stats.UnpairedPages).To(BeEmpty())
+			Expect(stats.Decisions).To(HaveLen(4))
+		})
+
+		It("should process every page of PDFs no rule matches", func() {
+			stats, err := newSelectionProcessor("1", pdf.PairingNone).ProcessPDF(ctx, filepath.Join(testDataDir, "mixed_content_sameSizeNormalPage_sameSizeFlashcardPage.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.SelectedPages).To(BeEmpty())
+			Expect(stats.Decisions).To(HaveLen(stats.PageCount))
+		})
+	})
+
+	Context("Card provenance", Label("happy-path"), func() {
+		It("should record the file and page of every card", func() {
+			pdfPath := filepath.Join(testDataDir, "standard_flashcards.pdf")
+			stats, err := processor.ProcessPDF(ctx, pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.ImagePairs).NotTo(BeEmpty())
+
+			source := anki.SourceFile{RelativePath: filepath.Join("cards", "standard_flashcards.pdf"), AbsolutePath: pdfPath}
+			pair := stats.ImagePairs[len(stats.ImagePairs)-1]
+			fields := anki.SourceFields(source, pair.Source, true)
+
+			absolutePath, err := filepath.Abs(pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+			link := fmt.Sprintf("%s#page=%d", anki.FileURL(absolutePath), pair.Source.PageNumber)
+			Expect(link).To(HavePrefix("file:///"))
+			Expect(fields["Source"]).To(Equal(fmt.Sprintf(`<a href="%s">cards/standard_flashcards.pdf</a>`, link)))
+			Expect(fields["Page"]).To(Equal(fmt.Sprint(stats.PageNumbers[len(stats.PageNumbers)-1])))
+			Expect(anki.SourceFields(source, pair.Source, false)["Source"]).NotTo(ContainSubstring("#page="))
+
+			var sortOrders []string
+			for _, pair := range stats.ImagePairs {
+				sortOrders = append(sortOrders, anki.SourceFields(source, pair.Source, false)["SortOrder"])
+			}
+			Expect(sortOrders[0]).To(HavePrefix("cards/standard_flashcards.pdf 0000"))
+			Expect(sort.StringsAreSorted(sortOrders)).To(BeTrue())
+		})
+
+		It("should read the source back from the note for locate", func() {
+			pdfPath := filepath.Join(testDataDir, "standard_flashcards.pdf")
+			absolutePath, err := filepath.Abs(pdfPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			source := anki.SourceFile{RelativePath: filepath.Join("my cards", "standard & more.pdf"), AbsolutePath: pdfPath}
+			fields := anki.SourceFields(source, pdf.CardSource{PageNumber: 4}, true)
+
+			sourcePath, relativePath, ok := anki.ParseSourceField(fields["Source"])
+			Expect(ok).To(BeTrue())
+			Expect(sourcePath).To(Equal(absolutePath))
+			Expect(relativePath).To(Equal(source.RelativePath))
+
+			_, _, ok = anki.ParseSourceField("")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("Unfinished cards", Label("happy-path"), func() {
+		It("should not mark answered cards as unfinished", func() {
+			detecting := processor.WithConfig(func(config *pdf.ProcessorConfig) {
+				config.BlankAnswers = pdf.BlankAnswerOptions{Enabled: true}
+			})
+			stats, err := detecting.ProcessPDF(ctx, filepath.Join(testDataDir, "standard_flashcards.pdf"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.ImagePairs).NotTo(BeEmpty())
+			for _, pair := range stats.ImagePairs {
+				Expect(pair.BlankAnswer).To(BeFalse(), "page %d", pair.Source.PageNumber)
+			}
+		})
+	})
+})